@@ -31,14 +31,23 @@ func RegisterRelativeIDTriggerUsingReflection(ctx context.Context, db *bun.DB, m
 	return RegisterRelativeIDTrigger(ctx, db, model, tableName, parentCol, relativeCol)
 }
 
+// RegisterRelativeIDTrigger maakt de trigger die bij een insert zonder relatief ID (NULL of 0)
+// het volgende ID binnen de parent toekent: MAX + 1.
+//
+// Gelijktijdige transacties voor dezelfde parent zouden hetzelfde MAX zien (de insert van de ander is nog niet
+// gecommit) en dan op de primary key stuklopen. Daarom neemt de trigger eerst een advisory lock op (tabel, parent),
+// die tot het einde van de transactie blijft staan: een tweede transactie wacht tot de eerste klaar is en ziet
+// daarna diens rij (READ COMMITTED: elk statement in de functie krijgt een nieuwe snapshot).
+// Transacties voor verschillende parents hinderen elkaar niet (op een zeldzame hash botsing na, die alleen wacht kost).
 func RegisterRelativeIDTrigger(ctx context.Context, db *bun.DB, model interface{},
 	tableName string, parentCol string, relativeCol string) error {
 
-	// 3. De SQL (Idempotent: kan veilig vaker gedraaid worden)
+	// 3. De SQL (Idempotent: kan veilig vaker gedraaid worden, dus bestaande databases krijgen de lock bij een herstart)
 	sql := fmt.Sprintf(`
         CREATE OR REPLACE FUNCTION fn_rel_id_%[1]s() RETURNS TRIGGER AS $$
         BEGIN
             IF NEW."%[3]s" IS NULL OR NEW."%[3]s" = 0 THEN
+                PERFORM pg_advisory_xact_lock(hashtext('%[1]s'), hashtext(NEW."%[2]s"::text));
                 SELECT COALESCE(MAX("%[3]s"), 0) + 1 INTO NEW."%[3]s"
                 FROM "%[1]s" WHERE "%[2]s" = NEW."%[2]s";
             END IF;
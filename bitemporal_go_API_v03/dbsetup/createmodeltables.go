@@ -49,6 +49,15 @@ func createModelTables(ctx context.Context, db *bun.DB) error {
 			if err != nil {
 				return fmt.Errorf("create table mislukt voor %s (%s): %w", typeName, meta.Tabelnaam, err)
 			}
+			// een bestaande tabel: voeg de kolommen toe die later bij de struct zijn gekomen, zie migratie.go
+			if err := RegisterOntbrekendeKolommen(ctx, db, dbModel); err != nil {
+				return fmt.Errorf("kon ontbrekende kolommen niet toevoegen voor %s (%s): %w", typeName, meta.Tabelnaam, err)
+			}
+
+			// zonder PFK: een nieuw ID komt uit de sequence van de ID kolom, zie migratie.go
+			if err := RegisterIDSequence(ctx, db, meta); err != nil {
+				return fmt.Errorf("kon sequence voor ID's niet aanmaken voor %s (%s): %w", typeName, meta.Tabelnaam, err)
+			}
 
 			// maak de triggerfuncties aan voor autoincrement van relatieve ID's,
 			// indien nodig
@@ -61,6 +70,18 @@ func createModelTables(ctx context.Context, db *bun.DB) error {
 				}
 			}
 
+			// enkelvoudig: hooguit één actief record per entiteit (materieel: geen overlappende perioden),
+			// ook bewaakt door de database, zie enkelvoudig_constraints.go
+			if err := RegisterEnkelvoudigConstraint(ctx, db, meta); err != nil {
+				return fmt.Errorf("kon enkelvoudigheid niet vastleggen voor %s (%s): %w", typeName, meta.Tabelnaam, err)
+			}
+
+			// indexen op de actieve records en de FK's naar de entiteiten, en de FK's die bun niet zelf maakt,
+			// zie indexen_constraints.go (de entiteiten bestaan dan al: zie createOrder)
+			if err := RegisterModelIndexenEnForeignKeys(ctx, db, meta, dbModel); err != nil {
+				return fmt.Errorf("kon indexen en foreign keys niet aanmaken voor %s (%s): %w", typeName, meta.Tabelnaam, err)
+			}
+
 		}
 	}
 
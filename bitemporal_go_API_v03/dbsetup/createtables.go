@@ -37,16 +37,41 @@ func CreateTables(db *bun.DB) error {
 	}
 
 	//Bitemporal core tables
+	// Registratie table (eerst: de andere core tabellen verwijzen ernaar)
+	_, err = db.NewCreateTable().Model((*model.Registratie)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if err := RegisterOntbrekendeKolommen(ctx, db, (*model.Registratie)(nil)); err != nil {
+		return err
+	}
+
 	// Wijziging table
 	_, err = db.NewCreateTable().Model((*model.Wijziging)(nil)).IfNotExists().Exec(ctx)
 	if err != nil {
 		return err
 	}
+	if err := RegisterOntbrekendeKolommen(ctx, db, (*model.Wijziging)(nil)); err != nil {
+		return err
+	}
+
+	// Idempotentiesleutel table (Idempotency-Key van /registratie/)
+	_, err = db.NewCreateTable().Model((*model.Idempotentiesleutel)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return err
+	}
 
-	// Registratie table
-	_, err = db.NewCreateTable().Model((*model.Registratie)(nil)).IfNotExists().Exec(ctx)
+	// Entiteitversie table (optimistische concurrency: laatste registratie per entiteit)
+	_, err = db.NewCreateTable().Model((*model.Entiteitversie)(nil)).IfNotExists().Exec(ctx)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	// een bestaande database bijwerken (unique tijdstip, entiteit_id aanvullen), zie migratie.go
+	if err := RegisterCoreMigratie(ctx, db); err != nil {
+		return err
+	}
+
+	// indexen en foreign keys van de core tabellen, zie indexen_constraints.go
+	return RegisterCoreIndexenEnForeignKeys(ctx, db)
 }
@@ -12,9 +12,19 @@ import (
 func DeleteTables(db *bun.DB) error {
 	ctx := context.Background()
 
-	// Deze twee tabellen zijn 'plumbing' voor elk register,
+	// Deze tabellen zijn 'plumbing' voor elk register,
 	// dus niet model-afhankelijk
-	_, err := db.NewDropTable().Model((*model.Registratie)(nil)).IfExists().Cascade().Exec(ctx)
+	_, err := db.NewDropTable().Model((*model.Idempotentiesleutel)(nil)).IfExists().Cascade().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDropTable().Model((*model.Entiteitversie)(nil)).IfExists().Cascade().Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDropTable().Model((*model.Registratie)(nil)).IfExists().Cascade().Exec(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,54 @@
+package dbsetup
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+)
+
+/*
+Enkelvoudige gegevenselementen/relaties (Momentvoorkomen Enkelvoudig in de metaregistry) hebben per entiteit
+hooguit één actief record. De registratie handlers zorgen daarvoor (zie sluitActieveEnkelvoudigeVoorgangersAf),
+maar de database bewaakt het ook, zodat directe SQL en toekomstige bugs het register niet kunnen corrumperen:
+- alleen formele tijd: een exclusion constraint met = op de FK naar de entiteit, voor de actieve records
+  (net als een partial unique index, maar die kan niet deferrable zijn)
+- materieel: een exclusion constraint, zodat de perioden tstzrange(aanvang, einde) van de actieve records
+  bij dezelfde entiteit niet overlappen (half open, net als model.PeriodesOverlappen)
+
+Actief betekent hier: opgevoerd en niet afgevoerd. Een ongedaan gemaakte opvoer (opvoer leeg) telt dus niet mee.
+Beide zijn DEFERRABLE INITIALLY DEFERRED: ze worden pas bij de commit gecontroleerd. Een replay (zie replay.go)
+herstelt de rijen één voor één en mag onderweg dus tijdelijk twee actieve records hebben.
+Beide zijn idempotent: ze worden bij elke start aangemaakt als ze nog niet bestaan.
+Een bestaande database die de regel al schendt, geeft bij het aanmaken een fout; herstel eerst de data.
+*/
+
+// enkelvoudigConstraintSQL geeft de SQL statements die de enkelvoudigheid van het type in de database bewaken,
+// of nil als het type niet enkelvoudig is.
+func enkelvoudigConstraintSQL(meta model.TypeMeta) []string {
+	if meta.Metatype == model.MetatypeEntiteit || meta.Momentvoorkomen != model.Enkelvoudig || meta.EntiteitIDKolom == "" {
+		return nil
+	}
+
+	const actief = `opvoer IS NOT NULL AND afvoer IS NULL`
+	naam := fmt.Sprintf("ex_%s_enkelvoudig", meta.Tabelnaam)
+
+	if !meta.IsMaterieel {
+		return []string{voegConstraintToeSQL(meta.Tabelnaam, naam, fmt.Sprintf(
+			`EXCLUDE USING btree ("%s" WITH =) WHERE (%s) DEFERRABLE INITIALLY DEFERRED`, meta.EntiteitIDKolom, actief))}
+	}
+
+	// het = op de integer FK in een gist index vraagt om btree_gist
+	return []string{
+		`CREATE EXTENSION IF NOT EXISTS btree_gist`,
+		voegConstraintToeSQL(meta.Tabelnaam, naam, fmt.Sprintf(
+			`EXCLUDE USING gist ("%s" WITH =, tstzrange(aanvang, einde) WITH &&) WHERE (%s) DEFERRABLE INITIALLY DEFERRED`,
+			meta.EntiteitIDKolom, actief)),
+	}
+}
+
+// RegisterEnkelvoudigConstraint maakt de constraint aan die de enkelvoudigheid van het type bewaakt (indien enkelvoudig).
+func RegisterEnkelvoudigConstraint(ctx context.Context, db *bun.DB, meta model.TypeMeta) error {
+	return voerUit(ctx, db, enkelvoudigConstraintSQL(meta))
+}
@@ -0,0 +1,46 @@
+package dbsetup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+func TestEnkelvoudigConstraintSQL(t *testing.T) {
+	t.Run("formeel enkelvoudig krijgt een deferrable exclusion constraint op de FK", func(t *testing.T) {
+		// Given: A_U is enkelvoudig en alleen formeel.
+		// When: de SQL wordt bepaald.
+		// Then: een exclusion constraint met = op a_id voor de actieve records, pas bij de commit gecontroleerd.
+		sql := enkelvoudigConstraintSQL(model.MetaRegistry.MustTypeMeta("A_U"))
+		if len(sql) != 1 || !strings.Contains(sql[0], `ALTER TABLE "a_u" ADD CONSTRAINT "ex_a_u_enkelvoudig" EXCLUDE USING btree ("a_id" WITH =) WHERE (opvoer IS NOT NULL AND afvoer IS NULL) DEFERRABLE INITIALLY DEFERRED`) {
+			t.Fatalf("expected a deferrable exclusion constraint on a_u, got %v", sql)
+		}
+	})
+
+	t.Run("materieel enkelvoudig krijgt een exclusion constraint op de periode", func(t *testing.T) {
+		// Given: B_X is enkelvoudig en (in deze test) materieel.
+		// When: de SQL wordt bepaald.
+		// Then: btree_gist en een deferrable exclusion constraint op b_id en tstzrange(aanvang, einde) voor de actieve records.
+		meta := model.MetaRegistry.MustTypeMeta("B_X")
+		meta.IsMaterieel = true
+		sql := enkelvoudigConstraintSQL(meta)
+		if len(sql) != 2 || !strings.Contains(sql[0], "btree_gist") {
+			t.Fatalf("expected btree_gist and a constraint, got %v", sql)
+		}
+		if !strings.Contains(sql[1], `EXCLUDE USING gist ("b_id" WITH =, tstzrange(aanvang, einde) WITH &&) WHERE (opvoer IS NOT NULL AND afvoer IS NULL) DEFERRABLE INITIALLY DEFERRED`) {
+			t.Fatalf("expected an exclusion constraint on b_x, got %s", sql[1])
+		}
+	})
+
+	t.Run("meervoudig en entiteiten krijgen niets", func(t *testing.T) {
+		// Given: A_V en Rel_A_B zijn meervoudig, A is een entiteit.
+		// When: de SQL wordt bepaald.
+		// Then: geen statements.
+		for _, typenaam := range []string{"A_V", "Rel_A_B", "A"} {
+			if sql := enkelvoudigConstraintSQL(model.MetaRegistry.MustTypeMeta(typenaam)); sql != nil {
+				t.Fatalf("expected no SQL for %s, got %v", typenaam, sql)
+			}
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package dbsetup
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+/*
+Indexen en foreign keys die bun niet uit de struct tags afleidt.
+
+Per type in de MetaRegistry:
+- een partial index op de actieve records (afvoer IS NULL): op de FK naar de entiteit, of bij een entiteit op het id.
+  Peilmomentloze reads en de kardinaliteit- en afvoerbeleid checks zoeken juist die records.
+- een index op elke FK naar een entiteit die niet al de eerste kolom van de primary key is (bij een PFK is dat wel zo)
+- een foreign key naar de entiteit, voor zover de struct geen belongs-to relatie heeft (die maakt bun zelf aan)
+
+Voor de core tabellen (plumbing):
+- wijziging: indexen op (representatienaam, representatie_id) voor de historie, en op registratie_id
+- foreign keys naar registratie vanuit wijziging, idempotentiesleutel, entiteitversie,
+  en vanuit registratie zelf naar de registratie die ze corrigeert of ongedaan maakt
+
+Alles is idempotent en wordt bij elke start aangemaakt als het nog niet bestaat.
+Een bestaande database met verwijzingen naar niet bestaande rijen geeft bij het aanmaken van een foreign key een fout.
+*/
+
+// voegConstraintToeSQL voegt een constraint toe als er nog geen constraint met die naam is
+// (ALTER TABLE ... ADD CONSTRAINT kent geen IF NOT EXISTS).
+func voegConstraintToeSQL(tabel string, naam string, definitie string) string {
+	return fmt.Sprintf(`
+        DO $$
+        BEGIN
+            IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[2]s') THEN
+                ALTER TABLE "%[1]s" ADD CONSTRAINT "%[2]s" %[3]s;
+            END IF;
+        END; $$`, tabel, naam, definitie)
+}
+
+// foreignKeySQL is een foreign key van tabel.kolom naar doeltabel.doelkolom, met de naam fk_<tabel>_<kolom>.
+func foreignKeySQL(tabel string, kolom string, doeltabel string, doelkolom string, onDelete string) string {
+	definitie := fmt.Sprintf(`FOREIGN KEY ("%s") REFERENCES "%s" ("%s")`, kolom, doeltabel, doelkolom)
+	if onDelete != "" {
+		definitie += " ON DELETE " + onDelete
+	}
+	return voegConstraintToeSQL(tabel, fmt.Sprintf("fk_%s_%s", tabel, kolom), definitie)
+}
+
+// entiteitFK is een FK kolom van een gegevenselement/relatie naar een entiteit.
+type entiteitFK struct {
+	kolom    string
+	entiteit model.TypeMeta
+}
+
+// entiteitFKs geeft de FK kolommen van een gegevenselement/relatie naar de bovenliggende (en secundaire) entiteit.
+func entiteitFKs(meta model.TypeMeta) ([]entiteitFK, error) {
+	if meta.Metatype == model.MetatypeEntiteit || meta.EntiteitIDKolom == "" {
+		return nil, nil
+	}
+
+	bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+	if !ok {
+		return nil, fmt.Errorf("geen bovenliggende entiteit gevonden voor %s", meta.Typenaam)
+	}
+	fks := []entiteitFK{{kolom: meta.EntiteitIDKolom, entiteit: bovenliggend.ParentType}}
+
+	if meta.SecondaireEntiteittype != "" {
+		secundair, ok := model.MetaRegistry.GetTypeMeta(meta.SecondaireEntiteittype)
+		if !ok {
+			return nil, fmt.Errorf("geen metadata voor %s (secundaire entiteit van %s)", meta.SecondaireEntiteittype, meta.Typenaam)
+		}
+		fks = append(fks, entiteitFK{kolom: meta.SecondaireEntiteitIDKolom, entiteit: secundair})
+	}
+	return fks, nil
+}
+
+// modelIndexSQL geeft de indexen voor de tabel van een type uit de MetaRegistry.
+func modelIndexSQL(meta model.TypeMeta) ([]string, error) {
+	actiefKolom := meta.IDKolom
+	if meta.Metatype != model.MetatypeEntiteit {
+		actiefKolom = meta.EntiteitIDKolom
+	}
+	sqls := []string{fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "ix_%[1]s_actief" ON "%[1]s" ("%[2]s") WHERE afvoer IS NULL`,
+		meta.Tabelnaam, actiefKolom)}
+
+	fks, err := entiteitFKs(meta)
+	if err != nil {
+		return nil, err
+	}
+	for _, fk := range fks {
+		if meta.HeeftPFK && fk.kolom == meta.EntiteitIDKolom {
+			// de eerste kolom van de primary key (entiteit id, relatief id): daar is al een index op
+			continue
+		}
+		sqls = append(sqls, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "ix_%[1]s_%[2]s" ON "%[1]s" ("%[2]s")`, meta.Tabelnaam, fk.kolom))
+	}
+	return sqls, nil
+}
+
+// modelForeignKeySQL geeft de foreign keys naar de entiteiten die bun niet al aanmaakt (viaBun: de kolommen met een belongs-to relatie).
+func modelForeignKeySQL(meta model.TypeMeta, viaBun map[string]bool) ([]string, error) {
+	fks, err := entiteitFKs(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	var sqls []string
+	for _, fk := range fks {
+		if viaBun[fk.kolom] {
+			continue
+		}
+		// net als de belongs-to relaties van de gegevenselementen: weg met de entiteit
+		sqls = append(sqls, foreignKeySQL(meta.Tabelnaam, fk.kolom, fk.entiteit.Tabelnaam, fk.entiteit.IDKolom, "CASCADE"))
+	}
+	return sqls, nil
+}
+
+// belongsToKolommen geeft de kolommen waarvoor bun (WithForeignKeys) al een foreign key aanmaakt.
+// Net als bun zelf: elke relatie behalve has-many en m2m (bun registreert belongs-to als HasOneRelation).
+func belongsToKolommen(db *bun.DB, dbModel any) map[string]bool {
+	typ := reflect.TypeOf(dbModel)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	kolommen := map[string]bool{}
+	for _, relatie := range db.Table(typ).Relations {
+		if relatie.Type == schema.HasManyRelation || relatie.Type == schema.ManyToManyRelation {
+			continue
+		}
+		for _, veld := range relatie.BaseFields {
+			kolommen[veld.Name] = true
+		}
+	}
+	return kolommen
+}
+
+// RegisterModelIndexenEnForeignKeys maakt de indexen en foreign keys voor de tabel van een type aan.
+func RegisterModelIndexenEnForeignKeys(ctx context.Context, db *bun.DB, meta model.TypeMeta, dbModel any) error {
+	indexen, err := modelIndexSQL(meta)
+	if err != nil {
+		return err
+	}
+	fks, err := modelForeignKeySQL(meta, belongsToKolommen(db, dbModel))
+	if err != nil {
+		return err
+	}
+	return voerUit(ctx, db, append(indexen, fks...))
+}
+
+// coreIndexenEnForeignKeysSQL geeft de indexen en foreign keys van de core tabellen.
+// Alle core tabellen en de registratie tabel moeten dan al bestaan.
+func coreIndexenEnForeignKeysSQL() []string {
+	return []string{
+		`CREATE INDEX IF NOT EXISTS "ix_wijziging_representatie" ON "wijziging" ("representatienaam", "representatie_id")`,
+		`CREATE INDEX IF NOT EXISTS "ix_wijziging_registratie_id" ON "wijziging" ("registratie_id")`,
+		foreignKeySQL("wijziging", "registratie_id", "registratie", "id", ""),
+		foreignKeySQL("registratie", "corrigeert_registratie_id", "registratie", "id", ""),
+		foreignKeySQL("registratie", "maakt_ongedaan_registratie_id", "registratie", "id", ""),
+		foreignKeySQL("idempotentiesleutel", "registratie_id", "registratie", "id", ""),
+		foreignKeySQL("entiteitversie", "registratie_id", "registratie", "id", ""),
+	}
+}
+
+// RegisterCoreIndexenEnForeignKeys maakt de indexen en foreign keys van de core tabellen aan.
+func RegisterCoreIndexenEnForeignKeys(ctx context.Context, db *bun.DB) error {
+	return voerUit(ctx, db, coreIndexenEnForeignKeysSQL())
+}
+
+func voerUit(ctx context.Context, db *bun.DB, sqls []string) error {
+	for _, sql := range sqls {
+		if _, err := db.ExecContext(ctx, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
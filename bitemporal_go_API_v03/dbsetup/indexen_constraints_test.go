@@ -0,0 +1,116 @@
+package dbsetup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestModelIndexSQL(t *testing.T) {
+	t.Run("relatie krijgt een actief index en indexen op beide FK's", func(t *testing.T) {
+		// Given: Rel_A_B verwijst via a_id en b_id naar A en B, zonder PFK.
+		// When: de indexen worden bepaald.
+		// Then: een partial index op a_id voor de actieve records en een index op a_id en op b_id.
+		sqls, err := modelIndexSQL(model.MetaRegistry.MustTypeMeta("Rel_A_B"))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		verwacht := []string{
+			`CREATE INDEX IF NOT EXISTS "ix_rel_a_b_actief" ON "rel_a_b" ("a_id") WHERE afvoer IS NULL`,
+			`CREATE INDEX IF NOT EXISTS "ix_rel_a_b_a_id" ON "rel_a_b" ("a_id")`,
+			`CREATE INDEX IF NOT EXISTS "ix_rel_a_b_b_id" ON "rel_a_b" ("b_id")`,
+		}
+		if strings.Join(sqls, "\n") != strings.Join(verwacht, "\n") {
+			t.Fatalf("expected %v, got %v", verwacht, sqls)
+		}
+	})
+
+	t.Run("gegevenselement met PFK krijgt geen extra index op de FK", func(t *testing.T) {
+		// Given: A_U heeft de PFK (a_id, rel_id), dus a_id is al de eerste kolom van de primary key.
+		// When: de indexen worden bepaald.
+		// Then: alleen de partial index op a_id voor de actieve records.
+		sqls, err := modelIndexSQL(model.MetaRegistry.MustTypeMeta("A_U"))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(sqls) != 1 || sqls[0] != `CREATE INDEX IF NOT EXISTS "ix_a_u_actief" ON "a_u" ("a_id") WHERE afvoer IS NULL` {
+			t.Fatalf("expected only the actief index, got %v", sqls)
+		}
+	})
+
+	t.Run("entiteit krijgt een actief index op het id", func(t *testing.T) {
+		// Given: A is een entiteit.
+		// When: de indexen worden bepaald.
+		// Then: een partial index op id voor de actieve records.
+		sqls, err := modelIndexSQL(model.MetaRegistry.MustTypeMeta("A"))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(sqls) != 1 || sqls[0] != `CREATE INDEX IF NOT EXISTS "ix_a_actief" ON "a" ("id") WHERE afvoer IS NULL` {
+			t.Fatalf("expected only the actief index, got %v", sqls)
+		}
+	})
+}
+
+func TestModelForeignKeySQL(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db := bun.NewDB(sqlDB, pgdialect.New())
+	defer db.Close()
+
+	t.Run("relatie zonder belongs-to krijgt FK's naar beide entiteiten", func(t *testing.T) {
+		// Given: Rel_A_B heeft geen belongs-to relaties in de struct.
+		// When: de foreign keys worden bepaald.
+		// Then: fk_rel_a_b_a_id naar a(id) en fk_rel_a_b_b_id naar b(id).
+		meta := model.MetaRegistry.MustTypeMeta("Rel_A_B")
+		sqls, err := modelForeignKeySQL(meta, belongsToKolommen(db, meta.DBFactory()))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(sqls) != 2 ||
+			!strings.Contains(sqls[0], `ADD CONSTRAINT "fk_rel_a_b_a_id" FOREIGN KEY ("a_id") REFERENCES "a" ("id") ON DELETE CASCADE`) ||
+			!strings.Contains(sqls[1], `ADD CONSTRAINT "fk_rel_a_b_b_id" FOREIGN KEY ("b_id") REFERENCES "b" ("id") ON DELETE CASCADE`) {
+			t.Fatalf("expected foreign keys to a and b, got %v", sqls)
+		}
+	})
+
+	t.Run("gegevenselement met belongs-to krijgt geen extra FK", func(t *testing.T) {
+		// Given: A_U heeft een belongs-to relatie naar A op a_id (die FK maakt bun al).
+		// When: de foreign keys worden bepaald.
+		// Then: geen extra foreign keys.
+		meta := model.MetaRegistry.MustTypeMeta("A_U")
+		sqls, err := modelForeignKeySQL(meta, belongsToKolommen(db, meta.DBFactory()))
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(sqls) != 0 {
+			t.Fatalf("expected no foreign keys, got %v", sqls)
+		}
+	})
+}
+
+func TestCoreIndexenEnForeignKeysSQL(t *testing.T) {
+	// Given: de core tabellen.
+	// When: de indexen en foreign keys worden bepaald.
+	// Then: de wijziging indexen en de FK's naar registratie (ook van registratie naar zichzelf).
+	sql := strings.Join(coreIndexenEnForeignKeysSQL(), "\n")
+	for _, verwacht := range []string{
+		`ON "wijziging" ("representatienaam", "representatie_id")`,
+		`ON "wijziging" ("registratie_id")`,
+		`"fk_wijziging_registratie_id" FOREIGN KEY ("registratie_id") REFERENCES "registratie" ("id")`,
+		`"fk_registratie_corrigeert_registratie_id" FOREIGN KEY ("corrigeert_registratie_id") REFERENCES "registratie" ("id")`,
+		`"fk_registratie_maakt_ongedaan_registratie_id" FOREIGN KEY ("maakt_ongedaan_registratie_id") REFERENCES "registratie" ("id")`,
+		`"fk_idempotentiesleutel_registratie_id"`,
+		`"fk_entiteitversie_registratie_id"`,
+	} {
+		if !strings.Contains(sql, verwacht) {
+			t.Errorf("expected %s in the core SQL", verwacht)
+		}
+	}
+}
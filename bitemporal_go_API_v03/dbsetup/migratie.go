@@ -0,0 +1,124 @@
+package dbsetup
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+)
+
+/*
+Bijwerken van een bestaande database.
+
+CREATE TABLE ... IF NOT EXISTS laat een bestaande tabel met rust, dus kolommen en constraints die later bij de
+structs zijn gekomen ontbreken daar. Bij elke start voegen we die (idempotent) toe:
+- kolommen: elke kolom uit de struct die geen primary key is en NULL mag zijn (bijv. wijziging.entiteit_id,
+  wijziging.aanvang/einde/vorige_aanvang/vorige_einde en aanvang/einde van de gegevenselementen)
+- registratie.tijdstip: NOT NULL en de unique constraint registratie_tijdstip_key (de naam die Postgres geeft aan de
+  unique uit de bun tag). Een bestaande database met twee registraties op hetzelfde tijdstip geeft hier een fout;
+  herstel eerst de data.
+- wijziging.entiteit_id bij typen met een PFK: afgeleid uit de tabel van het type, voor wijzigingen die het nog niet
+  hebben. Een rel_id is alleen uniek binnen de bovenliggende entiteit, dus:
+  - eerst waar het rel_id bij precies één entiteit voorkomt
+  - dan waar precies één record met dat rel_id het tijdstip van de wijziging als opvoer (bij een opvoer)
+    of afvoer (bij een afvoer) heeft
+  Wat daarna nog dubbelzinnig is, blijft leeg en moet met de hand worden hersteld.
+- de ID kolom van een gegevenselement/relatie zonder PFK (bijv. rel_a_b.id): een sequence als default, gezet op
+  het hoogste bestaande ID. Een record zonder ID (bijv. de heropvoer bij een correctie) krijgt zo een nieuw ID
+  van de database. Een nieuwe tabel krijgt die sequence al via autoincrement (SERIAL).
+
+De tabellen worden dus niet meer weggegooid: dat zou het register wissen.
+*/
+
+// ontbrekendeKolommenSQL geeft per kolom van het model die NULL mag zijn een ADD COLUMN IF NOT EXISTS.
+// Kolommen die NOT NULL zijn (of een primary key) kunnen niet zonder waarde aan een gevulde tabel worden toegevoegd.
+func ontbrekendeKolommenSQL(db *bun.DB, dbModel any) []string {
+	typ := reflect.TypeOf(dbModel)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	tabel := db.Table(typ)
+
+	var sqls []string
+	for _, veld := range tabel.Fields {
+		if veld.IsPK || veld.NotNull || veld.SQLDefault != "" {
+			continue
+		}
+		sqls = append(sqls, fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "%s" %s`,
+			tabel.Name, veld.Name, veld.CreateTableSQLType))
+	}
+	return sqls
+}
+
+// RegisterOntbrekendeKolommen voegt de kolommen van het model toe die in een bestaande tabel nog ontbreken.
+func RegisterOntbrekendeKolommen(ctx context.Context, db *bun.DB, dbModel any) error {
+	return voerUit(ctx, db, ontbrekendeKolommenSQL(db, dbModel))
+}
+
+// entiteitIDAanvullenSQL vult wijziging.entiteit_id aan bij een type met een PFK, voor zover dat eenduidig kan.
+func entiteitIDAanvullenSQL(meta model.TypeMeta) []string {
+	if !meta.HeeftPFK || meta.EntiteitIDKolom == "" {
+		return nil
+	}
+
+	aanvullen := func(voorwaarde string) string {
+		return fmt.Sprintf(`
+        UPDATE "wijziging" AS w
+        SET entiteit_id = (SELECT MIN(t."%[3]s")::text FROM "%[2]s" AS t WHERE t."%[4]s"::text = w.representatie_id AND %[5]s)
+        WHERE w.representatienaam = '%[1]s' AND w.entiteit_id IS NULL
+            AND (SELECT COUNT(DISTINCT t."%[3]s") FROM "%[2]s" AS t WHERE t."%[4]s"::text = w.representatie_id AND %[5]s) = 1`,
+			meta.Typenaam, meta.Tabelnaam, meta.EntiteitIDKolom, meta.IDKolom, voorwaarde)
+	}
+
+	return []string{
+		aanvullen(`TRUE`),
+		aanvullen(`((w.wijzigingstype = 'opvoer' AND t.opvoer = w.tijdstip) OR (w.wijzigingstype = 'afvoer' AND t.afvoer = w.tijdstip))`),
+	}
+}
+
+// idSequenceSQL geeft de ID kolom van een gegevenselement/relatie zonder PFK een sequence als default.
+// Bij elke start wordt de sequence voorbij het hoogste bestaande ID gezet; een opvoer met een meegegeven ID
+// zet haar daarna zelf bij (zie handlers.synchroniseerIDSequence).
+func idSequenceSQL(meta model.TypeMeta) []string {
+	if !meta.HeeftIDSequence() {
+		return nil
+	}
+
+	sequence := meta.IDSequence()
+	return []string{
+		fmt.Sprintf(`CREATE SEQUENCE IF NOT EXISTS "%s" OWNED BY "%s"."%s"`, sequence, meta.Tabelnaam, meta.IDKolom),
+		fmt.Sprintf(`ALTER TABLE "%s" ALTER COLUMN "%s" SET DEFAULT nextval('%s')`, meta.Tabelnaam, meta.IDKolom, sequence),
+		fmt.Sprintf(`SELECT setval('%s', COALESCE(MAX("%s"), 0) + 1, false) FROM "%s"`, sequence, meta.IDKolom, meta.Tabelnaam),
+	}
+}
+
+// RegisterIDSequence geeft de ID kolom van een gegevenselement/relatie zonder PFK een sequence, zie idSequenceSQL.
+func RegisterIDSequence(ctx context.Context, db *bun.DB, meta model.TypeMeta) error {
+	return voerUit(ctx, db, idSequenceSQL(meta))
+}
+
+// coreMigratieSQL geeft de constraints en het aanvullen van gegevens in de core tabellen.
+// De core tabellen en de tabellen van de model representaties moeten dan al bestaan.
+func coreMigratieSQL() []string {
+	sqls := []string{
+		`ALTER TABLE "registratie" ALTER COLUMN "tijdstip" SET NOT NULL`,
+		voegConstraintToeSQL("registratie", "registratie_tijdstip_key", `UNIQUE ("tijdstip")`),
+	}
+	typenamen := make([]string, 0, len(model.MetaRegistry))
+	for typenaam := range model.MetaRegistry {
+		typenamen = append(typenamen, typenaam)
+	}
+	sort.Strings(typenamen)
+	for _, typenaam := range typenamen {
+		sqls = append(sqls, entiteitIDAanvullenSQL(model.MetaRegistry.MustTypeMeta(typenaam))...)
+	}
+	return sqls
+}
+
+// RegisterCoreMigratie werkt de core tabellen van een bestaande database bij.
+func RegisterCoreMigratie(ctx context.Context, db *bun.DB) error {
+	return voerUit(ctx, db, coreMigratieSQL())
+}
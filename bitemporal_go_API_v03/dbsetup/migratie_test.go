@@ -0,0 +1,129 @@
+package dbsetup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+func TestOntbrekendeKolommenSQL(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db := bun.NewDB(sqlDB, pgdialect.New())
+	defer db.Close()
+
+	t.Run("wijziging krijgt de later toegevoegde kolommen", func(t *testing.T) {
+		// Given: een bestaande wijziging tabel van vóór entiteit_id en de materiële kolommen.
+		// When: de ontbrekende kolommen worden bepaald.
+		// Then: elk van die kolommen met ADD COLUMN IF NOT EXISTS, de primary key niet.
+		sql := strings.Join(ontbrekendeKolommenSQL(db, (*model.Wijziging)(nil)), "\n")
+		for _, verwacht := range []string{
+			`ALTER TABLE "wijziging" ADD COLUMN IF NOT EXISTS "entiteit_id" VARCHAR`,
+			`ALTER TABLE "wijziging" ADD COLUMN IF NOT EXISTS "aanvang" TIMESTAMPTZ`,
+			`ALTER TABLE "wijziging" ADD COLUMN IF NOT EXISTS "vorige_einde" TIMESTAMPTZ`,
+		} {
+			if !strings.Contains(strings.ToUpper(sql), strings.ToUpper(verwacht)) {
+				t.Errorf("expected %s, got:\n%s", verwacht, sql)
+			}
+		}
+		if strings.Contains(sql, `"id"`) {
+			t.Errorf("expected no statement for the primary key, got:\n%s", sql)
+		}
+	})
+
+	t.Run("NOT NULL kolommen worden niet toegevoegd", func(t *testing.T) {
+		// Given: registratie.tijdstip is NOT NULL.
+		// When: de ontbrekende kolommen worden bepaald.
+		// Then: geen statement voor tijdstip (dat kan niet zonder waarde op een gevulde tabel).
+		sql := strings.Join(ontbrekendeKolommenSQL(db, (*model.Registratie)(nil)), "\n")
+		if strings.Contains(sql, `"tijdstip"`) {
+			t.Fatalf("expected no statement for tijdstip, got:\n%s", sql)
+		}
+	})
+}
+
+func TestEntiteitIDAanvullenSQL(t *testing.T) {
+	t.Run("type met PFK vult entiteit_id eerst via het rel_id en dan via het tijdstip aan", func(t *testing.T) {
+		// Given: A_U heeft de PFK (a_id, rel_id).
+		// When: het aanvullen van wijziging.entiteit_id wordt bepaald.
+		// Then: twee updates op de A_U wijzigingen zonder entiteit_id, alleen waar precies één a_id past.
+		sqls := entiteitIDAanvullenSQL(model.MetaRegistry.MustTypeMeta("A_U"))
+		if len(sqls) != 2 {
+			t.Fatalf("expected 2 statements, got %v", sqls)
+		}
+		for _, sql := range sqls {
+			for _, verwacht := range []string{
+				`SET entiteit_id = (SELECT MIN(t."a_id")::text FROM "a_u" AS t WHERE t."rel_id"::text = w.representatie_id`,
+				`WHERE w.representatienaam = 'A_U' AND w.entiteit_id IS NULL`,
+				`(SELECT COUNT(DISTINCT t."a_id") FROM "a_u" AS t`,
+			} {
+				if !strings.Contains(sql, verwacht) {
+					t.Errorf("expected %s in:\n%s", verwacht, sql)
+				}
+			}
+		}
+		if !strings.Contains(sqls[1], `w.wijzigingstype = 'opvoer' AND t.opvoer = w.tijdstip`) {
+			t.Errorf("expected the second statement to match on the tijdstip, got:\n%s", sqls[1])
+		}
+	})
+
+	t.Run("type zonder PFK hoeft niets aan te vullen", func(t *testing.T) {
+		// Given: Rel_A_B heeft een eigen id.
+		// When: het aanvullen van wijziging.entiteit_id wordt bepaald.
+		// Then: niets.
+		if sqls := entiteitIDAanvullenSQL(model.MetaRegistry.MustTypeMeta("Rel_A_B")); len(sqls) != 0 {
+			t.Fatalf("expected no statements, got %v", sqls)
+		}
+	})
+}
+
+func TestCoreMigratieSQL(t *testing.T) {
+	// Given: een bestaande registratie tabel van vóór de unique op tijdstip.
+	// When: de migratie van de core tabellen wordt bepaald.
+	// Then: tijdstip wordt NOT NULL en krijgt de unique constraint registratie_tijdstip_key.
+	sql := strings.Join(coreMigratieSQL(), "\n")
+	for _, verwacht := range []string{
+		`ALTER TABLE "registratie" ALTER COLUMN "tijdstip" SET NOT NULL`,
+		`ADD CONSTRAINT "registratie_tijdstip_key" UNIQUE ("tijdstip")`,
+		`w.representatienaam = 'B_X'`,
+	} {
+		if !strings.Contains(sql, verwacht) {
+			t.Errorf("expected %s in the migration SQL", verwacht)
+		}
+	}
+}
+
+func TestIDSequenceSQL(t *testing.T) {
+	t.Run("relatie zonder PFK krijgt een sequence op de ID kolom", func(t *testing.T) {
+		// Given: Rel_A_B heeft een eigen id.
+		// When: de sequence voor de ID kolom wordt bepaald.
+		// Then: een sequence als default, gezet voorbij het hoogste bestaande id.
+		sql := strings.Join(idSequenceSQL(model.MetaRegistry.MustTypeMeta("Rel_A_B")), "\n")
+		for _, verwacht := range []string{
+			`CREATE SEQUENCE IF NOT EXISTS "rel_a_b_id_seq" OWNED BY "rel_a_b"."id"`,
+			`ALTER TABLE "rel_a_b" ALTER COLUMN "id" SET DEFAULT nextval('rel_a_b_id_seq')`,
+			`SELECT setval('rel_a_b_id_seq', COALESCE(MAX("id"), 0) + 1, false) FROM "rel_a_b"`,
+		} {
+			if !strings.Contains(sql, verwacht) {
+				t.Errorf("expected %s in:\n%s", verwacht, sql)
+			}
+		}
+	})
+
+	t.Run("type met PFK of entiteit krijgt geen sequence", func(t *testing.T) {
+		// Given: A_U (relatief ID via de trigger) en A (ID van de client).
+		// When: de sequence voor de ID kolom wordt bepaald.
+		// Then: niets.
+		for _, typenaam := range []string{"A_U", "A"} {
+			if sqls := idSequenceSQL(model.MetaRegistry.MustTypeMeta(typenaam)); len(sqls) != 0 {
+				t.Errorf("expected no statements for %s, got %v", typenaam, sqls)
+			}
+		}
+	})
+}
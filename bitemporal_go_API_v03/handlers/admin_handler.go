@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
 	"os"
 
 	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/dbsetup"
 	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
 )
 
 const defaultAdminDropTablesPassword = "1234"
@@ -22,16 +25,23 @@ func getAdminDropTablesPassword() string {
 	return password
 }
 
-func DropTables(c *gin.Context) {
-	password := c.Param("password")
+// isAdminPasswordValid controleert het wachtwoord uit de route (:password); zo niet, dan volgt een 401.
+// Hetzelfde wachtwoord (ADMIN_DROP_PASSWORD) beveiligt alle admin routes die data lezen of wijzigen.
+func isAdminPasswordValid(c *gin.Context) bool {
+	if c.Param("password") != getAdminDropTablesPassword() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+		return false
+	}
+	return true
+}
 
+func DropTables(c *gin.Context) {
 	if !isDropTablesAllowed() {
 		c.JSON(http.StatusForbidden, gin.H{"error": "drop tables is disabled"})
 		return
 	}
 
-	if password != getAdminDropTablesPassword() {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password"})
+	if !isAdminPasswordValid(c) {
 		return
 	}
 
@@ -64,3 +74,58 @@ func CreateTables(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Tables created successfully (or they already existed)"})
 }
+
+// handler voor het herbouwen van de afgeleide kolommen uit het logboek (zie REPLAY in replay.go)
+// optioneel ?type=A&id=2 voor één entiteit; beveiligd met het admin wachtwoord, net als droptables
+func ReplayLogboek(c *gin.Context) {
+	if !isAdminPasswordValid(c) {
+		return
+	}
+
+	if DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var rapport ReplayRapport
+	err := DB.RunInTx(c.Request.Context(), nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rapport, err = SpeelLogboekAf(ctx, tx, c.Query("type"), c.Query("id"))
+		return err
+	})
+	if err != nil {
+		c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rapport)
+}
+
+// handler voor de consistentiecontrole van het register (zie CONSISTENTIE in consistentie.go)
+// geeft altijd 200 met het rapport; "consistent": false als er schendingen zijn
+// beveiligd met het admin wachtwoord, net als droptables
+func ControleerConsistentie(c *gin.Context) {
+	if !isAdminPasswordValid(c) {
+		return
+	}
+
+	if DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var rapport ConsistentieRapport
+	// één snapshot voor alle controles
+	opties := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := DB.RunInTx(c.Request.Context(), opties, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rapport, err = ControleerRegister(ctx, tx)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rapport)
+}
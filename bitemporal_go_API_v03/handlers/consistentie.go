@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== CONSISTENTIE ===========================
+
+ControleerRegister doorloopt het register en rapporteert schendingen van de integriteit.
+De controles worden afgeleid uit de MetaRegistry, zodat nieuwe typen automatisch meedoen:
+
+Per type:
+- afvoer_voor_opvoer: afvoer ligt voor opvoer
+- wijziging_zonder_representatie: een wijziging verwijst naar een RepresentatieID dat niet (meer) bestaat
+- representatie_zonder_opvoer_wijziging: een opgevoerde representatie zonder opvoer wijziging in het logboek
+
+Per entiteit en onderliggend gegevenselement/relatie (OnderliggendeGegevenselementen):
+- meerdere_actieve_enkelvoudig: meer dan één actief record voor een enkelvoudige rol per entiteit
+  (bij een materieel type: alleen als de periodes overlappen)
+- actief_onder_afgevoerde_entiteit: een actief gegevenselement/relatie onder een afgevoerde entiteit
+
+Per relatie met een secundaire entiteit (SecondaireEntiteittype):
+- relatie_naar_afgevoerde_entiteit: een actieve relatie naar een afgevoerde entiteit (bijv. Rel_A_B naar een afgevoerde B)
+
+De laatste twee vervallen bij afvoerbeleid detach (OnderliggendGegevenselement.Afvoerbeleid, SecondairAfvoerbeleid):
+dan blijft het record na afvoer van de entiteit bewust actief.
+
+Actief = opgevoerd en niet afgevoerd. Het rapport is JSON (GET /admin/consistentie/:password, met het admin wachtwoord van droptables, of: go run . controleer).
+*/
+
+// Namen van de controles in het rapport.
+const (
+	ControleAfvoerVoorOpvoer              = "afvoer_voor_opvoer"
+	ControleWijzigingZonderRepresentatie  = "wijziging_zonder_representatie"
+	ControleRepresentatieZonderOpvoer     = "representatie_zonder_opvoer_wijziging"
+	ControleMeerdereActieveEnkelvoudig    = "meerdere_actieve_enkelvoudig"
+	ControleActiefOnderAfgevoerdeEntiteit = "actief_onder_afgevoerde_entiteit"
+	ControleRelatieNaarAfgevoerdeEntiteit = "relatie_naar_afgevoerde_entiteit"
+)
+
+// ConsistentieSchending is één gevonden schending.
+type ConsistentieSchending struct {
+	Controle          string `json:"controle"`
+	Representatienaam string `json:"representatienaam"`
+	RepresentatieID   string `json:"representatie_id,omitempty"`
+	EntiteitID        string `json:"entiteit_id,omitempty"`
+	WijzigingID       int64  `json:"wijziging_id,omitempty"`
+	Melding           string `json:"melding"`
+}
+
+// ConsistentieRapport is het resultaat van ControleerRegister.
+type ConsistentieRapport struct {
+	Typen       []string                `json:"typen"`
+	Consistent  bool                    `json:"consistent"`
+	Schendingen []ConsistentieSchending `json:"schendingen"`
+}
+
+// consistentieRij is een representatie zoals de controles haar ophalen.
+type consistentieRij struct {
+	ID         string     `bun:"id"`
+	EntiteitID string     `bun:"entiteit_id"`
+	Aanvang    *time.Time `bun:"aanvang"`
+	Einde      *time.Time `bun:"einde"`
+}
+
+// consistentieControle voert de controles uit en verzamelt de schendingen.
+type consistentieControle struct {
+	ctx     context.Context
+	db      bun.IDB
+	rapport *ConsistentieRapport
+}
+
+// ControleerRegister voert alle controles uit voor alle typen in de MetaRegistry.
+func ControleerRegister(ctx context.Context, db bun.IDB) (ConsistentieRapport, error) {
+	rapport := ConsistentieRapport{Typen: []string{}, Schendingen: []ConsistentieSchending{}}
+	controle := consistentieControle{ctx: ctx, db: db, rapport: &rapport}
+
+	namen := make([]string, 0, len(model.MetaRegistry))
+	for naam := range model.MetaRegistry {
+		namen = append(namen, naam)
+	}
+	sort.Strings(namen)
+
+	for _, naam := range namen {
+		meta := model.MetaRegistry.MustTypeMeta(naam)
+		rapport.Typen = append(rapport.Typen, naam)
+
+		if err := controle.afvoerVoorOpvoer(meta); err != nil {
+			return ConsistentieRapport{}, err
+		}
+		if err := controle.wijzigingZonderRepresentatie(meta); err != nil {
+			return ConsistentieRapport{}, err
+		}
+		if err := controle.representatieZonderOpvoer(meta); err != nil {
+			return ConsistentieRapport{}, err
+		}
+
+		for _, onderliggend := range meta.OnderliggendeGegevenselementen {
+			doelMeta, err := metaVoorRolnaam(meta, onderliggend.Rolnaam)
+			if err != nil {
+				return ConsistentieRapport{}, err
+			}
+			if onderliggend.Momentvoorkomen == model.Enkelvoudig {
+				if err := controle.meerdereActieveEnkelvoudig(doelMeta); err != nil {
+					return ConsistentieRapport{}, err
+				}
+			}
+			// bij detach mogen ze actief blijven onder een afgevoerde entiteit
+			if onderliggend.Afvoerbeleid == model.AfvoerbeleidDetach {
+				continue
+			}
+			if err := controle.actiefOnderAfgevoerdeEntiteit(ControleActiefOnderAfgevoerdeEntiteit, meta, doelMeta, doelMeta.EntiteitIDKolom); err != nil {
+				return ConsistentieRapport{}, err
+			}
+		}
+
+		if meta.SecondaireEntiteittype != "" && meta.SecondairAfvoerbeleid != model.AfvoerbeleidDetach {
+			secundair, ok := model.MetaRegistry.GetTypeMeta(meta.SecondaireEntiteittype)
+			if !ok {
+				return ConsistentieRapport{}, fmt.Errorf("HANDLER: geen metadata voor %s (secundaire entiteit van %s)", meta.SecondaireEntiteittype, meta.Typenaam)
+			}
+			if err := controle.actiefOnderAfgevoerdeEntiteit(ControleRelatieNaarAfgevoerdeEntiteit, secundair, meta, meta.SecondaireEntiteitIDKolom); err != nil {
+				return ConsistentieRapport{}, err
+			}
+		}
+	}
+
+	rapport.Consistent = len(rapport.Schendingen) == 0
+	return rapport, nil
+}
+
+func (k consistentieControle) meld(schending ConsistentieSchending) {
+	k.rapport.Schendingen = append(k.rapport.Schendingen, schending)
+}
+
+// selecteerRijen begint een select op de tabel van een type (alias t) met id en, indien aanwezig, entiteit_id.
+func (k consistentieControle) selecteerRijen(meta model.TypeMeta) *bun.SelectQuery {
+	query := k.db.NewSelect().
+		TableExpr("? AS t", bun.Ident(meta.Tabelnaam)).
+		ColumnExpr("t.? AS id", bun.Ident(meta.IDKolom))
+	if meta.EntiteitIDKolom != "" {
+		query = query.ColumnExpr("t.? AS entiteit_id", bun.Ident(meta.EntiteitIDKolom))
+	}
+	return query.Order("id")
+}
+
+func (k consistentieControle) afvoerVoorOpvoer(meta model.TypeMeta) error {
+	var rijen []consistentieRij
+	err := k.selecteerRijen(meta).
+		Where("t.afvoer < t.opvoer").
+		Scan(k.ctx, &rijen)
+	if err != nil {
+		return fmt.Errorf("HANDLER: controle %s voor %s mislukt: %v", ControleAfvoerVoorOpvoer, meta.Typenaam, err)
+	}
+	for _, rij := range rijen {
+		k.meld(ConsistentieSchending{Controle: ControleAfvoerVoorOpvoer, Representatienaam: meta.Typenaam,
+			RepresentatieID: rij.ID, EntiteitID: rij.EntiteitID, Melding: "afvoer ligt voor opvoer"})
+	}
+	return nil
+}
+
+func (k consistentieControle) wijzigingZonderRepresentatie(meta model.TypeMeta) error {
+	// bij een PFK hoort de entiteit bij de sleutel
+	bestaat := "SELECT 1 FROM ? AS t WHERE CAST(t.? AS text) = ?TableAlias.representatie_id"
+	args := []any{bun.Ident(meta.Tabelnaam), bun.Ident(meta.IDKolom)}
+	if meta.HeeftPFK {
+		bestaat += " AND CAST(t.? AS text) = ?TableAlias.entiteit_id"
+		args = append(args, bun.Ident(meta.EntiteitIDKolom))
+	}
+
+	var wijzigingen []model.Wijziging
+	err := k.db.NewSelect().
+		Model(&wijzigingen).
+		Where("?TableAlias.representatienaam = ?", meta.Typenaam).
+		Where("NOT EXISTS ("+bestaat+")", args...).
+		Order("id").
+		Scan(k.ctx)
+	if err != nil {
+		return fmt.Errorf("HANDLER: controle %s voor %s mislukt: %v", ControleWijzigingZonderRepresentatie, meta.Typenaam, err)
+	}
+	for _, wijziging := range wijzigingen {
+		k.meld(ConsistentieSchending{Controle: ControleWijzigingZonderRepresentatie, Representatienaam: meta.Typenaam,
+			RepresentatieID: wijziging.RepresentatieID, EntiteitID: wijziging.EntiteitID, WijzigingID: wijziging.ID,
+			Melding: fmt.Sprintf("%s wijziging verwijst naar een %s die niet bestaat", wijziging.Wijzigingstype, meta.Typenaam)})
+	}
+	return nil
+}
+
+func (k consistentieControle) representatieZonderOpvoer(meta model.TypeMeta) error {
+	opvoer := "SELECT 1 FROM wijziging AS w WHERE w.representatienaam = ? AND w.wijzigingstype = ? AND w.representatie_id = CAST(t.? AS text)"
+	args := []any{meta.Typenaam, model.WijzigingstypeOpvoer, bun.Ident(meta.IDKolom)}
+	if meta.HeeftPFK {
+		opvoer += " AND w.entiteit_id = CAST(t.? AS text)"
+		args = append(args, bun.Ident(meta.EntiteitIDKolom))
+	}
+
+	var rijen []consistentieRij
+	err := k.selecteerRijen(meta).
+		Where("t.opvoer IS NOT NULL").
+		Where("NOT EXISTS ("+opvoer+")", args...).
+		Scan(k.ctx, &rijen)
+	if err != nil {
+		return fmt.Errorf("HANDLER: controle %s voor %s mislukt: %v", ControleRepresentatieZonderOpvoer, meta.Typenaam, err)
+	}
+	for _, rij := range rijen {
+		k.meld(ConsistentieSchending{Controle: ControleRepresentatieZonderOpvoer, Representatienaam: meta.Typenaam,
+			RepresentatieID: rij.ID, EntiteitID: rij.EntiteitID, Melding: "opgevoerd zonder opvoer wijziging in het logboek"})
+	}
+	return nil
+}
+
+// meerdereActieveEnkelvoudig haalt de actieve records op van entiteiten met meer dan één actief record
+// en meldt ze (bij een materieel type alleen als de periodes overlappen).
+func (k consistentieControle) meerdereActieveEnkelvoudig(meta model.TypeMeta) error {
+	var rijen []consistentieRij
+	err := k.selecteerRijen(meta).
+		Column("aanvang", "einde").
+		Where("t.opvoer IS NOT NULL AND t.afvoer IS NULL").
+		Where("t.? IN (SELECT ? FROM ? WHERE opvoer IS NOT NULL AND afvoer IS NULL GROUP BY ? HAVING COUNT(*) > 1)",
+			bun.Ident(meta.EntiteitIDKolom), bun.Ident(meta.EntiteitIDKolom), bun.Ident(meta.Tabelnaam), bun.Ident(meta.EntiteitIDKolom)).
+		Scan(k.ctx, &rijen)
+	if err != nil {
+		return fmt.Errorf("HANDLER: controle %s voor %s mislukt: %v", ControleMeerdereActieveEnkelvoudig, meta.Typenaam, err)
+	}
+
+	perEntiteit := map[string][]consistentieRij{}
+	var entiteiten []string
+	for _, rij := range rijen {
+		if _, ok := perEntiteit[rij.EntiteitID]; !ok {
+			entiteiten = append(entiteiten, rij.EntiteitID)
+		}
+		perEntiteit[rij.EntiteitID] = append(perEntiteit[rij.EntiteitID], rij)
+	}
+
+	for _, entiteitID := range entiteiten {
+		actief := perEntiteit[entiteitID]
+		for i := 0; i < len(actief); i++ {
+			for j := i + 1; j < len(actief); j++ {
+				if meta.IsMaterieel && !model.PeriodesOverlappen(actief[i].Aanvang, actief[i].Einde, actief[j].Aanvang, actief[j].Einde) {
+					continue
+				}
+				k.meld(ConsistentieSchending{Controle: ControleMeerdereActieveEnkelvoudig, Representatienaam: meta.Typenaam,
+					RepresentatieID: actief[j].ID, EntiteitID: entiteitID,
+					Melding: fmt.Sprintf("tegelijk actief met %s %s (enkelvoudig)", meta.Typenaam, actief[i].ID)})
+			}
+		}
+	}
+	return nil
+}
+
+// actiefOnderAfgevoerdeEntiteit meldt actieve records van meta waarvan de entiteit (via fkKolom) is afgevoerd.
+func (k consistentieControle) actiefOnderAfgevoerdeEntiteit(controle string, entiteitMeta model.TypeMeta, meta model.TypeMeta, fkKolom string) error {
+	var rijen []consistentieRij
+	err := k.db.NewSelect().
+		TableExpr("? AS t", bun.Ident(meta.Tabelnaam)).
+		ColumnExpr("t.? AS id", bun.Ident(meta.IDKolom)).
+		ColumnExpr("t.? AS entiteit_id", bun.Ident(fkKolom)).
+		Join("JOIN ? AS e ON e.? = t.?", bun.Ident(entiteitMeta.Tabelnaam), bun.Ident(entiteitMeta.IDKolom), bun.Ident(fkKolom)).
+		Where("t.opvoer IS NOT NULL AND t.afvoer IS NULL").
+		Where("e.afvoer IS NOT NULL").
+		Order("entiteit_id", "id").
+		Scan(k.ctx, &rijen)
+	if err != nil {
+		return fmt.Errorf("HANDLER: controle %s voor %s mislukt: %v", controle, meta.Typenaam, err)
+	}
+	for _, rij := range rijen {
+		k.meld(ConsistentieSchending{Controle: controle, Representatienaam: meta.Typenaam,
+			RepresentatieID: rij.ID, EntiteitID: rij.EntiteitID,
+			Melding: fmt.Sprintf("actief, maar %s %s is afgevoerd", entiteitMeta.Typenaam, rij.EntiteitID)})
+	}
+	return nil
+}
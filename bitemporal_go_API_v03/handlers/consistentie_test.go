@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+func TestConsistentieControles(t *testing.T) {
+	dag := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+
+	t.Run("reports only overlapping active records of a materieel enkelvoudig role", func(t *testing.T) {
+		// Given: B_X is (in deze test) materieel; B1 heeft drie actieve X-en: X1 [1-10), X2 [10-) sluit aan,
+		//        X3 [5-) overlapt met beide.
+		// When: de controle op meerdere actieve records draait voor B_X.
+		// Then: alleen X3 wordt gemeld (twee keer: tegen X1 en tegen X2).
+		ctx, tx, mock := nieuweMockTx(t)
+		metMaterieel(t, "B_X")
+		rapport := ConsistentieRapport{}
+		controle := consistentieControle{ctx: ctx.Request.Context(), db: tx, rapport: &rapport}
+
+		mock.ExpectQuery(`SELECT t."rel_id" AS id, t."b_id" AS entiteit_id, "aanvang", "einde" FROM "b_x" AS t WHERE \(t.opvoer IS NOT NULL AND t.afvoer IS NULL\) AND \(t."b_id" IN \(SELECT "b_id" FROM "b_x" .* GROUP BY "b_id" HAVING COUNT\(\*\) > 1\)\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "entiteit_id", "aanvang", "einde"}).
+				AddRow("1", "1", dag(1), dag(10)).
+				AddRow("2", "1", dag(10), nil).
+				AddRow("3", "1", dag(5), nil))
+
+		if err := controle.meerdereActieveEnkelvoudig(model.MetaRegistry.MustTypeMeta("B_X")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rapport.Schendingen) != 2 {
+			t.Fatalf("expected 2 violations, got %+v", rapport.Schendingen)
+		}
+		for _, schending := range rapport.Schendingen {
+			if schending.Controle != ControleMeerdereActieveEnkelvoudig || schending.RepresentatieID != "3" || schending.EntiteitID != "1" {
+				t.Fatalf("expected X3 of B1 to be reported, got %+v", schending)
+			}
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+
+	t.Run("reports active relations to an afgevoerde secondary entity", func(t *testing.T) {
+		// Given: relatie 4 is actief, maar B7 is afgevoerd.
+		// When: de controle op relaties naar afgevoerde entiteiten draait voor Rel_A_B.
+		// Then: relatie 4 wordt gemeld met het id van B.
+		ctx, tx, mock := nieuweMockTx(t)
+		rapport := ConsistentieRapport{}
+		controle := consistentieControle{ctx: ctx.Request.Context(), db: tx, rapport: &rapport}
+
+		mock.ExpectQuery(`FROM "rel_a_b" AS t JOIN "b" AS e ON e."id" = t."b_id" WHERE \(t.opvoer IS NOT NULL AND t.afvoer IS NULL\) AND \(e.afvoer IS NOT NULL\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "entiteit_id"}).AddRow("4", "7"))
+
+		relatie := model.MetaRegistry.MustTypeMeta("Rel_A_B")
+		secundair := model.MetaRegistry.MustTypeMeta(relatie.SecondaireEntiteittype)
+		err := controle.actiefOnderAfgevoerdeEntiteit(ControleRelatieNaarAfgevoerdeEntiteit, secundair, relatie, relatie.SecondaireEntiteitIDKolom)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rapport.Schendingen) != 1 || rapport.Schendingen[0].RepresentatieID != "4" || rapport.Schendingen[0].EntiteitID != "7" {
+			t.Fatalf("expected relation 4 to B7 to be reported, got %+v", rapport.Schendingen)
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+
+	t.Run("reports wijzigingen without a representation", func(t *testing.T) {
+		// Given: een afvoer wijziging van A 9, terwijl A 9 niet bestaat.
+		// When: de controle op wijzigingen zonder representatie draait voor A.
+		// Then: de wijziging wordt gemeld met haar id.
+		ctx, tx, mock := nieuweMockTx(t)
+		rapport := ConsistentieRapport{}
+		controle := consistentieControle{ctx: ctx.Request.Context(), db: tx, rapport: &rapport}
+
+		mock.ExpectQuery(`FROM "wijziging" WHERE \("wijziging".representatienaam = 'A'\) AND \(NOT EXISTS \(SELECT 1 FROM "a" AS t WHERE CAST\(t."id" AS text\) = "wijziging".representatie_id\)\)`).
+			WillReturnRows(sqlmock.NewRows(wijzigingKolommen).AddRow(12, "afvoer", 3, "A", nil, "9", dag(2)))
+
+		if err := controle.wijzigingZonderRepresentatie(model.MetaRegistry.MustTypeMeta("A")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rapport.Schendingen) != 1 || rapport.Schendingen[0].WijzigingID != 12 || rapport.Schendingen[0].RepresentatieID != "9" {
+			t.Fatalf("expected wijziging 12 to be reported, got %+v", rapport.Schendingen)
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+}
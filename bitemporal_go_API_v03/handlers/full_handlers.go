@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
 	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
 )
 
 /* GENERAL TODO:
@@ -152,22 +155,41 @@ func MakeGetFullEntitiesHandler[T any](entity_name string, relation_names []stri
 
 		offset := (page - 1) * size
 
+		peil, err := leesPeilmoment(c)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+
 		var entities []T
 		query := DB.NewSelect().Model(&entities)
+		var entiteitIDs []string
+		if peil.isGezet() {
+			// eerst de pagina van de op het peilmoment geldige entiteiten, dan alleen hun logboek, zie full_handlers_peilmoment.go
+			entiteitIDs, err = haalGeldigeEntiteitIDsUitDB(c, new(T), peil, offset, size)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			query = query.Where("?TableAlias.id IN (?)", bun.In(entiteitIDs)).OrderExpr("?TableAlias.id ASC")
+		} else {
+			query = query.Limit(size).Offset(offset)
+		}
 
-		// Voeg alle relaties toe
-		for _, relation_name := range relation_names {
-			query = query.Relation(relation_name)
+		filter, err := nieuwPeilfilter(c, new(T), relation_names, peil, entiteitIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
-		err := query.
-			Limit(size).
-			Offset(offset).
+		// Voeg alle relaties toe (gefilterd op het peilmoment, indien opgegeven)
+		err = filter.voegRelatiesToe(query, relation_names).
 			Scan(c.Request.Context())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		filter.pasToe(&entities, relation_names)
 
 		hasMore := len(entities) == size
 
@@ -189,17 +211,28 @@ func MakeGetFullEntityHandler[T model.HasID](entity_name string, relation_names
 			return
 		}
 
-		var entity T
-		query := DB.NewSelect().Model(&entity)
+		peil, err := leesPeilmoment(c)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
 
-		// Voeg alle relaties toe
-		for _, relation_name := range relation_names {
-			query = query.Relation(relation_name)
+		var entity T
+		filter, err := nieuwPeilfilter(c, &entity, relation_names, peil, []string{entityID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
-		err := query.
-			Where("id = ?", entityID).
+		// Voeg alle relaties toe (gefilterd op het peilmoment, indien opgegeven)
+		err = filter.voegRelatiesToe(DB.NewSelect().Model(&entity), relation_names).
+			Where("?TableAlias.id = ?", entityID).
 			Scan(c.Request.Context())
+		if errors.Is(err, sql.ErrNoRows) {
+			// bestaat niet, of is niet geldig op het peilmoment
+			c.JSON(http.StatusNotFound, gin.H{"message": entity_name + " not found"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -209,6 +242,15 @@ func MakeGetFullEntityHandler[T model.HasID](entity_name string, relation_names
 			c.JSON(http.StatusNotFound, gin.H{"message": entity_name + " not found"})
 			return
 		}
+		filter.pasToe(&entity, relation_names)
+
+		// ETag: de huidige versie van de entiteit (alleen zonder peilmoment), zie registration_helpers_versie.go
+		if !peil.isGezet() {
+			if err := zetETag(c, representatieCode(&entity), entity.GetID()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
 		c.JSON(http.StatusOK, entity)
 	}
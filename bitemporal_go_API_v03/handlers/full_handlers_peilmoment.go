@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== PEILMOMENT ===========================
+
+De full endpoints (/full/as, /full/as/:id, /full/bs, /full/bs/:id) geven zonder parameters alles terug wat ooit
+is geregistreerd. Met een peilmoment geven ze alleen wat op dat moment geldig is:
+
+- ?peiltijdstip=2026-01-01T10:00:00Z  (formeel)   opvoer <= t < afvoer (afvoer leeg = nog niet afgevoerd)
+- ?peildatum=2026-01-01               (materieel) aanvang <= d < einde (leeg = onbegrensd), alleen voor materiële typen
+
+Alleen peildatum opgeven betekent: zoals nu bekend (peiltijdstip = nu).
+Het filter geldt voor de entiteit én voor haar onderliggende gegevenselementen/relaties;
+welk type bij een relatie hoort, komt uit de MetaRegistry (Rolnaam -> Doeltype).
+
+De kolommen opvoer/afvoer geven de huidige kennis weer: na een ongedaanmaking is er niets meer van te zien.
+Daarom leiden we de geldigheid af uit het logboek (wijziging + registratie, zie model.LeidGeldigheidAf):
+registraties die op het peiltijdstip ongedaan gemaakt waren tellen niet mee, een latere ongedaanmaking nog wel.
+In het antwoord staan opvoer/afvoer (en aanvang/einde) zoals ze op het peiltijdstip bekend waren.
+Rijen zonder wijziging records (bijv. via de plain of full POST endpoints ingevoerd) hebben geen geldigheid
+in het logboek en vallen dus buiten het filter.
+Dit is dezelfde logica als "nogw met peiltijdstip" en de view "niet_ongedaan_gemaakte_wijziging" in de HRv4 SQL scripts.
+*/
+
+// peilmoment is het (optionele) moment waarop de full endpoints filteren.
+type peilmoment struct {
+	Peiltijdstip *time.Time // formele tijd
+	Peildatum    *time.Time // materiële tijd
+}
+
+func (p peilmoment) isGezet() bool {
+	return p.Peiltijdstip != nil || p.Peildatum != nil
+}
+
+// leesPeilmoment leest ?peiltijdstip= en ?peildatum= uit de request.
+func leesPeilmoment(c *gin.Context) (peilmoment, error) {
+	var p peilmoment
+
+	peiltijdstip, err := leesTijdstipParameter(c, "peiltijdstip")
+	if err != nil {
+		return peilmoment{}, err
+	}
+	p.Peiltijdstip = peiltijdstip
+
+	if waarde := c.Query("peildatum"); waarde != "" {
+		datum, err := time.Parse(time.DateOnly, waarde)
+		if err != nil {
+			datum, err = time.Parse(time.RFC3339Nano, waarde)
+		}
+		if err != nil {
+			return peilmoment{}, nieuweValidatieFout(http.StatusBadRequest,
+				"ongeldige 'peildatum' %q: verwacht een datum (2026-01-01) of RFC3339", waarde)
+		}
+		p.Peildatum = &datum
+		if p.Peiltijdstip == nil {
+			nu := time.Now().UTC()
+			p.Peiltijdstip = &nu
+		}
+	}
+
+	return p, nil
+}
+
+// leesTijdstipParameter leest een (optioneel) formeel tijdstip uit een query parameter.
+func leesTijdstipParameter(c *gin.Context, naam string) (*time.Time, error) {
+	waarde := c.Query(naam)
+	if waarde == "" {
+		return nil, nil
+	}
+	tijdstip, err := time.Parse(time.RFC3339Nano, waarde)
+	if err != nil {
+		return nil, nieuweValidatieFout(http.StatusBadRequest,
+			"ongeldig '%s' %q: verwacht RFC3339, bijv. 2026-01-01T10:00:00Z", naam, waarde)
+	}
+	return &tijdstip, nil
+}
+
+// peilfilter filtert de full endpoints op een peilmoment, met de geldigheid zoals afgeleid uit het logboek.
+// Een nil peilfilter filtert niets.
+type peilfilter struct {
+	peil        peilmoment
+	meta        model.TypeMeta
+	relatieMeta map[string]model.TypeMeta
+	afgeleid    map[model.RepresentatieSleutel]model.AfgeleideGeldigheid
+}
+
+// nieuwPeilfilter leidt voor de entiteiten met de gegeven ids en hun relaties de geldigheid op het peilmoment af.
+// Alleen het logboek van die entiteiten wordt gelezen. Zonder peilmoment geeft het nil.
+func nieuwPeilfilter(c *gin.Context, entiteit any, relation_names []string, p peilmoment, entiteitIDs []string) (*peilfilter, error) {
+	if !p.isGezet() {
+		return nil, nil
+	}
+
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatieCode(entiteit))
+	if !ok {
+		return nil, fmt.Errorf("HANDLER: geen metadata voor %T", entiteit)
+	}
+
+	filter := &peilfilter{peil: p, meta: meta, relatieMeta: map[string]model.TypeMeta{}}
+	for _, relation_name := range relation_names {
+		doelMeta, err := metaVoorRolnaam(meta, relation_name)
+		if err != nil {
+			return nil, err
+		}
+		filter.relatieMeta[relation_name] = doelMeta
+	}
+
+	afgeleid, err := haalAfgeleideGeldigheidUitDB(c, meta, filter.relatieMeta, entiteitIDs, *p.Peiltijdstip)
+	if err != nil {
+		return nil, err
+	}
+	filter.afgeleid = afgeleid
+
+	return filter, nil
+}
+
+// haalAfgeleideGeldigheidUitDB haalt de wijzigingen van de entiteiten en hun relaties op, met de ongedaanmakingen
+// die (via een keten) die registraties raken, en leidt daaruit de geldigheid op het peiltijdstip af.
+// Materiële wijzigingen na het peiltijdstip zijn nodig voor de aanvang/einde van toen (hun vorige waarden).
+func haalAfgeleideGeldigheidUitDB(c *gin.Context, meta model.TypeMeta, relatieMeta map[string]model.TypeMeta,
+	entiteitIDs []string, peiltijdstip time.Time) (map[model.RepresentatieSleutel]model.AfgeleideGeldigheid, error) {
+	if len(entiteitIDs) == 0 {
+		return map[model.RepresentatieSleutel]model.AfgeleideGeldigheid{}, nil
+	}
+
+	var wijzigingen []model.Wijziging
+	err := DB.NewSelect().
+		Model(&wijzigingen).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return waarWijzigingenVanEntiteiten(q, meta, relatieMeta, entiteitIDs)
+		}).
+		Where("tijdstip <= ? OR wijzigingstype = ?", peiltijdstip, model.WijzigingstypeMaterieel).
+		Order("tijdstip", "id").
+		Scan(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon wijzigingen van %s %v niet ophalen: %v", meta.Typenaam, entiteitIDs, err)
+	}
+
+	registratieIDs := make([]int64, 0, len(wijzigingen))
+	for _, wijziging := range wijzigingen {
+		registratieIDs = append(registratieIDs, wijziging.RegistratieID)
+	}
+	ongedaanmakingen, err := haalOngedaanmakingsketensUitDB(c, registratieIDs, &peiltijdstip)
+	if err != nil {
+		return nil, err
+	}
+
+	afgeleid, err := model.LeidGeldigheidAf(wijzigingen, ongedaanmakingen, peiltijdstip)
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon geldigheid op %s niet afleiden: %v", peiltijdstip.Format(time.RFC3339Nano), err)
+	}
+
+	return afgeleid, nil
+}
+
+// waarWijzigingenVanEntiteiten beperkt een query op wijziging tot de entiteiten en hun relaties:
+// de entiteit via representatie_id, een relatie met een PFK via entiteit_id,
+// en een relatie met een eigen id via de FK naar de entiteit in haar eigen tabel.
+func waarWijzigingenVanEntiteiten(q *bun.SelectQuery, meta model.TypeMeta, relatieMeta map[string]model.TypeMeta, entiteitIDs []string) *bun.SelectQuery {
+	q = q.WhereOr("representatienaam = ? AND representatie_id IN (?)", meta.Typenaam, bun.In(entiteitIDs))
+
+	rolnamen := make([]string, 0, len(relatieMeta))
+	for rolnaam := range relatieMeta {
+		rolnamen = append(rolnamen, rolnaam)
+	}
+	sort.Strings(rolnamen)
+	for _, rolnaam := range rolnamen {
+		doelMeta := relatieMeta[rolnaam]
+		if doelMeta.HeeftPFK {
+			q = q.WhereOr("representatienaam = ? AND entiteit_id IN (?)", doelMeta.Typenaam, bun.In(entiteitIDs))
+			continue
+		}
+		q = q.WhereOr("representatienaam = ? AND representatie_id IN (SELECT CAST(? AS text) FROM ? WHERE ? IN (?))",
+			doelMeta.Typenaam, bun.Ident(doelMeta.IDKolom), bun.Ident(doelMeta.Tabelnaam),
+			bun.Ident(doelMeta.EntiteitIDKolom), bun.In(entiteitIDs))
+	}
+	return q
+}
+
+// haalOngedaanmakingsketensUitDB haalt de ongedaanmakingen (tot en met het peiltijdstip, of alle bij nil) op die de
+// registraties ongedaan maken, en die op hun beurt die ongedaanmakingen ongedaan maken, enzovoort.
+func haalOngedaanmakingsketensUitDB(c *gin.Context, registratieIDs []int64, peiltijdstip *time.Time) ([]model.Registratie, error) {
+	var ongedaanmakingen []model.Registratie
+	bekend := map[int64]bool{}
+	teZoeken := make([]int64, 0, len(registratieIDs))
+	for _, id := range registratieIDs {
+		if !bekend[id] {
+			bekend[id] = true
+			teZoeken = append(teZoeken, id)
+		}
+	}
+
+	for len(teZoeken) > 0 {
+		var gevonden []model.Registratie
+		query := DB.NewSelect().
+			Model(&gevonden).
+			Where("registratietype = ?", model.RegistratietypeOngedaanmaking).
+			Where("maakt_ongedaan_registratie_id IN (?)", bun.In(teZoeken))
+		if peiltijdstip != nil {
+			query = query.Where("tijdstip <= ?", *peiltijdstip)
+		}
+		err := query.
+			Order("id").
+			Scan(c.Request.Context())
+		if err != nil {
+			return nil, fmt.Errorf("HANDLER: kon ongedaanmakingen niet ophalen: %v", err)
+		}
+
+		teZoeken = teZoeken[:0]
+		for _, ongedaanmaking := range gevonden {
+			if bekend[ongedaanmaking.ID] {
+				continue
+			}
+			bekend[ongedaanmaking.ID] = true
+			ongedaanmakingen = append(ongedaanmakingen, ongedaanmaking)
+			teZoeken = append(teZoeken, ongedaanmaking.ID)
+		}
+	}
+
+	return ongedaanmakingen, nil
+}
+
+// peilBatchgrootte is het aantal entiteiten waarvan de lijst endpoints per keer de geldigheid afleiden.
+const peilBatchgrootte = 500
+
+// haalGeldigeEntiteitIDsUitDB geeft de ids (oplopend) van de entiteiten van het type die op het peilmoment geldig zijn:
+// hooguit size, na de eerste offset. De geldigheid wordt per batch van entiteiten afgeleid,
+// zodat alleen het logboek van die entiteiten wordt gelezen en niet dat van het hele type.
+func haalGeldigeEntiteitIDsUitDB(c *gin.Context, entiteit any, p peilmoment, offset int, size int) ([]string, error) {
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatieCode(entiteit))
+	if !ok {
+		return nil, fmt.Errorf("HANDLER: geen metadata voor %T", entiteit)
+	}
+
+	var geldig []string
+	var vanaf *string
+	for len(geldig) < offset+size {
+		var batch []string
+		query := DB.NewSelect().
+			Model(entiteit).
+			Column(meta.IDKolom).
+			OrderExpr("?TableAlias.? ASC", bun.Ident(meta.IDKolom)).
+			Limit(peilBatchgrootte)
+		if vanaf != nil {
+			query = query.Where("?TableAlias.? > ?", bun.Ident(meta.IDKolom), *vanaf)
+		}
+		if err := query.Scan(c.Request.Context(), &batch); err != nil {
+			return nil, fmt.Errorf("HANDLER: kon %s niet ophalen: %v", meta.Typenaam, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		vanaf = &batch[len(batch)-1]
+
+		filter, err := nieuwPeilfilter(c, entiteit, nil, p, batch)
+		if err != nil {
+			return nil, err
+		}
+		var ids []string
+		err = DB.NewSelect().
+			Model(entiteit).
+			Column(meta.IDKolom).
+			Where("?TableAlias.? IN (?)", bun.Ident(meta.IDKolom), bun.In(batch)).
+			Apply(filter.geldigOp(meta)).
+			OrderExpr("?TableAlias.? ASC", bun.Ident(meta.IDKolom)).
+			Scan(c.Request.Context(), &ids)
+		if err != nil {
+			return nil, fmt.Errorf("HANDLER: kon geldige %s niet bepalen: %v", meta.Typenaam, err)
+		}
+		geldig = append(geldig, ids...)
+
+		if len(batch) < peilBatchgrootte {
+			break
+		}
+	}
+
+	if offset >= len(geldig) {
+		return []string{}, nil
+	}
+	return geldig[offset:min(offset+size, len(geldig))], nil
+}
+
+// voegRelatiesToe voegt de relaties toe aan de query en beperkt entiteit en relaties tot wat op het peilmoment geldig is.
+func (f *peilfilter) voegRelatiesToe(query *bun.SelectQuery, relation_names []string) *bun.SelectQuery {
+	if f == nil {
+		for _, relation_name := range relation_names {
+			query = query.Relation(relation_name)
+		}
+		return query
+	}
+
+	query = query.Apply(f.geldigOp(f.meta))
+	for _, relation_name := range relation_names {
+		query = query.Relation(relation_name, f.geldigOp(f.relatieMeta[relation_name]))
+	}
+	return query
+}
+
+// geldigOp beperkt een select query tot de rijen van een type die op het peilmoment geldig zijn.
+// Formeel volgt dat helemaal uit het logboek. Materieel (alleen voor materiële typen) uit de afgeleide
+// aanvang/einde als die ooit gewijzigd zijn, anders uit de kolommen (die zijn dan sinds de opvoer gelijk).
+func (f *peilfilter) geldigOp(meta model.TypeMeta) func(*bun.SelectQuery) *bun.SelectQuery {
+	var geldigeSleutels, kolommenBepalenSleutels []model.RepresentatieSleutel
+	for sleutel, geldigheid := range f.afgeleid {
+		if sleutel.Representatienaam != meta.Typenaam || !geldigheid.IsGeldigOp(*f.peil.Peiltijdstip) {
+			continue
+		}
+		switch {
+		case f.peil.Peildatum == nil || !meta.IsMaterieel:
+			geldigeSleutels = append(geldigeSleutels, sleutel)
+		case !geldigheid.MaterieelBekend:
+			kolommenBepalenSleutels = append(kolommenBepalenSleutels, sleutel)
+		case model.IsMaterieelGeldigOp(geldigheid.Aanvang, geldigheid.Einde, *f.peil.Peildatum):
+			geldigeSleutels = append(geldigeSleutels, sleutel)
+		}
+	}
+
+	// bij een PFK is het ID alleen binnen de entiteit uniek: dan filteren we op (entiteit id, id)
+	kolom := "?TableAlias." + meta.IDKolom
+	if meta.HeeftPFK {
+		kolom = "(?TableAlias." + meta.EntiteitIDKolom + ", ?TableAlias." + meta.IDKolom + ")"
+	}
+	geldig := sleutelwaarden(meta, geldigeSleutels)
+	kolommenBepalen := sleutelwaarden(meta, kolommenBepalenSleutels)
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if len(kolommenBepalen) == 0 {
+			return q.Where(kolom+" IN (?)", bun.In(geldig))
+		}
+		return q.Where(kolom+" IN (?) OR ("+kolom+" IN (?)"+
+			" AND (?TableAlias.aanvang IS NULL OR ?TableAlias.aanvang <= ?)"+
+			" AND (?TableAlias.einde IS NULL OR ?TableAlias.einde > ?))",
+			bun.In(geldig), bun.In(kolommenBepalen), *f.peil.Peildatum, *f.peil.Peildatum)
+	}
+}
+
+// sleutelwaarden zet sleutels (gesorteerd) om naar de waarden voor een IN filter:
+// het ID, of bij een PFK het paar (entiteit id, id).
+func sleutelwaarden(meta model.TypeMeta, sleutels []model.RepresentatieSleutel) []any {
+	sort.Slice(sleutels, func(i, j int) bool {
+		if sleutels[i].EntiteitID != sleutels[j].EntiteitID {
+			return sleutels[i].EntiteitID < sleutels[j].EntiteitID
+		}
+		return sleutels[i].RepresentatieID < sleutels[j].RepresentatieID
+	})
+
+	waarden := make([]any, 0, len(sleutels))
+	for _, sleutel := range sleutels {
+		if meta.HeeftPFK {
+			waarden = append(waarden, []string{sleutel.EntiteitID, sleutel.RepresentatieID})
+		} else {
+			waarden = append(waarden, sleutel.RepresentatieID)
+		}
+	}
+	return waarden
+}
+
+// pasToe zet in de opgehaalde entiteit(en) en hun relaties de tijdstippen zoals ze op het peiltijdstip bekend waren.
+// entiteiten is een pointer naar een entiteit of naar een slice van entiteiten.
+func (f *peilfilter) pasToe(entiteiten any, relation_names []string) {
+	if f == nil {
+		return
+	}
+
+	waarde := reflect.Indirect(reflect.ValueOf(entiteiten))
+	if waarde.Kind() != reflect.Slice {
+		f.pasToeOpEntiteit(waarde, relation_names)
+		return
+	}
+	for i := 0; i < waarde.Len(); i++ {
+		f.pasToeOpEntiteit(waarde.Index(i), relation_names)
+	}
+}
+
+func (f *peilfilter) pasToeOpEntiteit(entiteit reflect.Value, relation_names []string) {
+	f.zetAfgeleideTijdstippen(f.meta, entiteit)
+	for _, relation_name := range relation_names {
+		relatie := entiteit.FieldByName(relation_name)
+		if relatie.Kind() != reflect.Slice {
+			continue
+		}
+		for i := 0; i < relatie.Len(); i++ {
+			f.zetAfgeleideTijdstippen(f.relatieMeta[relation_name], relatie.Index(i))
+		}
+	}
+}
+
+// zetAfgeleideTijdstippen overschrijft opvoer/afvoer (en zo nodig aanvang/einde) met de afgeleide waarden.
+func (f *peilfilter) zetAfgeleideTijdstippen(meta model.TypeMeta, waarde reflect.Value) {
+	if !waarde.CanAddr() {
+		return
+	}
+	representatie, ok := waarde.Addr().Interface().(model.HasID)
+	if !ok {
+		return
+	}
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
+		return
+	}
+	geldigheid := f.afgeleid[sleutel]
+
+	if formeel, ok := representatie.(model.HeeftOpvoerAfvoer); ok {
+		formeel.SetOpvoer(geldigheid.Opvoer)
+		formeel.SetAfvoer(geldigheid.Afvoer)
+	}
+	if materieel, ok := representatie.(model.HeeftAanvangEinde); ok && geldigheid.MaterieelBekend {
+		materieel.SetAanvang(geldigheid.Aanvang)
+		materieel.SetEinde(geldigheid.Einde)
+	}
+}
+
+// metaVoorRolnaam zoekt het type achter een relatie (Rolnaam) van een entiteit op in de MetaRegistry.
+func metaVoorRolnaam(entiteitMeta model.TypeMeta, rolnaam string) (model.TypeMeta, error) {
+	for _, onderliggend := range entiteitMeta.OnderliggendeGegevenselementen {
+		if onderliggend.Rolnaam != rolnaam {
+			continue
+		}
+		doelMeta, ok := model.MetaRegistry.GetTypeMeta(onderliggend.Doeltype)
+		if !ok {
+			return model.TypeMeta{}, fmt.Errorf("HANDLER: geen metadata voor %s (rol %s van %s)", onderliggend.Doeltype, rolnaam, entiteitMeta.Typenaam)
+		}
+		return doelMeta, nil
+	}
+
+	return model.TypeMeta{}, fmt.Errorf("HANDLER: %s heeft geen rol %s in de MetaRegistry", entiteitMeta.Typenaam, rolnaam)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLeesPeilmoment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("peildatum alone implies peiltijdstip now", func(t *testing.T) {
+		// Given: alleen een peildatum.
+		// When: het peilmoment wordt gelezen.
+		// Then: de peildatum is gezet en het peiltijdstip is (ongeveer) nu.
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/full/as?peildatum=2026-03-01", nil)
+
+		voor := time.Now()
+		p, err := leesPeilmoment(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.Peildatum == nil || !p.Peildatum.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("expected peildatum 2026-03-01, got %v", p.Peildatum)
+		}
+		if p.Peiltijdstip == nil || p.Peiltijdstip.Before(voor) {
+			t.Fatalf("expected peiltijdstip now, got %v", p.Peiltijdstip)
+		}
+	})
+
+	t.Run("rejects invalid peiltijdstip", func(t *testing.T) {
+		// Given: een peiltijdstip dat geen RFC3339 is.
+		// When: het peilmoment wordt gelezen.
+		// Then: er volgt een 400.
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/full/as?peiltijdstip=gisteren", nil)
+
+		_, err := leesPeilmoment(ctx)
+		if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusBadRequest {
+			t.Fatalf("expected 400 validation error, got %v", err)
+		}
+	})
+}
+
+func TestMakeGetFullEntityHandler_FiltertOpPeilmoment(t *testing.T) {
+	// Given: A2 (opgevoerd 07:00) met U1 (opgevoerd 07:00); U1 is om 08:00 afgevoerd,
+	//        maar die registratie is om 09:00 ongedaan gemaakt. Er zijn geen V's of relaties.
+	// When: /full/as/2 wordt opgevraagd op 10:00 met een peildatum.
+	// Then: alleen het logboek van A2 en haar relaties en de keten van ongedaanmakingen van die registraties wordt gelezen;
+	//       A2 en U1 zijn geldig (U1 zonder afvoer); alleen materiële typen krijgen een materieel filter.
+	mock := nieuweMockDB(t)
+
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+	peil := `'2026-01-01 10:00:00\+00:00'`
+	datum := `'2026-03-01 00:00:00\+00:00'`
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(\(representatienaam = 'A' AND representatie_id IN \('2'\)\) ` +
+		`OR \(representatienaam = 'Rel_A_B' AND representatie_id IN \(SELECT CAST\("id" AS text\) FROM "rel_a_b" WHERE "a_id" IN \('2'\)\)\) ` +
+		`OR \(representatienaam = 'A_U' AND entiteit_id IN \('2'\)\) OR \(representatienaam = 'A_V' AND entiteit_id IN \('2'\)\)\) ` +
+		`AND \(tijdstip <= ` + peil + ` OR wijzigingstype = 'materieel'\) ORDER BY "tijdstip", "id"`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(1, "opvoer", 1, "A", nil, "2", uur(7)).
+			AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(7)).
+			AddRow(3, "afvoer", 2, "A_U", "2", "1", uur(8)))
+	mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\) ` +
+		`AND \(maakt_ongedaan_registratie_id IN \(1, 2\)\) AND \(tijdstip <= ` + peil + `\) ORDER BY "id"`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(3, "ongedaanmaking", uur(9), nil, nil, 2))
+	mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\) ` +
+		`AND \(maakt_ongedaan_registratie_id IN \(3\)\) AND \(tijdstip <= ` + peil + `\) ORDER BY "id"`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "a" WHERE \("a"\.id IN \(NULL\) OR \("a"\.id IN \('2'\) ` +
+		`AND \("a"\.aanvang IS NULL OR "a"\.aanvang <= ` + datum + `\) AND \("a"\.einde IS NULL OR "a"\.einde > ` + datum + `\)\)\) AND \("a"\.id = '2'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(2, uur(7)))
+	mock.ExpectQuery(`SELECT .*FROM "a_u" WHERE \("a_u"\."a_id" IN \(2\)\) AND \(\("a_u"\.a_id, "a_u"\.rel_id\) IN \(\('2', '1'\)\)\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id", "opvoer", "afvoer"}).AddRow(2, 1, uur(7), uur(8)))
+	mock.ExpectQuery(`SELECT .*FROM "a_v" WHERE \("a_v"\."a_id" IN \(2\)\) AND \(\("a_v"\.a_id, "a_v"\.rel_id\) IN \(NULL\)\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+	mock.ExpectQuery(`SELECT .*FROM "rel_a_b" WHERE \("rel_a_b"\."a_id" IN \(2\)\) AND \("rel_a_b"\.id IN \(NULL\)\)$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "a_id"}))
+
+	router := gin.New()
+	router.GET("/full/as/:id", MakeGetFullEntityHandler[model.Full_A]("A", []string{"Us", "Vs", "RelABs"}))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+		"/full/as/2?peiltijdstip=2026-01-01T10:00:00Z&peildatum=2026-03-01", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+
+	var a model.Full_A
+	if err := json.Unmarshal(recorder.Body.Bytes(), &a); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(a.Us) != 1 || a.Us[0].Afvoer != nil {
+		t.Fatalf("expected U1 without afvoer (afvoer was undone), got %+v", a.Us)
+	}
+}
+
+func TestMakeGetFullEntitiesHandler_PagineertOpGeldigeEntiteiten(t *testing.T) {
+	// Given: A1 en A3 zijn om 07:00 opgevoerd, A2 pas om 11:00.
+	// When: /full/as wordt opgevraagd op 10:00, pagina 2 met 1 entiteit per pagina.
+	// Then: de geldigheid wordt per batch van entiteiten uit hun eigen logboek afgeleid (niet uit dat van het hele type),
+	//       A2 telt niet mee voor de paginering en pagina 2 is A3.
+	mock := nieuweMockDB(t)
+
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+	peil := `'2026-01-01 10:00:00\+00:00'`
+
+	mock.ExpectQuery(`SELECT "a"\."id" FROM "a" ORDER BY "a"\."id" ASC LIMIT 500$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("2").AddRow("3"))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(\(representatienaam = 'A' AND representatie_id IN \('1', '2', '3'\)\)\) ` +
+		`AND \(tijdstip <= ` + peil + ` OR wijzigingstype = 'materieel'\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(1, "opvoer", 1, "A", nil, "1", uur(7)).
+			AddRow(2, "opvoer", 1, "A", nil, "3", uur(7)))
+	mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE .*maakt_ongedaan_registratie_id IN \(1\)`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT "a"\."id" FROM "a" WHERE \("a"\."id" IN \('1', '2', '3'\)\) AND \("a"\.id IN \('1', '3'\)\) ORDER BY "a"\."id" ASC$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("3"))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(\(representatienaam = 'A' AND representatie_id IN \('3'\)\)\) `).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(2, "opvoer", 1, "A", nil, "3", uur(7)))
+	mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE .*maakt_ongedaan_registratie_id IN \(1\)`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "a" WHERE \("a"\.id IN \('3'\)\) AND \("a"\.id IN \('3'\)\) ORDER BY "a"\.id ASC$`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(3, uur(7)))
+
+	router := gin.New()
+	router.GET("/full/as", MakeGetFullEntitiesHandler[model.Full_A]("A", nil))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+		"/full/as?peiltijdstip=2026-01-01T10:00:00Z&page=2&size=1", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+
+	var antwoord struct {
+		A []model.Full_A `json:"A"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(antwoord.A) != 1 || antwoord.A[0].ID != 3 {
+		t.Fatalf("expected only A3 on page 2, got %+v", antwoord.A)
+	}
+}
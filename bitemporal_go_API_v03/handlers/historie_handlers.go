@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== HISTORIE ===========================
+
+GET /historie/{type}/{id} geeft de volledige formele (en materiële) historie van een entiteit
+en al haar onderliggende gegevenselementen/relaties (MetaRegistry: OnderliggendeGegevenselementen).
+{type} is de typenaam (A) of de JSON veldnaam (a) van een entiteit.
+
+De historie is gegroepeerd per registratie (op volgorde van tijdstip). Per registratie staan de wijzigingen
+met de versie waar de wijziging over gaat: de rij uit de tabel, met opvoer/afvoer (en aanvang/einde) zoals ze
+direct na die registratie waren. Die leiden we af uit het logboek, net als bij een peilmoment (model.LeidGeldigheidAf);
+de kolommen in de tabel geven alleen de huidige kennis weer.
+
+	{
+		"typenaam": "A",
+		"id": "2",
+		"registraties": [
+			{
+				"registratie": {"id": 1, "registratietype": "registratie", "tijdstip": "...", "opmerking": "..."},
+				"wijzigingen": [
+					{"wijziging": {"wijzigingstype": "opvoer", "representatienaam": "A", ...}, "versie": {"id": 2, "opvoer": "...", ...}},
+					{"wijziging": {"wijzigingstype": "opvoer", "representatienaam": "A_U", ...}, "versie": {"a_id": 2, "rel_id": 1, ...}}
+				]
+			}
+		],
+		"zonder_wijziging": []
+	}
+
+Een materieel wijziging bevat zelf de nieuwe en de vorige aanvang/einde.
+Een ongedaanmaking heeft geen eigen wijzigingen, maar zet wel opvoer/afvoer terug. Ze staat in de historie met de
+wijzigingen van de registratie die ze (via de keten, zie model.LosOngedaanmakingsketenOp) ongedaan maakt of herstelt,
+elk met de versie direct na de ongedaanmaking.
+Rijen zonder wijziging records (bijv. via de plain of full POST endpoints ingevoerd) staan onder "zonder_wijziging".
+Dit vervangt het handmatig combineren van /wijzigingen, /registraties en /a_us.
+*/
+
+// historieWijziging is een wijziging met de versie waar ze over gaat.
+type historieWijziging struct {
+	Wijziging model.Wijziging `json:"wijziging"`
+	Versie    map[string]any  `json:"versie,omitempty"`
+}
+
+// historieRegistratie is een registratie met de wijzigingen die ze voor de entiteit heeft veroorzaakt.
+type historieRegistratie struct {
+	Registratie model.Registratie   `json:"registratie"`
+	Wijzigingen []historieWijziging `json:"wijzigingen"`
+}
+
+// MakeGetHistorieHandler returns a gin.HandlerFunc for GET /historie/:type/:id
+func MakeGetHistorieHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		meta, err := entiteitMetaUitParameter(c)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+
+		entiteitID := c.Param("id")
+		registraties, zonderWijziging, err := haalHistorieUitDB(c, meta, entiteitID)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+		if len(registraties) == 0 && len(zonderWijziging) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": fmt.Sprintf("%s %s not found", meta.Typenaam, entiteitID)})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"typenaam":         meta.Typenaam,
+			"id":               entiteitID,
+			"registraties":     registraties,
+			"zonder_wijziging": zonderWijziging,
+		})
+	}
+}
+
+// entiteitMetaUitParameter zoekt het entiteittype uit de :type parameter op in de MetaRegistry,
+// op typenaam (A) of JSON veldnaam (a).
+func entiteitMetaUitParameter(c *gin.Context) (model.TypeMeta, error) {
+	meta, ok := model.MetaRegistry.GetTypeMeta(c.Param("type"))
+	if !ok {
+		meta, ok = model.MetaRegistry.GetByVeldnaam(c.Param("type"))
+	}
+	if !ok {
+		return model.TypeMeta{}, nieuweValidatieFout(http.StatusNotFound, "onbekend type '%s'", c.Param("type"))
+	}
+	if meta.Metatype != model.MetatypeEntiteit {
+		return model.TypeMeta{}, nieuweValidatieFout(http.StatusBadRequest, "%s is een %s; dit kan alleen voor entiteiten", meta.Typenaam, meta.Metatype)
+	}
+	return meta, nil
+}
+
+// haalHistorieUitDB haalt alle versies van een entiteit en haar onderliggende typen op, met hun wijzigingen en registraties.
+func haalHistorieUitDB(c *gin.Context, meta model.TypeMeta, entiteitID string) ([]historieRegistratie, []map[string]any, error) {
+	// versies per type: de entiteit zelf en de rijen van de onderliggende typen met een FK naar de entiteit
+	versies := map[model.RepresentatieSleutel]map[string]any{}
+	var volgorde []model.RepresentatieSleutel
+
+	typen := []model.TypeMeta{meta}
+	for _, onderliggend := range meta.OnderliggendeGegevenselementen {
+		doelMeta, err := metaVoorRolnaam(meta, onderliggend.Rolnaam)
+		if err != nil {
+			return nil, nil, err
+		}
+		typen = append(typen, doelMeta)
+	}
+
+	for i, typeMeta := range typen {
+		kolom := typeMeta.IDKolom
+		if i > 0 {
+			kolom = typeMeta.EntiteitIDKolom
+		}
+
+		var rijen []map[string]any
+		err := DB.NewSelect().
+			Table(typeMeta.Tabelnaam).
+			Where("? = ?", bun.Ident(kolom), entiteitID).
+			Order(typeMeta.IDKolom).
+			Scan(c.Request.Context(), &rijen)
+		if err != nil {
+			return nil, nil, fmt.Errorf("HANDLER: kon historie van %s niet ophalen: %v", typeMeta.Typenaam, err)
+		}
+
+		for _, rij := range rijen {
+			sleutel := sleutelBijEntiteit(typeMeta, kolom, entiteitID, rij[typeMeta.IDKolom])
+			versies[sleutel] = rij
+			volgorde = append(volgorde, sleutel)
+		}
+	}
+
+	wijzigingen, err := haalWijzigingenVoorSleutelsUitDB(c, volgorde)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	registratieIDs := make([]int64, 0)
+	perRegistratie := map[int64][]model.Wijziging{}
+	metWijziging := map[model.RepresentatieSleutel]bool{}
+	for _, wijziging := range wijzigingen {
+		metWijziging[wijziging.Sleutel()] = true
+		if _, ok := perRegistratie[wijziging.RegistratieID]; !ok {
+			registratieIDs = append(registratieIDs, wijziging.RegistratieID)
+		}
+		perRegistratie[wijziging.RegistratieID] = append(perRegistratie[wijziging.RegistratieID], wijziging)
+	}
+
+	zonderWijziging := make([]map[string]any, 0)
+	for _, sleutel := range volgorde {
+		if !metWijziging[sleutel] {
+			zonderWijziging = append(zonderWijziging, versies[sleutel])
+		}
+	}
+
+	var registraties []model.Registratie
+	if len(registratieIDs) > 0 {
+		err = DB.NewSelect().
+			Model(&registraties).
+			Where("id IN (?)", bun.In(registratieIDs)).
+			Order("tijdstip", "id").
+			Scan(c.Request.Context())
+		if err != nil {
+			return nil, nil, fmt.Errorf("HANDLER: kon registraties voor de historie niet ophalen: %v", err)
+		}
+	}
+
+	// de ongedaanmakingen van die registraties (en van die ongedaanmakingen, enzovoort) horen ook in de historie
+	ongedaanmakingen, err := haalOngedaanmakingsketensUitDB(c, registratieIDs, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	perID := map[int64]model.Registratie{}
+	for _, registratie := range append(registraties, ongedaanmakingen...) {
+		perID[registratie.ID] = registratie
+	}
+	registraties = append(registraties, ongedaanmakingen...)
+	sort.SliceStable(registraties, func(i, j int) bool {
+		if !registraties[i].Tijdstip.Equal(registraties[j].Tijdstip) {
+			return registraties[i].Tijdstip.Before(registraties[j].Tijdstip)
+		}
+		return registraties[i].ID < registraties[j].ID
+	})
+
+	historie := make([]historieRegistratie, 0, len(registraties))
+	for _, registratie := range registraties {
+		// een ongedaanmaking raakt de wijzigingen van de registratie aan het eind van haar keten
+		basisID := registratie.ID
+		if registratie.Registratietype == model.RegistratietypeOngedaanmaking {
+			keten, err := model.LosOngedaanmakingsketenOp(registratie, func(id int64) (model.Registratie, bool, error) {
+				gevonden, ok := perID[id]
+				return gevonden, ok, nil
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("HANDLER: kon ongedaanmaking %d niet herleiden: %v", registratie.ID, err)
+			}
+			basisID = keten.Basis.ID
+		}
+
+		afgeleid, err := model.LeidGeldigheidAf(wijzigingen, ongedaanmakingen, registratie.Tijdstip)
+		if err != nil {
+			return nil, nil, fmt.Errorf("HANDLER: kon versies na registratie %d niet afleiden: %v", registratie.ID, err)
+		}
+
+		historieWijzigingen := make([]historieWijziging, 0, len(perRegistratie[basisID]))
+		for _, wijziging := range perRegistratie[basisID] {
+			historieWijzigingen = append(historieWijzigingen, historieWijziging{
+				Wijziging: wijziging,
+				Versie:    versieNaAfleiding(versies[wijziging.Sleutel()], afgeleid[wijziging.Sleutel()]),
+			})
+		}
+		historie = append(historie, historieRegistratie{Registratie: registratie, Wijzigingen: historieWijzigingen})
+	}
+
+	return historie, zonderWijziging, nil
+}
+
+// versieNaAfleiding geeft een kopie van de rij met opvoer/afvoer (en, als ze ooit gewijzigd zijn, aanvang/einde)
+// zoals afgeleid uit het logboek.
+func versieNaAfleiding(rij map[string]any, geldigheid model.AfgeleideGeldigheid) map[string]any {
+	if rij == nil {
+		return nil
+	}
+	versie := make(map[string]any, len(rij))
+	for kolom, waarde := range rij {
+		versie[kolom] = waarde
+	}
+	versie["opvoer"] = geldigheid.Opvoer
+	versie["afvoer"] = geldigheid.Afvoer
+	if geldigheid.MaterieelBekend {
+		versie["aanvang"] = geldigheid.Aanvang
+		versie["einde"] = geldigheid.Einde
+	}
+	return versie
+}
+
+// haalWijzigingenVoorSleutelsUitDB haalt de wijzigingen van de representaties op, op volgorde van tijdstip en id.
+func haalWijzigingenVoorSleutelsUitDB(c *gin.Context, sleutels []model.RepresentatieSleutel) ([]model.Wijziging, error) {
+	wijzigingen := make([]model.Wijziging, 0)
+	if len(sleutels) == 0 {
+		return wijzigingen, nil
+	}
+
+	// entiteit_id is alleen gevuld bij een PFK, de sleutel heeft dan een lege EntiteitID
+	tripels := make([][]string, 0, len(sleutels))
+	for _, sleutel := range sleutels {
+		tripels = append(tripels, []string{sleutel.Representatienaam, sleutel.EntiteitID, sleutel.RepresentatieID})
+	}
+	sort.Slice(tripels, func(i, j int) bool {
+		for k := range tripels[i] {
+			if tripels[i][k] != tripels[j][k] {
+				return tripels[i][k] < tripels[j][k]
+			}
+		}
+		return false
+	})
+
+	err := DB.NewSelect().
+		Model(&wijzigingen).
+		Where("(representatienaam, COALESCE(entiteit_id, ''), representatie_id) IN (?)", bun.In(tripels)).
+		Order("tijdstip", "id").
+		Scan(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon wijzigingen voor de historie niet ophalen: %v", err)
+	}
+
+	return wijzigingen, nil
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMakeGetHistorieHandler(t *testing.T) {
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+
+	t.Run("groups the versions of an entity and its gegevenselementen by registratie", func(t *testing.T) {
+		// Given: A2 en U1 opgevoerd in registratie 1; U1 afgevoerd en U2 opgevoerd in registratie 2.
+		// When: /historie/a/2 wordt opgevraagd.
+		// Then: twee registraties op volgorde, met per wijziging de versie.
+		mock := nieuweMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "a" WHERE \("id" = '2'\) ORDER BY "id"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(2, uur(1)))
+		mock.ExpectQuery(`SELECT \* FROM "a_u" WHERE \("a_id" = '2'\) ORDER BY "rel_id"`).
+			WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id", "opvoer", "afvoer"}).
+				AddRow(2, 1, uur(1), uur(2)).
+				AddRow(2, 2, uur(2), nil))
+		mock.ExpectQuery(`SELECT \* FROM "a_v" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+		mock.ExpectQuery(`SELECT \* FROM "rel_a_b" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "a_id"}))
+		mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(\(representatienaam, COALESCE\(entiteit_id, ''\), representatie_id\) IN \(\('A', '', '2'\), \('A_U', '2', '1'\), \('A_U', '2', '2'\)\)\) ORDER BY "tijdstip", "id"`).
+			WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+				AddRow(1, "opvoer", 1, "A", nil, "2", uur(1)).
+				AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(1)).
+				AddRow(3, "afvoer", 2, "A_U", "2", "1", uur(2)).
+				AddRow(4, "opvoer", 2, "A_U", "2", "2", uur(2)))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(id IN \(1, 2\)\) ORDER BY "tijdstip", "id"`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen).
+				AddRow(1, "registratie", uur(1), "A2 opgevoerd", nil, nil).
+				AddRow(2, "registratie", uur(2), "U gewijzigd", nil, nil))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\) AND \(maakt_ongedaan_registratie_id IN \(1, 2\)\) ORDER BY "id"`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen))
+
+		router := gin.New()
+		router.GET("/historie/:type/:id", MakeGetHistorieHandler())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/historie/a/2", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet sql expectations: %v", err)
+		}
+
+		var antwoord struct {
+			Typenaam     string                `json:"typenaam"`
+			Registraties []historieRegistratie `json:"registraties"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if antwoord.Typenaam != "A" || len(antwoord.Registraties) != 2 {
+			t.Fatalf("expected 2 registraties for A, got %+v", antwoord)
+		}
+		tweede := antwoord.Registraties[1]
+		if len(tweede.Wijzigingen) != 2 || tweede.Wijzigingen[0].Versie["rel_id"] != float64(1) || tweede.Wijzigingen[0].Versie["afvoer"] == nil {
+			t.Fatalf("expected afvoer of U1 with its version in registratie 2, got %+v", tweede)
+		}
+	})
+
+	t.Run("includes an ongedaanmaking and shows each version as of its registratie", func(t *testing.T) {
+		// Given: A2 en U1 opgevoerd in registratie 1; U1 afgevoerd in registratie 2; registratie 2 ongedaan gemaakt
+		// in registratie 3, dus in de tabel is U1 weer actief.
+		// When: /historie/a/2 wordt opgevraagd.
+		// Then: drie registraties; bij registratie 2 is U1 afgevoerd, de ongedaanmaking toont dezelfde wijziging met U1 weer actief.
+		mock := nieuweMockDB(t)
+
+		mock.ExpectQuery(`SELECT \* FROM "a" WHERE \("id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(2, uur(1)))
+		mock.ExpectQuery(`SELECT \* FROM "a_u" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id", "opvoer", "afvoer"}).AddRow(2, 1, uur(1), nil))
+		mock.ExpectQuery(`SELECT \* FROM "a_v" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+		mock.ExpectQuery(`SELECT \* FROM "rel_a_b" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "a_id"}))
+		mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE .* ORDER BY "tijdstip", "id"`).
+			WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+				AddRow(1, "opvoer", 1, "A", nil, "2", uur(1)).
+				AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(1)).
+				AddRow(3, "afvoer", 2, "A_U", "2", "1", uur(2)))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(id IN \(1, 2\)\) ORDER BY "tijdstip", "id"`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen).
+				AddRow(1, "registratie", uur(1), "A2 opgevoerd", nil, nil).
+				AddRow(2, "registratie", uur(2), "U1 afgevoerd", nil, nil))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\) AND \(maakt_ongedaan_registratie_id IN \(1, 2\)\)`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen).
+				AddRow(3, "ongedaanmaking", uur(3), "afvoer U1 was onterecht", nil, 2))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\) AND \(maakt_ongedaan_registratie_id IN \(3\)\)`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen))
+
+		router := gin.New()
+		router.GET("/historie/:type/:id", MakeGetHistorieHandler())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/historie/a/2", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet sql expectations: %v", err)
+		}
+
+		var antwoord struct {
+			Registraties []historieRegistratie `json:"registraties"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if len(antwoord.Registraties) != 3 {
+			t.Fatalf("expected 3 registraties, got %+v", antwoord.Registraties)
+		}
+		afvoer := antwoord.Registraties[1]
+		if len(afvoer.Wijzigingen) != 1 || afvoer.Wijzigingen[0].Versie["afvoer"] == nil {
+			t.Fatalf("expected U1 afgevoerd as of registratie 2, got %+v", afvoer)
+		}
+		ongedaanmaking := antwoord.Registraties[2]
+		if ongedaanmaking.Registratie.Registratietype != "ongedaanmaking" || len(ongedaanmaking.Wijzigingen) != 1 ||
+			ongedaanmaking.Wijzigingen[0].Wijziging.ID != 3 || ongedaanmaking.Wijzigingen[0].Versie["afvoer"] != nil {
+			t.Fatalf("expected the ongedaanmaking with U1 active again, got %+v", ongedaanmaking)
+		}
+	})
+
+	t.Run("rejects types that are not entiteiten", func(t *testing.T) {
+		// Given: het gegevenselement type A_U.
+		// When: /historie/A_U/1 wordt opgevraagd.
+		// Then: er volgt een 400 zonder database verkeer.
+		mock := nieuweMockDB(t)
+
+		router := gin.New()
+		router.GET("/historie/:type/:id", MakeGetHistorieHandler())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/historie/A_U/1", nil))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet sql expectations: %v", err)
+		}
+	})
+}
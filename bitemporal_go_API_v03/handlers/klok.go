@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== REGISTRATIETIJDSTIP ===========================
+
+Het tijdstip van een registratie is de formele tijdlijn van het register en mag dus nooit teruglopen.
+De server bepaalt het tijdstip met een Klok:
+- in productie de systeemklok (SysteemKlok)
+- in tests een deterministische klok (NieuweTestKlok), die bij elke aanroep een vaste stap verder gaat
+
+Met ALLOW_CLIENT_TIJDSTIP=true mag de client zelf een tijdstip meesturen (handig voor migraties en demo's).
+Zonder meegestuurd tijdstip geldt ook dan de klok. Zonder die instelling wordt een meegestuurd tijdstip genegeerd.
+
+Strikt oplopend:
+- tijdstippen worden afgekapt op microseconden (de precisie van Postgres timestamptz)
+- binnen het proces maakt MonotoneKlok de klok strikt oplopend
+- ten opzichte van de database vergelijken we met het laatste tijdstip in de registratie tabel:
+  - servertijd: schuif op naar 1 microseconde na het laatste tijdstip
+  - clienttijd: weiger met een 409
+- er is geen globale lock: registraties op verschillende entiteiten lopen gelijktijdig. Twee gelijktijdige
+  registraties kunnen zo hetzelfde laatste tijdstip zien en hetzelfde tijdstip kiezen; de unique constraint op
+  registratie.tijdstip (registratieTijdstipConstraint) laat de tweede dan wachten en falen, en die krijgt een
+  nieuwe poging met een later tijdstip (zie isHerhaalbareFout)
+- registraties op dezelfde entiteit wachten op elkaars entiteitlock (zie registration_helpers_vergrendeling.go)
+  vóór ze het tijdstip bepalen, en krijgen dus tijdstippen in de volgorde waarin ze committen
+*/
+
+// registratieTijdstipConstraint is de naam van de unique constraint op registratie.tijdstip
+// (de naam die Postgres geeft aan de unique uit de bun tag, zie ook dbsetup).
+const registratieTijdstipConstraint = "registratie_tijdstip_key"
+
+// Klok levert het huidige tijdstip.
+type Klok interface {
+	Nu() time.Time
+}
+
+// SysteemKlok is de klok van de server.
+type SysteemKlok struct{}
+
+// Nu geeft de huidige systeemtijd in UTC.
+func (SysteemKlok) Nu() time.Time { return time.Now().UTC() }
+
+// TestKlok is een deterministische klok: elke aanroep van Nu gaat een vaste stap verder.
+type TestKlok struct {
+	mu       sync.Mutex
+	volgende time.Time
+	stap     time.Duration
+}
+
+// NieuweTestKlok maakt een TestKlok die begint op start en bij elke aanroep stap verder gaat.
+func NieuweTestKlok(start time.Time, stap time.Duration) *TestKlok {
+	return &TestKlok{volgende: start, stap: stap}
+}
+
+// Nu geeft het volgende tijdstip van de TestKlok.
+func (k *TestKlok) Nu() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	nu := k.volgende
+	k.volgende = k.volgende.Add(k.stap)
+	return nu
+}
+
+// MonotoneKlok maakt een onderliggende klok strikt oplopend (in microseconden) binnen het proces,
+// ook als de systeemklok terugspringt.
+type MonotoneKlok struct {
+	mu     sync.Mutex
+	bron   Klok
+	laatst time.Time
+}
+
+// NieuweMonotoneKlok omhult een klok zodat opeenvolgende tijdstippen strikt oplopen.
+func NieuweMonotoneKlok(bron Klok) *MonotoneKlok {
+	return &MonotoneKlok{bron: bron}
+}
+
+// Nu geeft een tijdstip dat strikt later is dan het vorige.
+func (k *MonotoneKlok) Nu() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	nu := k.bron.Nu().Truncate(time.Microsecond)
+	if !nu.After(k.laatst) {
+		nu = k.laatst.Add(time.Microsecond)
+	}
+	k.laatst = nu
+	return nu
+}
+
+// RegistratieKlok bepaalt de tijdstippen van nieuwe registraties. Tests kunnen deze vervangen.
+var RegistratieKlok Klok = NieuweMonotoneKlok(SysteemKlok{})
+
+func isClientTijdstipToegestaan() bool {
+	return os.Getenv("ALLOW_CLIENT_TIJDSTIP") == "true"
+}
+
+// bepaalRegistratieTijdstip bepaalt het tijdstip voor een nieuwe registratie, strikt later dan de laatste registratie.
+// gevraagd is het door de client meegestuurde tijdstip (zero als niet meegestuurd); dat telt alleen met ALLOW_CLIENT_TIJDSTIP=true.
+func bepaalRegistratieTijdstip(c *gin.Context, tx bun.Tx, gevraagd time.Time) (time.Time, error) {
+	clientTijdstip := !gevraagd.IsZero() && isClientTijdstipToegestaan()
+
+	tijdstip := RegistratieKlok.Nu()
+	if clientTijdstip {
+		tijdstip = gevraagd
+	}
+	tijdstip = tijdstip.UTC().Truncate(time.Microsecond)
+
+	laatste, err := haalLaatsteRegistratieTijdstipUitDB(c, tx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if laatste == nil || tijdstip.After(*laatste) {
+		return tijdstip, nil
+	}
+
+	if clientTijdstip {
+		return time.Time{}, nieuweValidatieFout(http.StatusConflict,
+			"tijdstip %s is niet later dan de laatste registratie (%s); de formele tijdlijn mag niet teruglopen",
+			tijdstip.Format(time.RFC3339Nano), laatste.Format(time.RFC3339Nano))
+	}
+
+	return laatste.Add(time.Microsecond), nil
+}
+
+// haalLaatsteRegistratieTijdstipUitDB geeft het tijdstip van de laatste registratie, of nil als er nog geen is.
+func haalLaatsteRegistratieTijdstipUitDB(c *gin.Context, tx bun.Tx) (*time.Time, error) {
+	var laatste *time.Time
+	err := tx.NewSelect().
+		Model((*model.Registratie)(nil)).
+		ColumnExpr("MAX(tijdstip)").
+		Scan(c.Request.Context(), &laatste)
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon laatste registratietijdstip niet bepalen: %v", err)
+	}
+
+	return laatste, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMonotoneKlok_LooptStriktOpBijTerugspringendeBron(t *testing.T) {
+	// Given: een bron die steeds hetzelfde tijdstip geeft (met nanoseconden).
+	// When: de monotone klok drie keer wordt gevraagd.
+	// Then: de tijdstippen lopen per microseconde strikt op.
+	start := time.Date(2026, 1, 1, 7, 0, 0, 500, time.UTC)
+	klok := NieuweMonotoneKlok(NieuweTestKlok(start, 0))
+
+	eerste, tweede, derde := klok.Nu(), klok.Nu(), klok.Nu()
+	if !eerste.Equal(start.Truncate(time.Microsecond)) {
+		t.Fatalf("expected %s, got %s", start.Truncate(time.Microsecond), eerste)
+	}
+	if tweede.Sub(eerste) != time.Microsecond || derde.Sub(tweede) != time.Microsecond {
+		t.Fatalf("expected strictly increasing by 1µs, got %s, %s, %s", eerste, tweede, derde)
+	}
+}
+
+func TestBepaalRegistratieTijdstip_SchuiftOpNaLaatsteRegistratie(t *testing.T) {
+	// Given: de klok staat op 07:00, maar de laatste registratie in de database is van 08:00.
+	// When: het tijdstip voor een nieuwe registratie wordt bepaald.
+	// Then: het tijdstip wordt 1 microseconde na de laatste registratie.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	oud := RegistratieKlok
+	RegistratieKlok = NieuweTestKlok(time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC), time.Hour)
+	defer func() { RegistratieKlok = oud }()
+
+	laatste := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(laatste))
+
+	tijdstip, err := bepaalRegistratieTijdstip(ctx, tx, time.Time{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !tijdstip.Equal(laatste.Add(time.Microsecond)) {
+		t.Fatalf("expected %s, got %s", laatste.Add(time.Microsecond), tijdstip)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestBepaalRegistratieTijdstip_RejectsTeruglopendClientTijdstip(t *testing.T) {
+	// Given: client tijdstippen zijn toegestaan en de laatste registratie is van 08:00.
+	// When: de client 07:00 meestuurt.
+	// Then: er volgt een 409 validatiefout.
+	t.Setenv("ALLOW_CLIENT_TIJDSTIP", "true")
+	ctx, tx, mock := nieuweMockTx(t)
+
+	laatste := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(laatste))
+
+	_, err := bepaalRegistratieTijdstip(ctx, tx, time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC))
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestBepaalRegistratieTijdstip_GebruiktClientTijdstipOpLegeDatabase(t *testing.T) {
+	// Given: client tijdstippen zijn toegestaan en er zijn nog geen registraties.
+	// When: de client een tijdstip meestuurt.
+	// Then: dat tijdstip wordt gebruikt, afgekapt op microseconden.
+	t.Setenv("ALLOW_CLIENT_TIJDSTIP", "true")
+	ctx, tx, mock := nieuweMockTx(t)
+
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+	gevraagd := time.Date(2026, 1, 1, 7, 0, 0, 1500, time.UTC)
+	tijdstip, err := bepaalRegistratieTijdstip(ctx, tx, gevraagd)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !tijdstip.Equal(gevraagd.Truncate(time.Microsecond)) {
+		t.Fatalf("expected %s, got %s", gevraagd.Truncate(time.Microsecond), tijdstip)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/dbsetup"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// nieuweTestDatabase verbindt met de Postgres database uit TEST_DATABASE_URL en zet de tabellen opnieuw op.
+// Zonder TEST_DATABASE_URL wordt de test overgeslagen. Let op: alle tabellen in die database worden gedropt.
+func nieuweTestDatabase(t *testing.T) *bun.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is niet gezet; deze test heeft een echte Postgres database nodig")
+	}
+	gin.SetMode(gin.TestMode)
+
+	db := bun.NewDB(sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn))), pgdialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+	if err := dbsetup.DeleteTables(db); err != nil {
+		t.Fatalf("failed to drop tables: %v", err)
+	}
+	if err := dbsetup.CreateTables(db); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	oudeDB := DB
+	DB = db
+	t.Cleanup(func() { DB = oudeDB })
+	return db
+}
+
+// registreerOpTestDatabase biedt een registratie aan op de (echte) test database.
+func registreerOpTestDatabase(body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/registratie/", RegistreerMetNieuweAanpak())
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/registratie/", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// registreerTegelijk biedt de registraties (zo goed als) tegelijk aan en verwacht dat ze allemaal slagen.
+func registreerTegelijk(t *testing.T, bodies []string) {
+	t.Helper()
+	antwoorden := make([]*httptest.ResponseRecorder, len(bodies))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, body := range bodies {
+		wg.Add(1)
+		go func(i int, body string) {
+			defer wg.Done()
+			<-start
+			antwoorden[i] = registreerOpTestDatabase(body)
+		}(i, body)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, antwoord := range antwoorden {
+		if antwoord.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for registratie %d, got %d: %s", i, antwoord.Code, antwoord.Body.String())
+		}
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_ConcurrentOpvoerAssignsUniqueRelIDs(t *testing.T) {
+	// Given: A1 met één U, in een echte database.
+	// When: vijf registraties tegelijk elk een V bij A1 opvoeren.
+	// Then: alle registraties slagen en de V's krijgen de rel_id's 1 tot en met 5.
+	db := nieuweTestDatabase(t)
+
+	recorder := registreerOpTestDatabase(`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"a": {"id": 1, "us": [{"a_id": 1, "aaa": "a1", "bbb": "b1"}]}}}]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for A1, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var bodies []string
+	for i := 0; i < 5; i++ {
+		bodies = append(bodies, fmt.Sprintf(
+			`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"v": {"a_id": 1, "ccc": "c%d"}}}]}`, i))
+	}
+	registreerTegelijk(t, bodies)
+
+	var relIDs []int
+	err := db.NewSelect().
+		Table("a_v").
+		Column("rel_id").
+		Where("a_id = ?", 1).
+		Order("rel_id").
+		Scan(context.Background(), &relIDs)
+	if err != nil {
+		t.Fatalf("failed to query a_v: %v", err)
+	}
+	if fmt.Sprint(relIDs) != "[1 2 3 4 5]" {
+		t.Fatalf("expected rel_ids [1 2 3 4 5], got %v", relIDs)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_ConcurrentOpvoerOfEnkelvoudigKeepsOneActive(t *testing.T) {
+	// Given: A1 met één U (enkelvoudig), in een echte database.
+	// When: vijf registraties tegelijk elk een nieuwe U bij A1 opvoeren.
+	// Then: alle registraties slagen (na elkaar, door de vergrendeling van A1) en er is precies één actieve U.
+	db := nieuweTestDatabase(t)
+
+	recorder := registreerOpTestDatabase(`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"a": {"id": 1, "us": [{"a_id": 1, "aaa": "a1", "bbb": "b1"}]}}}]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for A1, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var bodies []string
+	for i := 0; i < 5; i++ {
+		bodies = append(bodies, fmt.Sprintf(
+			`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"u": {"a_id": 1, "aaa": "a%d", "bbb": "b%d"}}}]}`, i, i))
+	}
+	registreerTegelijk(t, bodies)
+
+	actief, err := db.NewSelect().
+		Table("a_u").
+		Where("a_id = ?", 1).
+		Where("afvoer IS NULL").
+		Count(context.Background())
+	if err != nil {
+		t.Fatalf("failed to query a_u: %v", err)
+	}
+	if actief != 1 {
+		t.Fatalf("expected exactly one active U, got %d", actief)
+	}
+}
+
+func TestCreateTables_DatabaseRejectsSecondActiveEnkelvoudig(t *testing.T) {
+	// Given: A1 met één actieve U, in een echte database.
+	// When: buiten de API om (directe SQL) een tweede actieve U bij A1 wordt ingevoegd.
+	// Then: de database weigert dat (exclusion constraint op a_id voor de actieve U's).
+	db := nieuweTestDatabase(t)
+
+	recorder := registreerOpTestDatabase(`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"a": {"id": 1, "us": [{"a_id": 1, "aaa": "a1", "bbb": "b1"}]}}}]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for A1, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	_, err := db.ExecContext(context.Background(),
+		`INSERT INTO "a_u" ("a_id", "aaa", "bbb", "opvoer") VALUES (1, 'a2', 'b2', now())`)
+	if err == nil || !strings.Contains(err.Error(), "ex_a_u_enkelvoudig") {
+		t.Fatalf("expected a violation of ex_a_u_enkelvoudig, got %v", err)
+	}
+}
+
+func TestSpeelLogboekAf_MagTijdelijkTweeActieveEnkelvoudigeHebben(t *testing.T) {
+	// Given: A1 met U1; U2 opgevoerd (U1 afgevoerd) en dat ongedaan gemaakt, dus volgens het logboek is alleen U1 actief.
+	// Buiten de API om is dat omgedraaid: U1 afgevoerd, U2 opgevoerd.
+	// When: het logboek wordt afgespeeld.
+	// Then: de replay slaagt, ook al zijn na het herstel van U1 even beide actief, en alleen U1 is actief.
+	db := nieuweTestDatabase(t)
+
+	for _, body := range []string{
+		`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"a": {"id": 1, "us": [{"a_id": 1, "aaa": "a1", "bbb": "b1"}]}}}]}`,
+		`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"opvoer": {"u": {"a_id": 1, "aaa": "a2", "bbb": "b2"}}}]}`,
+		`{"registratie": {"registratietype": "ongedaanmaking", "maakt_ongedaan_registratie_id": 2}, "wijzigingen": []}`,
+	} {
+		if recorder := registreerOpTestDatabase(body); recorder.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	}
+
+	ctx := context.Background()
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE "a_u" SET "afvoer" = now() WHERE "a_id" = 1 AND "rel_id" = 1`); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE "a_u" SET "opvoer" = now() WHERE "a_id" = 1 AND "rel_id" = 2`)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to swap the active U: %v", err)
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := SpeelLogboekAf(ctx, tx, "A", "1")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected the replay to succeed, got %v", err)
+	}
+
+	var actief []int
+	err = db.NewSelect().
+		Table("a_u").
+		Column("rel_id").
+		Where("a_id = ?", 1).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Scan(ctx, &actief)
+	if err != nil {
+		t.Fatalf("failed to query a_u: %v", err)
+	}
+	if len(actief) != 1 || actief[0] != 1 {
+		t.Fatalf("expected only U1 to be active, got %v", actief)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+Fouten bij het verwerken van een registratie.
+We onderscheiden fouten in de aangeboden registratie zelf (de client moet iets anders aanbieden: 4xx)
+van technische fouten tijdens de verwerking (database e.d.: 5xx).
+Een validatieFout wordt ongewijzigd (of met %w) doorgegeven, zodat de handler de juiste status kan bepalen.
+*/
+
+// validatieFout is een fout in de aangeboden registratie, met de bijbehorende HTTP status.
+type validatieFout struct {
+	status  int
+	bericht string
+}
+
+func (f *validatieFout) Error() string { return f.bericht }
+
+func nieuweValidatieFout(status int, format string, args ...any) error {
+	return &validatieFout{status: status, bericht: fmt.Sprintf(format, args...)}
+}
+
+// httpStatusVoorFout geeft de status van een validatiefout terug, en anders de fallback (typisch 500).
+func httpStatusVoorFout(err error, fallback int) int {
+	var fout *validatieFout
+	if errors.As(err, &fout) {
+		return fout.status
+	}
+	return fallback
+}
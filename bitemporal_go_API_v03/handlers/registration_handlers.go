@@ -1,262 +1,397 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/uptrace/bun"
 )
 
 func RegistreerMetNieuweAanpak() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		var request model.RegistreerRequest
-		if err := c.ShouldBindJSON(&request); err != nil {
+
+		// IDEMPOTENTIE: optionele Idempotency-Key header, zie registration_helpers_idempotentie.go
+		idempotentieSleutel, requestHash, err := leesIdempotentie(c)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+
+		// de body bewaren we: een nieuwe poging (zie hieronder) begint weer met het oorspronkelijke request
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
 
 		// Output request body for debugging as pretty JSON
 		LogRequestBodyAsJSON(c)
 
-		// Start transaction
-		tx, err := DB.BeginTx(c.Request.Context(), nil)
+		// OPTIMISTISCHE CONCURRENCY: voorwaarden uit If-Match (en per wijziging), zie registration_helpers_versie.go
+		request, voorwaarden, err := leesRegistratieRequest(c, body)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to start transaction: %v", err)})
+			c.JSON(httpStatusVoorFout(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 			return
 		}
-		committed := false
-		defer func() {
-			if !committed {
-				_ = tx.Rollback()
+
+		opties := registratieopties{
+			start: start,
+			// DRYRUN (?dryrun=true): de hele verwerking inclusief alle validaties, maar de transactie wordt altijd teruggedraaid
+			dryrun: strings.ToLower(c.Query("dryrun")) == "true",
+			// ANTWOORD (?return=full): ook de resulterende entiteiten, zie registration_helpers_antwoord.go
+			volledig:            strings.ToLower(c.Query("return")) == "full",
+			idempotentieSleutel: idempotentieSleutel,
+			requestHash:         requestHash,
+		}
+
+		// IDEMPOTENTIE: een herhaald request krijgt het oorspronkelijke antwoord (of een 409 bij een ander request)
+		if idempotentieSleutel != "" && !opties.dryrun {
+			afgespeeld, err := speelIdempotentAntwoordAf(c, DB, idempotentieSleutel, requestHash)
+			if err != nil {
+				c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+				return
 			}
-		}()
+			if afgespeeld {
+				return
+			}
+		}
 
-		/*
-			tijdelijk voor testen: maak het registratietijdstip gelijk aan
-			een tijdstip oplopende met het registratienummer.
-			Dat vergt wel eerst een insert van de registratie zonder tijdstip,
-			en dan een update met het tijdstip.
-		*/
+		// VERGRENDELING: strandt een poging op een serialisatiefout of deadlock, dan volgt (na een korte wachttijd)
+		// een nieuwe poging in een nieuwe transactie, zie registration_helpers_vergrendeling.go
+		status, antwoord, err := verwerkRegistratie(c, request, voorwaarden, opties)
+		for poging := 1; isHerhaalbareFout(err) && poging < maxRegistratiePogingen; poging++ {
+			if debugLogsEnabled() {
+				fmt.Printf("HANDLER: poging %d van de registratie mislukt, nieuwe poging: %v\n", poging, err)
+			}
+			if !wachtOpNieuwePoging(c, poging) {
+				break
+			}
+			// de vorige poging heeft ids, tijdstippen en voorwaarden in het request ingevuld
+			if request, voorwaarden, err = leesRegistratieRequest(c, body); err != nil {
+				status = httpStatusVoorFout(err, http.StatusBadRequest)
+				break
+			}
+			status, antwoord, err = verwerkRegistratie(c, request, voorwaarden, opties)
+		}
 
-		// Step 1: Insert Registratie and get ID + Tijdstip
-		_, err = tx.NewInsert().
-			Model(&request.Registratie).
-			Returning("id").
-			Exec(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to insert registratie: %v", err)})
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
-
-		// TIJDELIJK: OVERWRITE registratietijdstip met een tijdstip gebaseerd op de registratie ID, zodat we oplopende tijdstippen hebben voor testdoeleinden
-		request.Registratie.Tijdstip = time.
-			Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).
-			Add(time.Duration(request.Registratie.ID) * time.Hour).
-			Add(time.Microsecond * time.Duration(request.Registratie.ID)) //gimmick: laatste cijfer van de tijd is het ID van de registratie...
-		_, err = tx.NewUpdate().
-			Model(&request.Registratie).
-			Where("id = ?", request.Registratie.ID).
-			Exec(c.Request.Context())
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to update registratie with tijdstip: %v", err)})
+		if antwoord == nil {
+			// het antwoord is al geschreven (afgespeeld idempotent antwoord)
 			return
 		}
+		c.JSON(status, antwoord)
+	}
 
-		// set twee variabelen voor verder gebruik in de fuctie: registratieID en registratieTijdstip
-		registratieID := request.Registratie.ID
-		registratieTijdstip := request.Registratie.Tijdstip
+}
 
-		/*
-			CORRECTIE / ONGEDAANMAKING scenario's
-			-------------------------------------
-			CORRECTIE VOORWAARDEN:
-			- Registratietype = Correctie
-			- CorrigeertRegistratieID != nil
-			- Het tijdstip van de correctie is later dan dat van de te corrigeren registratie.
-			- // LATER // indien niet: zoek de eerste wijziging in de registratie, en kijk of die verwijst naar een gegevenselement dat gebruikt werd in andere registratie
-			- Een entiteit zelf kan niet gewijzigd worden, BEHALVE de materiele tijden (aanvang, einde)
-
-			PROBLEEM:
-			- een registratie kan nu meer dan één entiteit betreffen
-			- op zich zou hetzelfde formaat gebruikt kunnen worden voor correctie, bijv.:
-			{
-				"registratie": {
-					"registratietype": "correctie",
-					"tijdstip": "2026-01-12T11:00:00Z",
-					"opmerking": "Corrigeer U3 van entiteit A2",
-				},
-				"wijzigingen": [
-					{
-						"opvoer": {
-							"a": {
-								"id": 2,
-								"us": [
-									{
-										"rel_id": 3,
-										"aaa": "a2-correctie",
-										"bbb": "b2-correctie"
-									}
-								]
-							}
-						}
-					}
-				]
-			}
+// registratieopties zijn de instellingen van het request die voor elke poging van de registratie gelijk zijn.
+type registratieopties struct {
+	start               time.Time
+	dryrun              bool
+	volledig            bool
+	idempotentieSleutel string
+	requestHash         string
+}
 
-			- in dit voorbeeld corrigeert de wijziging het gegevenselement U3 (id=3) van entiteit A2 (id=2)
-			- de handler voor deze correctie moet dus:
-			1. U3 afvoeren (op dezelfde manier als bij een normale afvoer, dus inclusief wijziging record) met registratietijdstip
-			2. U3 opnieuw opvoeren met de gecorrigeerde data, maar met een nieuwe ID... Dat is nu even lastig zonder auto-increment ID,
-			maar we zouden in de handler een nieuwe ID kunnen genereren (bijv. max bestaande ID + 1) voordat we de opvoer uitvoeren.
-			Deze nieuwe ID wordt dan ook gebruikt in de wijziging record voor de opvoer.
-			Een andere oplossing zou zijn om UUID's te gebruiken in plaats van auto-increment IDs,
-			zodat we al een ID kunnen genereren voordat we de opvoer uitvoeren.
+// leesRegistratieRequest leest het request uit de body en de voorwaarden uit de headers.
+func leesRegistratieRequest(c *gin.Context, body []byte) (model.RegistreerRequest, versievoorwaarden, error) {
+	var request model.RegistreerRequest
+	if err := binding.JSON.BindBody(body, &request); err != nil {
+		return request, nil, nieuweValidatieFout(http.StatusBadRequest, "%s", err.Error())
+	}
+	if request.Registratie.Registratietype == "" {
+		request.Registratie.Registratietype = model.RegistratietypeRegistratie
+	}
 
-			In principe wil je ook een afgevoerd gegeven nog kunnen corrigeren. Dat lijkt raar (StUF sluit het bijv. uit),
-			maar het kan als het materieel is iig wel. Voor alleen een formeel gegevens is het wel gek.
+	voorwaarden, err := leesVersievoorwaarden(c)
+	if err != nil {
+		return request, nil, err
+	}
+	return request, voorwaarden, nil
+}
 
-			RECURSIE:
-			- Een complexere correctie is eigenlijk een herhaling van zetten, maar dan
-			met meerdere gegevenselementen betreffende één of meerdere entiteiten.
+// verwerkRegistratie voert één poging van de registratie uit, in een eigen transactie.
+// Het geeft de status en het antwoord terug, of de status en de fout; de handler schrijft die.
+// Een nil antwoord zonder fout betekent dat het antwoord al is geschreven.
+func verwerkRegistratie(c *gin.Context, request model.RegistreerRequest, voorwaarden versievoorwaarden, opties registratieopties) (int, any, error) {
+	// VERGRENDELING: de entiteiten uit de wijzigingen, bepaald vóór er iets wordt geschreven
+	teVergrendelen, err := teVergrendelenEntiteiten(request)
+	if err != nil {
+		return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+	}
 
+	// Start transaction
+	tx, err := DB.BeginTx(c.Request.Context(), nil)
+	if err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
 
-			ONGEDAANMAKING VOORWAARDEN:
-			- Registratietype = Ongedaanmaking
-			- MaaktOngedaanRegistratieID != nil
-			- Het tijdstip van de ongedaanmaking is later dan dat van de ongedaan te maken registratie.
+	if err := vergrendelEntiteiten(c, tx, teVergrendelen); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
 
-			ACTIES ONGEDAANMAKING:
-			- Bij ongedaanmaking maken we een nieuwe registratie aan met type "Ongedaanmaking"
-			en een verwijzing naar de te ongedaan maken registratie.
-			- In principe zou dat genoeg zijn,
-			maar we willen nog de afgeleide velden opvoer en afvoer in de ongedaangemaakte registratie opnieuw bepalen.
-			Hier zit wel complexiteit, omdat we dan feitelijk moeten tijdsreizen naar een tijdstip nèt voor de ongedaan gemaakte registratie,
-			en dan de toestand van opvoer/afvoer herstellen.
-			N.B.: het kan dus zijn dat de representatie in de ongedaan gemaakte registratie werd opgevoerd, en dus daarvoor niet bestond.
-			Dan moet gewoon de opvoer leeggemaakt worden.
+	// Step 1: bepaal het (strikt oplopende) tijdstip en insert Registratie in één keer
+	tijdstip, err := bepaalRegistratieTijdstip(c, tx, request.Registratie.Tijdstip)
+	if err != nil {
+		return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+	}
+	request.Registratie.Tijdstip = tijdstip
+
+	_, err = tx.NewInsert().
+		Model(&request.Registratie).
+		Returning("id").
+		Exec(c.Request.Context())
+	if err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("failed to insert registratie: %v", err)
+	}
 
-			Maar een ongedaanmaking van een ongedaanmaking of van een correctie is lastiger. Moet ik even over nadenken.
+	// set twee variabelen voor verder gebruik in de fuctie: registratieID en registratieTijdstip
+	registratieID := request.Registratie.ID
+	registratieTijdstip := request.Registratie.Tijdstip
 
+	if err := valideerRegistratie(c, tx, request); err != nil {
+		return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+	}
 
+	/*
+		CORRECTIE: zie registration_helpers_correctie.go
+		ONGEDAANMAKING: zie registration_helpers_ongedaanmaking.go (ook van correcties en van ongedaanmakingen)
+	*/
+	// KARDINALITEIT en VERSIES: de entiteiten die deze registratie raakt, zie registration_helpers_kardinaliteit.go
+	geraakt := geraakteEntiteiten{}
+	geraaktTijdstip := registratieTijdstip
 
+	if request.Registratie.Registratietype == model.RegistratietypeOngedaanmaking {
+		keten, err := valideerOngedaanmaking(c, tx, request.Registratie)
+		if err != nil {
+			return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+		}
+		// de rijen van de ongedaan gemaakte registratie, zolang ze nog haar tijdstip hebben
+		geraaktTijdstip = keten.Basis.Tijdstip
+		if err := haalGeraakteEntiteitenUitDB(c, tx, geraaktTijdstip, entiteittypen(), geraakt); err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		// VERGRENDELING: een ongedaanmaking heeft geen wijzigingen in het request; vergrendel wat ze terugzet
+		if err := vergrendelEntiteiten(c, tx, geraakteEntiteitSleutels(geraakt)); err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		if err := handleOngedaanmaking(c, tx, request.Registratie, keten); err != nil {
+			return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("failed to handle ongedaanmaking: %v", err)
+		}
+	}
 
-		*/
+	/* check of er een param "ID" is meegegeven in de URL
+	dit is dan de ID van de entiteit waarop de registratie betrekking heeft,
+	en die we kunnen gebruiken voor:
+	- Afvoer van de gehele entiteit (in dat geval is deze ID gelijk aan de ID van de entiteit in de opvoer)
+	- wijziging op een of meer van de gegevenselementen van de entiteit (in dat geval is deze ID ook gelijk aan de ID van de entiteit,
+	en waarnaar het gegevenselement verwijst via haar (bijv.) a_ID of B_ID veld.
+	In de database is dit de FK naar de entiteit-tabel.
+	- Bij correctie van een bestaande registratie (in dat geval is deze ID ook gelijk aan de ID van de entiteit).
+	*/
+	if c.Param("id") != "" {
+		// we slaan deze ID op in de context zodat we er later bij kunnen
+		c.Set("entiteitID", c.Param("id"))
+	}
 
-		/*
-			// Registratie, Correctie, Ongedaanmaking
-			type Registratie struct {
-				ID                         int64                `bun:"id,pk,autoincrement"` // auto-increment ID van de registratie
-				Registratietype            RegistratietypeEnum  // Registratie, Correctie, Ongedaanmaking
-				Tijdstip                   time.Time            // Het tijdstip van de registratie, correctie of ongedaanmaking
-				Opmerking                  *string              // optioneel veld voor extra informatie
-				CorrigeertRegistratieID    *int64               // bij correcties: verwijzing naar de registratie die gecorrigeerd wordt
-				MaaktOngedaanRegistratieID *int64               // bij ongedaanmakings: verwijzing naar de registratie die ongedaan wordt gemaakt
+	// TODO: hier komt de nieuwe aanpak van registratie, waarbij we de registratie en wijziging(en) in één endpoint verwerken
+	// we kunnen hierbij gebruik maken van de "entiteitID" param in de URL (optioneel) en/of de IDs in de opvoer/afvoer van de wijziging(en)
+	// om te bepalen op welke entiteit en/of gegevenselementen de registratie betrekking heeft
+
+	//Haal de "methode" query param op, die aangeeft of we de reflectie-based aanpak willen gebruiken
+	// of de aanpak waarbij we de 'metamap' gebruiken
+	// vermoedelijk is er verschil in afhandelingstijd, omdat reflectie meer overhead heeft,
+	// maar moeten we wel de metamap inrichten.
+	methode := strings.ToLower(c.Query("methode"))
+	useReflectie := methode == "reflectie"
+
+	// Step 2: Process each wijziging
+	// foutmeldingen noemen de index van de wijziging in het request (wijzigingen[i])
+	for i, wijziging := range request.Wijzigingen {
+		var rep *model.RepresentatiePlusNaam
+		if wijziging.Opvoer != nil {
+			rep = wijziging.Opvoer // geen specifieke representatie verwacht; daar dealen we later wel mee
+
+		} else if wijziging.Afvoer != nil {
+			rep = wijziging.Afvoer // geen specifieke representatie verwacht; daar dealen we later wel mee
+		} else if wijziging.Materieel != nil {
+			rep = wijziging.Materieel // alleen aanvang/einde, zie registration_helpers_materieel.go
+		}
+		// TEST: print recursief de representatie, inclusief onderliggende gegevenselementen/relaties
+		if debugLogsEnabled() {
+			if rep != nil && rep.Representatie != nil {
+				fmt.Printf("HANDLER: representatienaam=%s veldnaam=%s\n%s", rep.Representatienaam, rep.Veldnaam, model.RepresentatieToString(rep.Representatie))
+			} else {
+				fmt.Println("HANDLER: geen representatie aanwezig in wijziging")
 			}
-		*/
-
-		/* check of er een param "ID" is meegegeven in de URL
-		dit is dan de ID van de entiteit waarop de registratie betrekking heeft,
-		en die we kunnen gebruiken voor:
-		- Afvoer van de gehele entiteit (in dat geval is deze ID gelijk aan de ID van de entiteit in de opvoer)
-		- wijziging op een of meer van de gegevenselementen van de entiteit (in dat geval is deze ID ook gelijk aan de ID van de entiteit,
-		en waarnaar het gegevenselement verwijst via haar (bijv.) a_ID of B_ID veld.
-		In de database is dit de FK naar de entiteit-tabel.
-		- Bij correctie van een bestaande registratie (in dat geval is deze ID ook gelijk aan de ID van de entiteit).
-		*/
-		if c.Param("id") != "" {
-			// we slaan deze ID op in de context zodat we er later bij kunnen
-			c.Set("entiteitID", c.Param("id"))
 		}
 
-		// TODO: hier komt de nieuwe aanpak van registratie, waarbij we de registratie en wijziging(en) in één endpoint verwerken
-		// we kunnen hierbij gebruik maken van de "entiteitID" param in de URL (optioneel) en/of de IDs in de opvoer/afvoer van de wijziging(en)
-		// om te bepalen op welke entiteit en/of gegevenselementen de registratie betrekking heeft
-
-		//Haal de "methode" query param op, die aangeeft of we de reflectie-based aanpak willen gebruiken
-		// of de aanpak waarbij we de 'metamap' gebruiken
-		// vermoedelijk is er verschil in afhandelingstijd, omdat reflectie meer overhead heeft,
-		// maar moeten we wel de metamap inrichten.
-		methode := strings.ToLower(c.Query("methode"))
-		useReflectie := methode == "reflectie"
+		if rep == nil || rep.Representatie == nil {
+			return http.StatusBadRequest, nil, fmt.Errorf("wijzigingen[%d]: wijziging bevat geen representatie", i)
+		}
 
-		// Step 2: Process each wijziging
-		for _, wijziging := range request.Wijzigingen {
-			var rep *model.RepresentatiePlusNaam
-			if wijziging.Opvoer != nil {
-				rep = wijziging.Opvoer // geen specifieke representatie verwacht; daar dealen we later wel mee
+		temporalRep, ok := rep.Representatie.(model.FormeleRepresentatie)
+		if !ok {
+			return http.StatusBadRequest, nil, fmt.Errorf("wijzigingen[%d]: representatie %T ondersteunt geen opvoer/afvoer interface", i, rep.Representatie)
+		}
 
-			} else if wijziging.Afvoer != nil {
-				rep = wijziging.Afvoer // geen specifieke representatie verwacht; daar dealen we later wel mee
+		// OPTIMISTISCHE CONCURRENCY: voorwaarde voor de entiteit van deze wijziging
+		if wijziging.LaatsteRegistratieID != nil {
+			sleutel, err := entiteitVanRepresentatie(rep.Representatienaam, temporalRep)
+			if err == nil {
+				err = voorwaarden.voegToe(sleutel.Typenaam, sleutel.ID, *wijziging.LaatsteRegistratieID)
 			}
-			// TEST: print recursief de representatie, inclusief onderliggende gegevenselementen/relaties
-			if debugLogsEnabled() {
-				if rep != nil && rep.Representatie != nil {
-					fmt.Printf("HANDLER: representatienaam=%s veldnaam=%s\n%s", rep.Representatienaam, rep.Veldnaam, model.RepresentatieToString(rep.Representatie))
-				} else {
-					fmt.Println("HANDLER: geen representatie aanwezig in wijziging")
-				}
+			if err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("wijzigingen[%d]: %v", i, err)
 			}
+		}
 
-			if rep == nil || rep.Representatie == nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "wijziging bevat geen representatie"})
-				return
+		// process de WIJZIGING
+		// kijk naar het metatype van de representatie
+		// als opvoer iets anders dan afvoer
+		// indien correctie of ongedaanmaking ook andere logica
+
+		// Handle REGISTRATIE / OPVOER scenario
+		switch true {
+		// CORRECTIE scenario: opvoer van gecorrigeerde gegevenselementen/relaties
+		case wijziging.Opvoer != nil && request.Registratie.Registratietype == model.RegistratietypeCorrectie:
+			if err := handleRepresentatieCorrectie(c, tx, registratieID, registratieTijdstip,
+				*request.Registratie.CorrigeertRegistratieID, rep.Representatienaam, temporalRep); err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("wijzigingen[%d]: failed to handle correctie van %s: %v", i, rep.Representatienaam, err)
 			}
-
-			temporalRep, ok := rep.Representatie.(model.FormeleRepresentatie)
-			if !ok {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("representatie %T ondersteunt geen opvoer/afvoer interface", rep.Representatie)})
-				return
+		// OPVOER scenario's
+		case wijziging.Opvoer != nil:
+			// ZONDER REFLECTIE
+			handleOpvoer := handleRepresentatieOpvoerMeta
+			// MET REFLECTIE
+			if useReflectie {
+				handleOpvoer = handleRepresentatieOpvoerMetReflectie
 			}
-
-			// process de WIJZIGING
-			// kijk naar het metatype van de representatie
-			// als opvoer iets anders dan afvoer
-			// indien correctie of ongedaanmaking ook andere logica
-
-			// Handle REGISTRATIE / OPVOER scenario
-			switch true {
-			// OPVOER scenario's
-			case wijziging.Opvoer != nil:
-				// ZONDER REFLECTIE
-				handleOpvoer := handleRepresentatieOpvoerMeta
-				// MET REFLECTIE
-				if useReflectie {
-					handleOpvoer = handleRepresentatieOpvoerMetReflectie
-				}
-				if err := handleOpvoer(c, tx, registratieID, registratieTijdstip,
-					rep.Representatienaam, temporalRep); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to handle opvoer van %s: %v", rep.Representatienaam, err)})
-					return
-				}
-			// AFVOER scenario's
-			case wijziging.Afvoer != nil:
-				if err := handleRepresentatieAfvoer(c, tx, registratieID, registratieTijdstip,
-					rep.Representatienaam, temporalRep); err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to handle afvoer van %s: %v", rep.Representatienaam, err)})
-					return
-				}
+			if err := handleOpvoer(c, tx, registratieID, registratieTijdstip,
+				rep.Representatienaam, temporalRep); err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("wijzigingen[%d]: failed to handle opvoer van %s: %v", i, rep.Representatienaam, err)
+			}
+		// AFVOER scenario's
+		case wijziging.Afvoer != nil:
+			if err := handleRepresentatieAfvoer(c, tx, registratieID, registratieTijdstip,
+				rep.Representatienaam, temporalRep); err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("wijzigingen[%d]: failed to handle afvoer van %s: %v", i, rep.Representatienaam, err)
+			}
+		// MATERIELE wijziging: alleen aanvang/einde
+		case wijziging.Materieel != nil:
+			if err := handleRepresentatieMaterieel(c, tx, registratieID, registratieTijdstip,
+				rep.Representatienaam, temporalRep); err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, fmt.Errorf("wijzigingen[%d]: failed to handle materiële wijziging van %s: %v", i, rep.Representatienaam, err)
 			}
-
 		}
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to commit transaction: %v", err)})
-			return
-		}
-		committed = true
+	}
+
+	// KARDINALITEIT: controleer de resulterende toestand van de geraakte entiteiten
+	if err := haalGeraakteEntiteitenUitDB(c, tx, geraaktTijdstip, entiteittypen(), geraakt); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	if err := controleerKardinaliteit(c, tx, geraakt); err != nil {
+		return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+	}
+
+	// VERSIES: controleer de voorwaarden en maak deze registratie de versie van de geraakte entiteiten
+	if err := werkEntiteitversiesBij(c, tx, registratieID, geraakt, voorwaarden); err != nil {
+		return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+	}
+
+	// ANTWOORD: de registratie met alle (ook impliciete) wijzigingen, opgehaald vóór de commit
+	antwoord, err := bouwRegistratieAntwoord(c, tx, request.Registratie, geraakt, opties.volledig)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
 
-		elapsedMs := time.Since(start).Milliseconds()
-		// Succes response
-		c.JSON(http.StatusCreated,
-			gin.H{"message": fmt.Sprintf("De registratie %d is succesvol verwerkt op %s in %d ms", registratieID, registratieTijdstip, elapsedMs)})
+	// DRYRUN: geef terug wat deze registratie zou vastleggen (inclusief de impliciete afvoeren
+	// van voorgangers en onderliggende representaties, en de toegekende rel_ids); de defer draait alles terug
+	if opties.dryrun {
+		antwoord.Dryrun = true
+		antwoord.Message = "De registratie is geldig en is niet vastgelegd"
+		return http.StatusOK, antwoord, nil
+	}
+
+	elapsedMs := time.Since(opties.start).Milliseconds()
+	antwoord.Message = fmt.Sprintf("De registratie %d is succesvol verwerkt op %s in %d ms", registratieID, registratieTijdstip, elapsedMs)
+
+	// IDEMPOTENTIE: leg de sleutel met het antwoord vast in dezelfde transactie
+	if opties.idempotentieSleutel != "" {
+		vastgelegd, err := legIdempotentieVast(c, tx, opties.idempotentieSleutel, opties.requestHash,
+			registratieID, registratieTijdstip, http.StatusCreated, antwoord)
+		if err != nil {
+			return http.StatusInternalServerError, nil, err
+		}
+		if !vastgelegd {
+			// een gelijktijdig request met dezelfde sleutel was eerder klaar: deze registratie wordt teruggedraaid (defer)
+			afgespeeld, err := speelIdempotentAntwoordAf(c, DB, opties.idempotentieSleutel, opties.requestHash)
+			if err != nil {
+				return httpStatusVoorFout(err, http.StatusInternalServerError), nil, err
+			}
+			if !afgespeeld {
+				return http.StatusConflict, nil, fmt.Errorf("%s %q wordt al gebruikt", IdempotencyKeyHeader, opties.idempotentieSleutel)
+			}
+			return http.StatusOK, nil, nil
+		}
+	}
 
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return http.StatusInternalServerError, nil, fmt.Errorf("failed to commit transaction: %v", err)
 	}
+	committed = true
+
+	// Succes response
+	return http.StatusCreated, antwoord, nil
+}
 
+// valideerRegistratie controleert of het registratietype, de verwijzingen naar andere registraties
+// en de wijzigingen bij elkaar passen.
+func valideerRegistratie(c *gin.Context, tx bun.Tx, request model.RegistreerRequest) error {
+	registratie := request.Registratie
+	switch registratie.Registratietype {
+	case model.RegistratietypeRegistratie:
+		if registratie.CorrigeertRegistratieID != nil || registratie.MaaktOngedaanRegistratieID != nil {
+			return nieuweValidatieFout(http.StatusBadRequest,
+				"een gewone registratie mag niet naar een te corrigeren of ongedaan te maken registratie verwijzen")
+		}
+		return nil
+	case model.RegistratietypeCorrectie:
+		if registratie.MaaktOngedaanRegistratieID != nil {
+			return nieuweValidatieFout(http.StatusBadRequest, "een correctie mag geen maakt_ongedaan_registratie_id hebben")
+		}
+		return valideerCorrectie(c, tx, registratie)
+	case model.RegistratietypeOngedaanmaking:
+		if registratie.CorrigeertRegistratieID != nil {
+			return nieuweValidatieFout(http.StatusBadRequest, "een ongedaanmaking mag geen corrigeert_registratie_id hebben")
+		}
+		if len(request.Wijzigingen) > 0 {
+			return nieuweValidatieFout(http.StatusBadRequest, "een ongedaanmaking bevat geen wijzigingen")
+		}
+		return nil
+	default:
+		return nieuweValidatieFout(http.StatusBadRequest, "onbekend registratietype '%s'", registratie.Registratietype)
+	}
 }
 
 /*
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// afvoerVanV3 is een registratie met de afvoer van V3 bij A2.
+const afvoerVanV3 = `{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"afvoer": {"v": {"a_id": 2, "rel_id": 3}}}]}`
+
+// registreerVoorTest voert een POST /registratie/ uit tegen de (mock) database, met een vaste klok.
+// headers zijn paren van naam en waarde.
+func registreerVoorTest(t *testing.T, query string, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	oud := RegistratieKlok
+	RegistratieKlok = NieuweTestKlok(time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC), time.Hour)
+	t.Cleanup(func() { RegistratieKlok = oud })
+
+	router := gin.New()
+	router.POST("/registratie/", RegistreerMetNieuweAanpak())
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/registratie/"+query, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// verwachtAfvoerVanV3 verwacht de SQL van afvoerVanV3 als registratie 9, tot en met het ophalen van de wijzigingen
+// voor het antwoord: alleen A2 is geraakt, heeft daarna één U en twee V's en krijgt versie 9.
+// De kardinaliteit wordt alleen gecontroleerd met metVoorbeeldKardinaliteit.
+func verwachtAfvoerVanV3(mock sqlmock.Sqlmock, tijdstip time.Time) {
+	mock.ExpectBegin()
+	verwachtVergrendeling(mock, "A", 2)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectQuery(`INSERT INTO "registratie" .* RETURNING id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`UPDATE "a_v" SET afvoer = .*WHERE \(a_id = 2\) AND \(rel_id = 3\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	verwachtGeraakteEntiteitA2(mock)
+	mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`FROM "a_u" WHERE \(a_id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 1))
+	mock.ExpectQuery(`FROM "a_v" WHERE \(a_id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 2))
+	mock.ExpectExec(`INSERT INTO "entiteitversie" .*VALUES \('A', 2, 9\) ON CONFLICT \(typenaam, entiteit_id\) DO UPDATE SET registratie_id = EXCLUDED.registratie_id$`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(registratie_id = 9\) ORDER BY "id"`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).AddRow(21, "afvoer", 9, "A_V", "2", "3", tijdstip))
+}
+
+// verwachtGeraakteEntiteitA2 verwacht het bepalen van de geraakte entiteiten (van alle entiteittypen): alleen A2, via een V.
+func verwachtGeraakteEntiteitA2(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(opvoer = `).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_u"`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_v"`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "rel_a_b"`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT "id" FROM "b" WHERE \(opvoer = `).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "b_id" FROM "b_x"`).
+		WillReturnRows(sqlmock.NewRows([]string{"b_id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "b_id" FROM "b_y"`).
+		WillReturnRows(sqlmock.NewRows([]string{"b_id"}))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(tijdstip = .*\) AND \(wijzigingstype = 'materieel'\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+}
+
+func TestRegistreerMetNieuweAanpak_DryrunRollsBackAndReturnsPlannedWijzigingen(t *testing.T) {
+	// Given: A2 heeft een actieve V3.
+	// When: de afvoer van V3 met ?dryrun=true wordt aangeboden.
+	// Then: alle stappen en validaties lopen, de geplande wijzigingen komen terug (200) en de transactie wordt teruggedraaid.
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectRollback()
+
+	recorder := registreerVoorTest(t, "?dryrun=true", afvoerVanV3)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var antwoord struct {
+		Dryrun      bool `json:"dryrun"`
+		Wijzigingen []struct {
+			Wijzigingstype    string `json:"wijzigingstype"`
+			Representatienaam string `json:"representatienaam"`
+			RepresentatieID   string `json:"representatie_id"`
+		} `json:"wijzigingen"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !antwoord.Dryrun || len(antwoord.Wijzigingen) != 1 || antwoord.Wijzigingen[0].RepresentatieID != "3" {
+		t.Fatalf("expected the planned afvoer of V3, got %s", recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_ReturnFullRespondsWithWijzigingenAndResultingEntities(t *testing.T) {
+	// Given: A2 heeft een actieve U1 en actieve V3 en V4.
+	// When: de afvoer van V3 met ?return=full wordt vastgelegd.
+	// Then: het antwoord (201) bevat de registratie, de afvoer wijziging en A2 met alleen haar actieve U en V.
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+	opvoer := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectQuery(`SELECT .*FROM "a" WHERE \("a"\.id = 2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(2, opvoer))
+	mock.ExpectQuery(`SELECT .*FROM "a_u" .*\("a_u"\.opvoer IS NOT NULL\) AND \("a_u"\.afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id", "a_id", "aaa"}).AddRow(1, 2, "u"))
+	mock.ExpectQuery(`SELECT .*FROM "a_v" .*\("a_v"\.afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id", "a_id", "ccc"}).AddRow(4, 2, "v"))
+	mock.ExpectQuery(`SELECT .*FROM "rel_a_b" .*\("rel_a_b"\.afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "a_id", "b_id"}))
+	mock.ExpectCommit()
+
+	recorder := registreerVoorTest(t, "?return=full", afvoerVanV3)
+
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var antwoord struct {
+		Registratie struct {
+			ID int64 `json:"id"`
+		} `json:"registratie"`
+		Wijzigingen []struct {
+			Wijzigingstype  string `json:"wijzigingstype"`
+			RepresentatieID string `json:"representatie_id"`
+		} `json:"wijzigingen"`
+		Entiteiten map[string][]struct {
+			ID int `json:"id"`
+			Us []struct {
+				RelID int `json:"rel_id"`
+			} `json:"us"`
+			Vs []struct {
+				RelID int `json:"rel_id"`
+			} `json:"vs"`
+		} `json:"entiteiten"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if antwoord.Registratie.ID != 9 || len(antwoord.Wijzigingen) != 1 || antwoord.Wijzigingen[0].Wijzigingstype != "afvoer" {
+		t.Fatalf("expected registratie 9 with the afvoer wijziging, got %s", recorder.Body.String())
+	}
+	as := antwoord.Entiteiten["A"]
+	if len(as) != 1 || as[0].ID != 2 || len(as[0].Us) != 1 || len(as[0].Vs) != 1 || as[0].Vs[0].RelID != 4 {
+		t.Fatalf("expected A2 with U1 and V4, got %s", recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+// idempotentieKolommen zijn de kolommen van de tabel idempotentiesleutel.
+var idempotentieKolommen = []string{"sleutel", "request_hash", "registratie_id", "status", "antwoord", "tijdstip"}
+
+// hashVoorTest is de hash van een request zonder dryrun, return en If-Match.
+func hashVoorTest(body string) string {
+	return requestHashVan([]byte(body), "", "", "")
+}
+
+func TestRegistreerMetNieuweAanpak_IdempotencyKeyIsStoredWithTheRegistratie(t *testing.T) {
+	// Given: de Idempotency-Key "k1" is nog niet gebruikt.
+	// When: de afvoer van V3 met die sleutel wordt vastgelegd.
+	// Then: de sleutel wordt in dezelfde transactie vastgelegd met de hash van het request, registratie 9 en het antwoord.
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+		WillReturnRows(sqlmock.NewRows(idempotentieKolommen))
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectExec(`INSERT INTO "idempotentiesleutel" .*'k1', '` + hashVoorTest(afvoerVanV3) + `', 9, 201, '\{"message":"De registratie 9 is succesvol verwerkt.*ON CONFLICT \(sleutel\) DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3, IdempotencyKeyHeader, "k1")
+
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_IdempotencyKeyReplaysTheOriginalResponse(t *testing.T) {
+	// Given: de Idempotency-Key "k1" is gebruikt voor hetzelfde request.
+	// When: het request met die sleutel opnieuw wordt aangeboden.
+	// Then: het oorspronkelijke antwoord komt terug en er wordt niets geregistreerd.
+	mock := nieuweMockDB(t)
+	origineel := `{"message":"De registratie 9 is succesvol verwerkt","registratie":{"id":9}}`
+
+	mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+		WillReturnRows(sqlmock.NewRows(idempotentieKolommen).
+			AddRow("k1", hashVoorTest(afvoerVanV3), 9, http.StatusCreated, origineel, time.Now()))
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3, IdempotencyKeyHeader, "k1")
+
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != origineel {
+		t.Fatalf("expected the original 201 response, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("expected %s header", IdempotentReplayedHeader)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_IdempotencyKeyWithOtherPayloadIsConflict(t *testing.T) {
+	// Given: de Idempotency-Key "k1" is gebruikt voor een ander request.
+	// When: de afvoer van V3 met die sleutel wordt aangeboden.
+	// Then: er volgt een 409 en er wordt niets geregistreerd.
+	mock := nieuweMockDB(t)
+
+	mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+		WillReturnRows(sqlmock.NewRows(idempotentieKolommen).
+			AddRow("k1", hashVoorTest(`{"ander": "request"}`), 7, http.StatusCreated, `{}`, time.Now()))
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3, IdempotencyKeyHeader, "k1")
+
+	if recorder.Code != http.StatusConflict || !strings.Contains(recorder.Body.String(), "registratie 7") {
+		t.Fatalf("expected 409 naming registratie 7, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_IdempotencyKeyWithOtherOptionsIsConflict(t *testing.T) {
+	// Given: de Idempotency-Key "k1" is gebruikt voor de afvoer van V3 zonder opties.
+	// When: dezelfde body met die sleutel wordt aangeboden met ?return=full, of met een If-Match.
+	// Then: dat is een ander request (een ander antwoord): er volgt een 409 en er wordt niets geregistreerd.
+	for _, tc := range []struct {
+		naam    string
+		query   string
+		headers []string
+	}{
+		{naam: "return=full", query: "?return=full"},
+		{naam: "If-Match", headers: []string{"If-Match", `"A/2/8"`}},
+	} {
+		t.Run(tc.naam, func(t *testing.T) {
+			mock := nieuweMockDB(t)
+
+			mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+				WillReturnRows(sqlmock.NewRows(idempotentieKolommen).
+					AddRow("k1", hashVoorTest(afvoerVanV3), 9, http.StatusCreated, `{}`, time.Now()))
+
+			recorder := registreerVoorTest(t, tc.query, afvoerVanV3, append(tc.headers, IdempotencyKeyHeader, "k1")...)
+
+			if recorder.Code != http.StatusConflict || !strings.Contains(recorder.Body.String(), "registratie 9") {
+				t.Fatalf("expected 409 naming registratie 9, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet sql expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_ConcurrentIdempotencyKeyRollsBackAndReplays(t *testing.T) {
+	// Given: een gelijktijdig request met de Idempotency-Key "k1" legt de sleutel eerder vast.
+	// When: dit request de sleutel wil vastleggen.
+	// Then: deze registratie wordt teruggedraaid en het antwoord van het andere request komt terug.
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+	origineel := `{"message":"De registratie 8 is succesvol verwerkt","registratie":{"id":8}}`
+
+	mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+		WillReturnRows(sqlmock.NewRows(idempotentieKolommen))
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectExec(`INSERT INTO "idempotentiesleutel" .*ON CONFLICT \(sleutel\) DO NOTHING`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT .*FROM "idempotentiesleutel" WHERE \(sleutel = 'k1'\)`).
+		WillReturnRows(sqlmock.NewRows(idempotentieKolommen).
+			AddRow("k1", hashVoorTest(afvoerVanV3), 8, http.StatusCreated, origineel, time.Now()))
+	mock.ExpectRollback()
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3, IdempotencyKeyHeader, "k1")
+
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != origineel {
+		t.Fatalf("expected the response of registratie 8, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
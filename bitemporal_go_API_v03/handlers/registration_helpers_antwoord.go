@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== ANTWOORD OP EEN REGISTRATIE ===========================
+
+Het antwoord op POST /registratie/ bevat de vastgelegde registratie (met id en tijdstip) en alle wijzigingen
+die daarbij zijn vastgelegd, ook de impliciete: afvoer van een enkelvoudige voorganger, cascade bij afvoer
+van een entiteit, en de door de trigger toegekende rel_id's van nieuwe gegevenselementen.
+
+Met ?return=full staan daarnaast de resulterende full entiteiten in het antwoord (per entiteittype), met hun
+actieve gegevenselementen/relaties. Dat zijn de entiteiten die de registratie raakt, op dezelfde manier bepaald
+als bij de kardinaliteit (zie registration_helpers_kardinaliteit.go). Een afgevoerde entiteit staat er ook in,
+met haar afvoer.
+
+Alles wordt binnen de transactie opgehaald, vóór de commit; zo werkt het ook bij een dryrun.
+*/
+
+// RegistratieAntwoord is het antwoord op een geslaagde registratie (of dryrun).
+type RegistratieAntwoord struct {
+	Message     string                           `json:"message"`
+	Dryrun      bool                             `json:"dryrun,omitempty"`
+	Registratie model.Registratie                `json:"registratie"`
+	Wijzigingen []model.Wijziging                `json:"wijzigingen"`
+	Entiteiten  map[string][]model.Representatie `json:"entiteiten,omitempty"` // alleen bij ?return=full
+}
+
+// bouwRegistratieAntwoord haalt de wijzigingen van de registratie op en, als volledig is gevraagd,
+// de geraakte entiteiten.
+func bouwRegistratieAntwoord(c *gin.Context, tx bun.Tx, registratie model.Registratie,
+	geraakt geraakteEntiteiten, volledig bool) (RegistratieAntwoord, error) {
+	antwoord := RegistratieAntwoord{Registratie: registratie}
+
+	wijzigingen, err := haalWijzigingenVanRegistratieUitDB(c, tx, registratie.ID)
+	if err != nil {
+		return RegistratieAntwoord{}, err
+	}
+	antwoord.Wijzigingen = wijzigingen
+
+	if volledig {
+		entiteiten, err := haalVolledigeEntiteitenUitDB(c, tx, geraakt)
+		if err != nil {
+			return RegistratieAntwoord{}, err
+		}
+		antwoord.Entiteiten = entiteiten
+	}
+
+	return antwoord, nil
+}
+
+// haalVolledigeEntiteitenUitDB haalt de geraakte entiteiten op als full entiteit, met hun actieve gegevenselementen/relaties.
+// Een entiteit die niet (meer) bestaat, bijv. na ongedaanmaking van haar opvoer, wordt overgeslagen.
+func haalVolledigeEntiteitenUitDB(c *gin.Context, tx bun.Tx, geraakt geraakteEntiteiten) (map[string][]model.Representatie, error) {
+	entiteiten := map[string][]model.Representatie{}
+
+	for _, meta := range entiteittypen() {
+		for _, id := range geraakt.gesorteerd(meta.Typenaam) {
+			entiteit := meta.Factory()
+			query := tx.NewSelect().
+				Model(entiteit).
+				Where(fmt.Sprintf("?TableAlias.%s = ?", meta.IDKolom), id)
+			for _, rol := range meta.OnderliggendeGegevenselementen {
+				query = query.Relation(rol.Rolnaam, func(q *bun.SelectQuery) *bun.SelectQuery {
+					return q.Where("?TableAlias.opvoer IS NOT NULL").Where("?TableAlias.afvoer IS NULL")
+				})
+			}
+
+			err := query.Scan(c.Request.Context())
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("HANDLER: kon %s %d niet ophalen: %v", meta.Typenaam, id, err)
+			}
+			entiteiten[meta.Typenaam] = append(entiteiten[meta.Typenaam], entiteit)
+		}
+	}
+
+	return entiteiten, nil
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== CORRECTIE ===========================
+
+Een correctie is een registratie met Registratietype = correctie en een verwijzing
+naar de registratie die gecorrigeerd wordt (CorrigeertRegistratieID).
+
+VOORWAARDEN:
+- de te corrigeren registratie bestaat en is geen ongedaanmaking
+- het tijdstip van de correctie is later dan dat van de te corrigeren registratie
+
+Het request formaat is hetzelfde als bij een gewone registratie, bijv.:
+
+	{
+		"registratie": {"registratietype": "correctie", "corrigeert_registratie_id": 7, "opmerking": "Corrigeer U3 van A2"},
+		"wijzigingen": [
+			{"opvoer": {"a": {"id": 2, "us": [{"rel_id": 3, "aaa": "a2-correctie", "bbb": "b2-correctie"}]}}}
+		]
+	}
+
+ACTIES per gecorrigeerd gegevenselement/relatie (dus met een ID):
+ 1. controleer dat het in de te corrigeren registratie is opgevoerd en nog actief is
+ 2. voer het af met het correctietijdstip (inclusief wijziging record)
+ 3. voer de gecorrigeerde versie opnieuw op, met een nieuw ID (inclusief wijziging record)
+
+De wijziging records verwijzen naar de correctie, dus de betekenis "dit was een correctie"
+blijft bewaard in de registratie. Een entiteit zelf wordt niet afgevoerd en opnieuw opgevoerd; die dient
+als omhulsel voor haar onderliggende gegevenselementen/relaties. Wel kan haar materiële tijd (aanvang/einde)
+gecorrigeerd worden: als die is meegegeven, wordt die in place gewijzigd met een materieel wijziging record
+(zie registration_helpers_materieel.go).
+Representaties zonder ID in een correctie worden als aanvulling gewoon opgevoerd.
+Een afvoer in een correctie wordt als gewone afvoer verwerkt.
+*/
+
+// valideerCorrectie controleert de voorwaarden voor een correctie ten opzichte van de te corrigeren registratie.
+func valideerCorrectie(c *gin.Context, tx bun.Tx, correctie model.Registratie) error {
+	if correctie.CorrigeertRegistratieID == nil {
+		return nieuweValidatieFout(http.StatusBadRequest, "correctie zonder corrigeert_registratie_id")
+	}
+
+	teCorrigeren, gevonden, err := haalRegistratieUitDB(c, tx, *correctie.CorrigeertRegistratieID)
+	if err != nil {
+		return err
+	}
+	if !gevonden {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"te corrigeren registratie %d bestaat niet", *correctie.CorrigeertRegistratieID)
+	}
+	if teCorrigeren.Registratietype == model.RegistratietypeOngedaanmaking {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"registratie %d is een ongedaanmaking en kan niet gecorrigeerd worden", teCorrigeren.ID)
+	}
+	if !teCorrigeren.Tijdstip.Before(correctie.Tijdstip) {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"correctie (%s) moet later zijn dan de te corrigeren registratie %d (%s)",
+			correctie.Tijdstip.Format(time.RFC3339Nano), teCorrigeren.ID, teCorrigeren.Tijdstip.Format(time.RFC3339Nano))
+	}
+
+	return nil
+}
+
+// handleRepresentatieCorrectie verwerkt een opvoer binnen een correctie.
+func handleRepresentatieCorrectie(c *gin.Context, tx bun.Tx, registratieID int64, correctieTijdstip time.Time,
+	teCorrigerenRegistratieID int64, representatienaam string, representatie model.FormeleRepresentatie) error {
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatienaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: onbekend type voor correctie: %s", representatienaam)
+	}
+
+	if meta.Metatype != model.MetatypeEntiteit {
+		if isZeroID(representatie.GetID()) {
+			return handleRepresentatieOpvoerMeta(c, tx, registratieID, correctieTijdstip, representatienaam, representatie)
+		}
+		return corrigeerRepresentatie(c, tx, registratieID, correctieTijdstip, teCorrigerenRegistratieID, meta, representatie)
+	}
+
+	// de entiteit moet bestaan en actief zijn, maar wordt zelf niet afgevoerd
+	actief, err := isRepresentatieActief(c, tx, meta, representatie)
+	if err != nil {
+		return err
+	}
+	if !actief {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"%s %v bestaat niet of is afgevoerd en kan niet gecorrigeerd worden", representatienaam, representatie.GetID())
+	}
+
+	// alleen als aanvang of einde is meegegeven, corrigeren we de materiële tijd van de entiteit
+	if materieel, ok := representatie.(model.HeeftAanvangEinde); ok && meta.IsMaterieel &&
+		(materieel.GetAanvang() != nil || materieel.GetEinde() != nil) {
+		if err := valideerMaterieleTijd(meta, representatie); err != nil {
+			return err
+		}
+		if err := wijzigMaterieleTijd(c, tx, registratieID, correctieTijdstip, meta, representatie,
+			materieel.GetAanvang(), materieel.GetEinde()); err != nil {
+			return err
+		}
+	}
+
+	onderliggendeRepresentaties, ok := representatie.(model.HeeftOnderliggendeGegevenselementen)
+	if !ok {
+		return fmt.Errorf("HANDLER: type %s geeft geen onderliggende gegevenselementen vrij", representatienaam)
+	}
+
+	for _, onderliggende := range onderliggendeRepresentaties.GeefOnderliggendeGegevenselementen() {
+		if err := handleRepresentatieCorrectie(c, tx, registratieID, correctieTijdstip, teCorrigerenRegistratieID,
+			onderliggende.Typenaam, onderliggende.Representatie); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// corrigeerRepresentatie voert één gegevenselement/relatie af en voert de gecorrigeerde versie opnieuw op met een nieuw ID.
+func corrigeerRepresentatie(c *gin.Context, tx bun.Tx, registratieID int64, correctieTijdstip time.Time,
+	teCorrigerenRegistratieID int64, meta model.TypeMeta, representatie model.FormeleRepresentatie) error {
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+	oudID := sleutel.RepresentatieID
+
+	geregistreerd, err := isOpgevoerdInRegistratie(c, tx, teCorrigerenRegistratieID, sleutel)
+	if err != nil {
+		return err
+	}
+	if !geregistreerd {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"%s %s is niet opgevoerd in registratie %d en kan daarom niet via die registratie gecorrigeerd worden",
+			meta.Typenaam, oudID, teCorrigerenRegistratieID)
+	}
+
+	waar, err := waarRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.NewUpdate().
+		Table(meta.Tabelnaam).
+		Set("afvoer = ?", correctieTijdstip).
+		ApplyQueryBuilder(waar).
+		Where("afvoer IS NULL").
+		Where("opvoer IS NOT NULL").
+		Exec(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: failed to update %s afvoer: %v", meta.Typenaam, err)
+	}
+	if aantal, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("HANDLER: kon aantal afgevoerde %s records niet bepalen: %v", meta.Typenaam, err)
+	} else if aantal != 1 {
+		return nieuweValidatieFout(http.StatusConflict,
+			"%s %s is niet (meer) actief en kan niet gecorrigeerd worden", meta.Typenaam, oudID)
+	}
+
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
+		sleutel, correctieTijdstip); err != nil {
+		return err
+	}
+
+	if err := zetNieuwIDVoorHeropvoer(meta, representatie); err != nil {
+		return err
+	}
+
+	return handleRepresentatieOpvoerMeta(c, tx, registratieID, correctieTijdstip, meta.Typenaam, representatie)
+}
+
+// zetNieuwIDVoorHeropvoer laat de database de gecorrigeerde versie een nieuw ID geven: ID op 0 zetten volstaat.
+// Bij een relatieve autoincrement doet de trigger dat, anders de sequence van de ID kolom (zie dbsetup/migratie.go),
+// dus zonder een MAX(id) + 1 dat bij gelijktijdige correcties hetzelfde ID kan opleveren.
+func zetNieuwIDVoorHeropvoer(meta model.TypeMeta, representatie model.Representatie) error {
+	return zetIntWaardeVoorKolomInRepresentatie(representatie, meta.IDKolom, 0)
+}
+
+// haalRegistratieUitDB haalt een registratie op; gevonden is false als die niet bestaat.
+func haalRegistratieUitDB(c *gin.Context, tx bun.Tx, id int64) (model.Registratie, bool, error) {
+	var registratie model.Registratie
+	err := tx.NewSelect().
+		Model(&registratie).
+		Where("id = ?", id).
+		Scan(c.Request.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Registratie{}, false, nil
+	}
+	if err != nil {
+		return model.Registratie{}, false, fmt.Errorf("HANDLER: kon registratie %d niet ophalen: %v", id, err)
+	}
+
+	return registratie, true, nil
+}
+
+// isOpgevoerdInRegistratie bepaalt of een representatie is opgevoerd in een bepaalde registratie.
+func isOpgevoerdInRegistratie(c *gin.Context, tx bun.Tx, registratieID int64, sleutel model.RepresentatieSleutel) (bool, error) {
+	query := tx.NewSelect().
+		Model((*model.Wijziging)(nil)).
+		Where("registratie_id = ?", registratieID).
+		Where("representatienaam = ?", sleutel.Representatienaam).
+		Where("representatie_id = ?", sleutel.RepresentatieID).
+		Where("wijzigingstype = ?", model.WijzigingstypeOpvoer)
+	if sleutel.EntiteitID != "" {
+		query = query.Where("entiteit_id = ?", sleutel.EntiteitID)
+	}
+	exists, err := query.Exists(c.Request.Context())
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon wijzigingen van registratie %d niet raadplegen: %v", registratieID, err)
+	}
+
+	return exists, nil
+}
+
+// isRepresentatieActief bepaalt of een representatie bestaat, is opgevoerd en nog niet is afgevoerd.
+func isRepresentatieActief(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.Representatie) (bool, error) {
+	waar, err := waarRepresentatie(meta, representatie)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := tx.NewSelect().
+		Table(meta.Tabelnaam).
+		ApplyQueryBuilder(waar).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Exists(c.Request.Context())
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon %s %v niet raadplegen: %v", meta.Typenaam, representatie.GetID(), err)
+	}
+
+	return exists, nil
+}
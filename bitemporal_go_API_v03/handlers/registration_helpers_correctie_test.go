@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+var registratieKolommen = []string{"id", "registratietype", "tijdstip", "opmerking", "corrigeert_registratie_id", "maakt_ongedaan_registratie_id"}
+
+func TestValideerCorrectie_RejectsNonExistingRegistratie(t *testing.T) {
+	// Given: een correctie die verwijst naar een registratie die niet bestaat.
+	// When: de correctie wordt gevalideerd.
+	// Then: er volgt een 422 validatiefout.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*id = 7`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+
+	teCorrigeren := int64(7)
+	err := valideerCorrectie(ctx, tx, model.Registratie{
+		ID:                      9,
+		Registratietype:         model.RegistratietypeCorrectie,
+		Tijdstip:                time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		CorrigeertRegistratieID: &teCorrigeren,
+	})
+	if err == nil {
+		t.Fatal("expected error for missing registratie, got nil")
+	}
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status 422, got %d (%v)", status, err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestValideerCorrectie_RejectsLaterRegistratie(t *testing.T) {
+	// Given: de te corrigeren registratie is niet eerder dan de correctie.
+	// When: de correctie wordt gevalideerd.
+	// Then: er volgt een 422 validatiefout.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*id = 7`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(7, "registratie", tijdstip.Add(time.Hour), nil, nil, nil))
+
+	teCorrigeren := int64(7)
+	err := valideerCorrectie(ctx, tx, model.Registratie{
+		ID:                      9,
+		Registratietype:         model.RegistratietypeCorrectie,
+		Tijdstip:                tijdstip,
+		CorrigeertRegistratieID: &teCorrigeren,
+	})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestCorrigeerRepresentatie_AfvoerEnHeropvoerMetNieuwRelID(t *testing.T) {
+	// Given: U3 van A2 is opgevoerd in registratie 7 en nog actief.
+	// When: registratie 9 corrigeert U3.
+	// Then: U3 wordt afgevoerd en de gecorrigeerde U opnieuw opgevoerd met een nieuw rel_id,
+	//       beide met een wijziging record bij de correctie.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	meta := model.MetaRegistry.MustTypeMeta("A_U")
+	representatie := &model.A_U{A_ID: 2, Rel_ID: 3, Aaa: "a2-correctie", Bbb: "b2-correctie"}
+	tijdstip := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS .*FROM "wijziging".*registratie_id = 7.*representatie_id = '3'`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = .*a_id = 2.*rel_id = 3.*afvoer IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'afvoer'.*'A_U', '2', '3'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	// heropvoer: geen actieve voorganger meer, insert met rel_id via trigger
+	mock.ExpectQuery(`SELECT .*FROM "a_u".*afvoer IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`INSERT INTO "a_u"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}).AddRow(4))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'opvoer'.*'A_U', '2', '4'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(22))
+
+	err := corrigeerRepresentatie(ctx, tx, 9, tijdstip, 7, meta, representatie)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if representatie.Rel_ID != 4 {
+		t.Fatalf("expected new rel_id 4, got %d", representatie.Rel_ID)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestCorrigeerRepresentatie_HeropvoerZonderPFKKrijgtIDUitSequence(t *testing.T) {
+	// Given: Rel_A_B 5 van A1 naar B7 is opgevoerd in registratie 7 en nog actief.
+	// When: registratie 9 corrigeert relatie 5.
+	// Then: de gecorrigeerde relatie wordt zonder id ingevoegd en krijgt het id van de database (sequence);
+	//       er wordt geen MAX(id) bepaald.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	meta := model.MetaRegistry.MustTypeMeta("Rel_A_B")
+	representatie := &model.Rel_A_B{ID: 5, A_ID: 1, B_ID: 7}
+	tijdstip := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS .*FROM "wijziging".*registratie_id = 7.*representatie_id = '5'`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "rel_a_b" SET afvoer = .*id = 5.*afvoer IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'afvoer'.*'Rel_A_B', DEFAULT, '5'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "b" WHERE \(id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`INSERT INTO "rel_a_b" \("id", .*VALUES \(DEFAULT, .*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'opvoer'.*'Rel_A_B', DEFAULT, '8'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(22))
+
+	err := corrigeerRepresentatie(ctx, tx, 9, tijdstip, 7, meta, representatie)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if representatie.ID != 8 {
+		t.Fatalf("expected new id 8 from the sequence, got %d", representatie.ID)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestCorrigeerRepresentatie_NaOpvoerMetMeegegevenIDGeenBestaandID(t *testing.T) {
+	// Given: Rel_A_B 12 van A1 naar B7 wordt opgevoerd met een door de client meegegeven id.
+	// When: dezelfde relatie daarna (registratie 9) wordt gecorrigeerd.
+	// Then: na de opvoer wordt de sequence bijgezet tot minstens 12, zodat de heropvoer bij de correctie
+	//       een id uit de sequence krijgt dat niet al bestaat.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	meta := model.MetaRegistry.MustTypeMeta("Rel_A_B")
+	tijdstip := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "b" WHERE \(id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`INSERT INTO "rel_a_b" \("id", .*VALUES \(12, `).
+		WillReturnRows(sqlmock.NewRows([]string{"afvoer"}))
+	mock.ExpectExec(`SELECT setval\('rel_a_b_id_seq', GREATEST\(12, \(SELECT last_value FROM "rel_a_b_id_seq"\)\)\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'opvoer'.*'Rel_A_B', DEFAULT, '12'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(20))
+
+	if err := handleRepresentatieOpvoerMeta(ctx, tx, 8, tijdstip.Add(-time.Hour), "Rel_A_B",
+		&model.Rel_A_B{ID: 12, A_ID: 1, B_ID: 7}); err != nil {
+		t.Fatalf("expected no error on opvoer, got: %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT EXISTS .*FROM "wijziging".*registratie_id = 8.*representatie_id = '12'`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "rel_a_b" SET afvoer = .*id = 12.*afvoer IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'afvoer'.*'Rel_A_B', DEFAULT, '12'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "b" WHERE \(id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`INSERT INTO "rel_a_b" \("id", .*VALUES \(DEFAULT, .*RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(13))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'opvoer'.*'Rel_A_B', DEFAULT, '13'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(22))
+
+	correctie := &model.Rel_A_B{ID: 12, A_ID: 1, B_ID: 7}
+	if err := corrigeerRepresentatie(ctx, tx, 9, tijdstip, 8, meta, correctie); err != nil {
+		t.Fatalf("expected no error on correctie, got: %v", err)
+	}
+	if correctie.ID != 13 {
+		t.Fatalf("expected new id 13 from the sequence, got %d", correctie.ID)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestCorrigeerRepresentatie_RejectsAfgevoerdGegevenselement(t *testing.T) {
+	// Given: U3 is opgevoerd in registratie 7 maar inmiddels afgevoerd.
+	// When: registratie 9 corrigeert U3.
+	// Then: er volgt een 409 validatiefout en er wordt niets opgevoerd.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	meta := model.MetaRegistry.MustTypeMeta("A_U")
+	representatie := &model.A_U{A_ID: 2, Rel_ID: 3}
+	tijdstip := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS .*FROM "wijziging"`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = `).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := corrigeerRepresentatie(ctx, tx, 9, tijdstip, 7, meta, representatie)
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
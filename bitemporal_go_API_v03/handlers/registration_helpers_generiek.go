@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 
@@ -36,6 +38,17 @@ func handleRepresentatieOpvoerMetReflectie(c *gin.Context, tx bun.Tx, registrati
 	// dit is de basis insert van 1 element, maar relaties gaan niet vanzelf mee, dus die moeten we apart behandelen (zie handleOpvoerA en handleOpvoerB)
 	// ook moet er per gegevenselement/relatie een wijziging record worden gemaakt,
 	//  dus dat doen we ook niet automatisch in de database, maar apart in de code (zie handleOpvoerElement)
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatienaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: onbekend type voor opvoer: %s", representatienaam)
+	}
+	if err := valideerMaterieleTijd(meta, representatie); err != nil {
+		return err
+	}
+	if err := valideerRelatieEindpunten(c, tx, meta, representatie, opvoerTijdstip); err != nil {
+		return err
+	}
+
 	representatie.SetOpvoer(&opvoerTijdstip)
 
 	// insert de top level representatie, dat moet namelijk sowieso
@@ -64,8 +77,11 @@ func handleRepresentatieOpvoerMetReflectie(c *gin.Context, tx bun.Tx, registrati
 	}
 
 	// Maak wijziging record aan
-	return persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID,
-		representatienaam, fmt.Sprint(representatie.GetID()), opvoerTijdstip)
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+	return persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, sleutel, opvoerTijdstip)
 }
 
 // handleRepresentatieOpvoerMeta verwerkt opvoer via de metaregistry, zonder reflectie.
@@ -86,12 +102,21 @@ func handleRepresentatieOpvoerMeta(c *gin.Context, tx bun.Tx, registratieID int6
 
 	- vinden: bovenliggende tabel...
 	*/
+	if err := valideerMaterieleTijd(meta, representatie); err != nil {
+		return err
+	}
+	if err := valideerRelatieEindpunten(c, tx, meta, representatie, opvoerTijdstip); err != nil {
+		return err
+	}
+
 	if meta.Metatype != model.MetatypeEntiteit {
 		if err := sluitActieveEnkelvoudigeVoorgangersAf(c, tx, registratieID, opvoerTijdstip, representatienaam, representatie, meta); err != nil {
 			return err
 		}
 	}
 
+	// een meegegeven ID gaat buiten de sequence om: zet die daarna bij, anders geeft ze dit ID later nog eens uit
+	meegegevenID, _ := representatie.GetID().(int)
 	representatie.SetOpvoer(&opvoerTijdstip)
 	_, err := tx.NewInsert().
 		Model(representatie).
@@ -99,9 +124,17 @@ func handleRepresentatieOpvoerMeta(c *gin.Context, tx bun.Tx, registratieID int6
 	if err != nil {
 		return fmt.Errorf("HANDLER: failed to insert %s: %v", representatienaam, err)
 	}
+	if meta.HeeftIDSequence() && meegegevenID != 0 {
+		if err := synchroniseerIDSequence(c, tx, meta, meegegevenID); err != nil {
+			return err
+		}
+	}
 
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID,
-		representatienaam, fmt.Sprint(representatie.GetID()), opvoerTijdstip); err != nil {
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, sleutel, opvoerTijdstip); err != nil {
 		return err
 	}
 
@@ -145,22 +178,26 @@ func handleRepresentatieAfvoer(c *gin.Context, tx bun.Tx, registratieID int64, a
 		return fmt.Errorf("HANDLER: onbekend type voor afvoer: %s", representatienaam)
 	}
 
-	if meta.Metatype != model.MetatypeEntiteit {
-		if err := updateAfvoerByID(c, tx, meta, representatie.GetID(), afvoerTijdstip); err != nil {
-			return err
-		}
-		return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
-			representatienaam, fmt.Sprint(representatie.GetID()), afvoerTijdstip)
+	// de representatie moet bestaan en actief zijn; bij een PFK ook binnen de opgegeven entiteit
+	waar, err := waarRepresentatie(meta, representatie)
+	if err != nil {
+		return err
 	}
-
-	if err := updateAfvoerByID(c, tx, meta, representatie.GetID(), afvoerTijdstip); err != nil {
+	if err := updateAfvoerByID(c, tx, meta, representatie.GetID(), waar, afvoerTijdstip); err != nil {
+		return err
+	}
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
 		return err
 	}
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
-		representatienaam, fmt.Sprint(representatie.GetID()), afvoerTijdstip); err != nil {
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutel, afvoerTijdstip); err != nil {
 		return err
 	}
 
+	if meta.Metatype != model.MetatypeEntiteit {
+		return nil
+	}
+
 	// nodig omdat nu alle gegevenselementen/relaties van een entiteit een int ID_NAAR_ENTITEIT veld hebben?
 	entiteitID, ok := anyNaarInt(representatie.GetID()) // hulpfunctie om de ID als int te krijgen, ongeacht het type
 	if !ok {
@@ -183,32 +220,78 @@ func handleRepresentatieAfvoer(c *gin.Context, tx bun.Tx, registratieID int64, a
 			return err
 		}
 
-		for _, id := range activeIDs {
-			if err := updateAfvoerByID(c, tx, childMeta, id, afvoerTijdstip); err != nil {
-				return err
-			}
-			if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
-				childMeta.Typenaam, fmt.Sprint(id), afvoerTijdstip); err != nil {
-				return err
-			}
+		// afvoerbeleid: zie registration_helpers_relaties.go
+		if err := pasAfvoerbeleidToe(c, tx, registratieID, afvoerTijdstip, meta, entiteitID,
+			childMeta, rel.Afvoerbeleid, fkColumn, activeIDs); err != nil {
+			return err
 		}
 	}
 
-	return nil
+	// relaties die vanaf een andere entiteit naar deze entiteit verwijzen (bijv. Rel_A_B bij afvoer van B)
+	return handleAfvoerVerwijzendeRelaties(c, tx, registratieID, afvoerTijdstip, meta, entiteitID)
 
 }
 
-func updateAfvoerByID(c *gin.Context, tx bun.Tx, meta model.TypeMeta, id any, afvoerTijdstip time.Time) error {
-	_, err := tx.NewUpdate().
+// updateAfvoerByID voert precies één representatie af (waar bepaalt welke, id is alleen voor de foutmelding).
+// De representatie moet bestaan, uiterlijk op het afvoertijdstip zijn opgevoerd en nog niet zijn afgevoerd;
+// anders volgt een 404 (bestaat niet) of 409 (niet actief), zodat er geen afvoer wijziging wordt vastgelegd.
+func updateAfvoerByID(c *gin.Context, tx bun.Tx, meta model.TypeMeta, id any,
+	waar func(bun.QueryBuilder) bun.QueryBuilder, afvoerTijdstip time.Time) error {
+	result, err := tx.NewUpdate().
 		Table(meta.Tabelnaam).
 		Set("afvoer = ?", afvoerTijdstip).
-		Where(fmt.Sprintf("%s = ?", meta.IDKolom), id).
+		ApplyQueryBuilder(waar).
+		Where("opvoer <= ?", afvoerTijdstip).
+		Where("afvoer IS NULL").
 		Exec(c.Request.Context())
 	if err != nil {
 		return fmt.Errorf("HANDLER: failed to update %s afvoer: %v", meta.Typenaam, err)
 	}
 
-	return nil
+	aantal, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon aantal afgevoerde %s records niet bepalen: %v", meta.Typenaam, err)
+	}
+	switch {
+	case aantal == 1:
+		return nil
+	case aantal > 1:
+		return fmt.Errorf("HANDLER: afvoer van %s %v raakte %d records (één verwacht)", meta.Typenaam, id, aantal)
+	}
+
+	return redenVoorMislukteAfvoer(c, tx, meta, id, waar, afvoerTijdstip)
+}
+
+// redenVoorMislukteAfvoer bepaalt waarom een afvoer niets heeft afgevoerd.
+func redenVoorMislukteAfvoer(c *gin.Context, tx bun.Tx, meta model.TypeMeta, id any,
+	waar func(bun.QueryBuilder) bun.QueryBuilder, afvoerTijdstip time.Time) error {
+	var huidig struct {
+		Opvoer *time.Time `bun:"opvoer"`
+		Afvoer *time.Time `bun:"afvoer"`
+	}
+	err := tx.NewSelect().
+		Table(meta.Tabelnaam).
+		Column("opvoer", "afvoer").
+		ApplyQueryBuilder(waar).
+		Limit(1).
+		Scan(c.Request.Context(), &huidig)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nieuweValidatieFout(http.StatusNotFound, "%s %v bestaat niet en kan niet afgevoerd worden", meta.Typenaam, id)
+	}
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon %s %v niet raadplegen: %v", meta.Typenaam, id, err)
+	}
+
+	switch {
+	case huidig.Afvoer != nil:
+		return nieuweValidatieFout(http.StatusConflict, "%s %v is al afgevoerd op %s",
+			meta.Typenaam, id, huidig.Afvoer.Format(time.RFC3339Nano))
+	case huidig.Opvoer == nil:
+		return nieuweValidatieFout(http.StatusConflict, "%s %v is niet opgevoerd en kan niet afgevoerd worden", meta.Typenaam, id)
+	default:
+		return nieuweValidatieFout(http.StatusConflict, "%s %v is pas opgevoerd op %s, na het afvoertijdstip %s",
+			meta.Typenaam, id, huidig.Opvoer.Format(time.RFC3339Nano), afvoerTijdstip.Format(time.RFC3339Nano))
+	}
 }
 
 func haalActieveIDsGegevenselementUitDB(c *gin.Context, tx bun.Tx, meta model.TypeMeta, fkColumn string, entiteitID int) ([]int, error) {
@@ -217,6 +300,7 @@ func haalActieveIDsGegevenselementUitDB(c *gin.Context, tx bun.Tx, meta model.Ty
 		Table(meta.Tabelnaam).
 		Column(meta.IDKolom).
 		Where(fmt.Sprintf("%s = ?", fkColumn), entiteitID).
+		Where("opvoer IS NOT NULL"). // een ongedaan gemaakte opvoer (opvoer leeg) is niet actief
 		Where("afvoer IS NULL")
 	if err := query.Scan(c.Request.Context(), &ids); err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("HANDLER: failed to query active %s records: %v", meta.Typenaam, err)
@@ -225,17 +309,59 @@ func haalActieveIDsGegevenselementUitDB(c *gin.Context, tx bun.Tx, meta model.Ty
 	return ids, nil
 }
 
+// haalOverlappendeActieveIDsGegevenselementUitDB geeft de actieve records van een materieel gegevenselement bij een entiteit
+// waarvan de periode [aanvang, einde) overlapt met die van de nieuwe representatie.
+func haalOverlappendeActieveIDsGegevenselementUitDB(c *gin.Context, tx bun.Tx, meta model.TypeMeta, fkColumn string, entiteitID int,
+	representatie model.FormeleRepresentatie) ([]int, error) {
+	var aanvang, einde *time.Time
+	if materieel, ok := representatie.(model.HeeftAanvangEinde); ok {
+		aanvang, einde = materieel.GetAanvang(), materieel.GetEinde()
+	}
+	return haalActieveIDsMetOverlapUitDB(c, tx, meta, fkColumn, entiteitID, aanvang, einde)
+}
+
+// haalActieveIDsMetOverlapUitDB geeft de IDs van de actieve records bij de entiteit waarvan de periode overlapt met [aanvang, einde).
+func haalActieveIDsMetOverlapUitDB(c *gin.Context, tx bun.Tx, meta model.TypeMeta, fkColumn string, entiteitID int,
+	aanvang *time.Time, einde *time.Time) ([]int, error) {
+	var actieve []struct {
+		ID      int        `bun:"id"`
+		Aanvang *time.Time `bun:"aanvang"`
+		Einde   *time.Time `bun:"einde"`
+	}
+	err := tx.NewSelect().
+		Table(meta.Tabelnaam).
+		ColumnExpr("? AS id", bun.Ident(meta.IDKolom)).
+		Column("aanvang", "einde").
+		Where(fmt.Sprintf("%s = ?", fkColumn), entiteitID).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Scan(c.Request.Context(), &actieve)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("HANDLER: failed to query active %s records: %v", meta.Typenaam, err)
+	}
+
+	ids := make([]int, 0, len(actieve))
+	for _, record := range actieve {
+		if model.PeriodesOverlappen(record.Aanvang, record.Einde, aanvang, einde) {
+			ids = append(ids, record.ID)
+		}
+	}
+
+	return ids, nil
+}
+
 /*
 ===== Maak wijziging aan in wijzigingstabel ======
 */
 func persisteerWijziging(c *gin.Context, tx bun.Tx, wijzigingstype model.WijzigingstypeEnum,
-	registratieID int64, representatienaam string, representatieID string, registratietijdstip time.Time) error {
+	registratieID int64, sleutel model.RepresentatieSleutel, registratietijdstip time.Time) error {
 	wijziging := model.Wijziging{
 		Wijzigingstype:    wijzigingstype,
 		RegistratieID:     registratieID,
-		Representatienaam: representatienaam,
-		RepresentatieID:   representatieID,     // Now directly using string
-		Tijdstip:          registratietijdstip, //afgeleid van registratie tijdstip
+		Representatienaam: sleutel.Representatienaam,
+		EntiteitID:        sleutel.EntiteitID,      // alleen gevuld bij een PFK
+		RepresentatieID:   sleutel.RepresentatieID, // Now directly using string
+		Tijdstip:          registratietijdstip,     //afgeleid van registratie tijdstip
 	}
 
 	_, err := tx.NewInsert().
@@ -299,22 +425,28 @@ func sluitActieveEnkelvoudigeVoorgangersAf(c *gin.Context, tx bun.Tx, registrati
 		return fmt.Errorf("HANDLER: bovenliggende %s id ontbreekt voor %s", bovenliggendeRelatieMeta.ParentType.Typenaam, representatienaam)
 	}
 
-	activeIDs, err := haalActieveIDsGegevenselementUitDB(c, tx, meta, fkColumn, entiteitID)
+	var activeIDs []int
+	if meta.IsMaterieel {
+		// materieel: alleen voorgangers waarvan de periode overlapt met die van de nieuwe waarde
+		activeIDs, err = haalOverlappendeActieveIDsGegevenselementUitDB(c, tx, meta, fkColumn, entiteitID, representatie)
+	} else {
+		activeIDs, err = haalActieveIDsGegevenselementUitDB(c, tx, meta, fkColumn, entiteitID)
+	}
 	if err != nil {
 		return err
 	}
 
-	if len(activeIDs) > 1 {
+	if len(activeIDs) > 1 && !meta.IsMaterieel {
 		return fmt.Errorf("HANDLER: meerdere actieve %s records gevonden voor %s=%d (enkelvoudig verwacht)",
 			representatienaam, fkColumn, entiteitID)
 	}
 
 	for _, id := range activeIDs {
-		if err := updateAfvoerByID(c, tx, meta, id, registratietijdstip); err != nil {
+		if err := updateAfvoerByID(c, tx, meta, id, waarRepresentatieBijEntiteit(meta, fkColumn, entiteitID, id), registratietijdstip); err != nil {
 			return err
 		}
 		if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
-			representatienaam, fmt.Sprint(id), registratietijdstip); err != nil {
+			sleutelBijEntiteit(meta, fkColumn, entiteitID, id), registratietijdstip); err != nil {
 			return err
 		}
 	}
@@ -323,15 +455,50 @@ func sluitActieveEnkelvoudigeVoorgangersAf(c *gin.Context, tx bun.Tx, registrati
 }
 
 func haalIntWaardeVoorKolomUitRepresentatie(representatie any, kolomnaam string) (int, error) {
+	veld, veldnaam, err := vindVeldVoorKolomInRepresentatie(representatie, kolomnaam)
+	if err != nil {
+		return 0, err
+	}
+
+	result, ok := anyNaarInt(veld.Interface())
+	if !ok {
+		return 0, fmt.Errorf("veld %s is geen integer", veldnaam)
+	}
+	return result, nil
+}
+
+// zetIntWaardeVoorKolomInRepresentatie is de tegenhanger van haalIntWaardeVoorKolomUitRepresentatie,
+// bijv. om een nieuw ID te zetten bij de heropvoer van een gecorrigeerd gegevenselement.
+func zetIntWaardeVoorKolomInRepresentatie(representatie any, kolomnaam string, waarde int) error {
+	veld, veldnaam, err := vindVeldVoorKolomInRepresentatie(representatie, kolomnaam)
+	if err != nil {
+		return err
+	}
+	if !veld.CanSet() {
+		return fmt.Errorf("veld %s kan niet gezet worden", veldnaam)
+	}
+
+	switch veld.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		veld.SetInt(int64(waarde))
+		return nil
+	default:
+		return fmt.Errorf("veld %s is geen integer", veldnaam)
+	}
+}
+
+// vindVeldVoorKolomInRepresentatie zoekt het struct veld dat hoort bij een kolomnaam,
+// op basis van de veldnaam, de json tag of de bun tag.
+func vindVeldVoorKolomInRepresentatie(representatie any, kolomnaam string) (reflect.Value, string, error) {
 	value := reflect.ValueOf(representatie)
 	for value.Kind() == reflect.Pointer {
 		if value.IsNil() {
-			return 0, fmt.Errorf("lege representatie")
+			return reflect.Value{}, "", fmt.Errorf("lege representatie")
 		}
 		value = value.Elem()
 	}
 	if value.Kind() != reflect.Struct {
-		return 0, fmt.Errorf("representatie is geen struct")
+		return reflect.Value{}, "", fmt.Errorf("representatie is geen struct")
 	}
 
 	typeInfo := value.Type()
@@ -347,16 +514,11 @@ func haalIntWaardeVoorKolomUitRepresentatie(representatie any, kolomnaam string)
 		if normalizeVeldnaam(fieldType.Name) == normalizedKolom ||
 			normalizeVeldnaam(firstTagValue(fieldType.Tag.Get("json"))) == normalizedKolom ||
 			normalizeVeldnaam(firstTagValue(fieldType.Tag.Get("bun"))) == normalizedKolom {
-
-			result, ok := anyNaarInt(fieldValue.Interface())
-			if !ok {
-				return 0, fmt.Errorf("veld %s is geen integer", fieldType.Name)
-			}
-			return result, nil
+			return fieldValue, fieldType.Name, nil
 		}
 	}
 
-	return 0, fmt.Errorf("kolom %s niet gevonden in representatie", kolomnaam)
+	return reflect.Value{}, "", fmt.Errorf("kolom %s niet gevonden in representatie", kolomnaam)
 }
 
 func firstTagValue(tag string) string {
@@ -370,3 +532,87 @@ func firstTagValue(tag string) string {
 func normalizeVeldnaam(veld string) string {
 	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(veld)), "_", "")
 }
+
+// waarRepresentatie beperkt een query tot precies één representatie.
+// Bij gegevenselementen met een PFK is het (relatieve) ID alleen uniek binnen de bovenliggende entiteit,
+// dus dan wordt ook op de FK naar die entiteit gefilterd.
+func waarRepresentatie(meta model.TypeMeta, representatie model.Representatie) (func(bun.QueryBuilder) bun.QueryBuilder, error) {
+	id := representatie.GetID()
+	if !meta.HeeftPFK {
+		return func(q bun.QueryBuilder) bun.QueryBuilder {
+			return q.Where(fmt.Sprintf("%s = ?", meta.IDKolom), id)
+		}, nil
+	}
+
+	entiteitID, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.EntiteitIDKolom)
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", meta.EntiteitIDKolom, meta.Typenaam, err)
+	}
+	if entiteitID == 0 {
+		return nil, nieuweValidatieFout(http.StatusBadRequest, "%s ontbreekt voor %s %v", meta.EntiteitIDKolom, meta.Typenaam, id)
+	}
+
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		return q.
+			Where(fmt.Sprintf("%s = ?", meta.EntiteitIDKolom), entiteitID).
+			Where(fmt.Sprintf("%s = ?", meta.IDKolom), id)
+	}, nil
+}
+
+// waarRepresentatieBijEntiteit beperkt een query tot de representatie met dit ID bij een entiteit (via de FK naar die entiteit).
+func waarRepresentatieBijEntiteit(meta model.TypeMeta, fkColumn string, entiteitID int, id any) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		return q.
+			Where(fmt.Sprintf("%s = ?", meta.IDKolom), id).
+			Where(fmt.Sprintf("%s = ?", fkColumn), entiteitID)
+	}
+}
+
+// waarSleutel beperkt een query tot de representatie met de sleutel zoals vastgelegd in een wijziging record.
+func waarSleutel(meta model.TypeMeta, sleutel model.RepresentatieSleutel) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		q = q.Where(fmt.Sprintf("%s = ?", meta.IDKolom), sleutel.RepresentatieID)
+		if sleutel.EntiteitID != "" {
+			q = q.Where(fmt.Sprintf("%s = ?", meta.EntiteitIDKolom), sleutel.EntiteitID)
+		}
+		return q
+	}
+}
+
+// synchroniseerIDSequence zet de sequence van de ID kolom (zie dbsetup/migratie.go) minstens op een meegegeven ID,
+// zodat een record zonder ID (bijv. de heropvoer bij een correctie) daarna geen bestaand ID krijgt.
+// setval is niet transactioneel: ook na een rollback blijft het ID overgeslagen, net als bij nextval.
+func synchroniseerIDSequence(c *gin.Context, tx bun.Tx, meta model.TypeMeta, id int) error {
+	_, err := tx.ExecContext(c.Request.Context(), "SELECT setval(?, GREATEST(?, (SELECT last_value FROM ?)))",
+		meta.IDSequence(), id, bun.Ident(meta.IDSequence()))
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon de sequence van %s niet bijzetten: %v", meta.Typenaam, err)
+	}
+	return nil
+}
+
+// sleutelVoorRepresentatie geeft de sleutel waaronder een representatie in het logboek (wijziging) staat.
+// Bij een PFK hoort daar het ID van de bovenliggende entiteit bij.
+func sleutelVoorRepresentatie(meta model.TypeMeta, representatie model.HasID) (model.RepresentatieSleutel, error) {
+	sleutel := model.RepresentatieSleutel{Representatienaam: meta.Typenaam, RepresentatieID: fmt.Sprint(representatie.GetID())}
+	if !meta.HeeftPFK {
+		return sleutel, nil
+	}
+
+	entiteitID, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.EntiteitIDKolom)
+	if err != nil {
+		return model.RepresentatieSleutel{}, fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", meta.EntiteitIDKolom, meta.Typenaam, err)
+	}
+	sleutel.EntiteitID = fmt.Sprint(entiteitID)
+	return sleutel, nil
+}
+
+// sleutelBijEntiteit geeft de sleutel van de representatie met dit ID bij een entiteit (via fkColumn).
+// Alleen als fkColumn de PFK is, hoort het ID van de entiteit bij de sleutel.
+func sleutelBijEntiteit(meta model.TypeMeta, fkColumn string, entiteitID any, id any) model.RepresentatieSleutel {
+	sleutel := model.RepresentatieSleutel{Representatienaam: meta.Typenaam, RepresentatieID: fmt.Sprint(id)}
+	if meta.HeeftPFK && fkColumn == meta.EntiteitIDKolom {
+		sleutel.EntiteitID = fmt.Sprint(entiteitID)
+	}
+	return sleutel
+}
@@ -172,3 +172,67 @@ func TestSluitActieveEnkelvoudigeVoorgangersAf_ErrorsOnMissingParentID(t *testin
 		t.Fatalf("unmet sql expectations: %v", err)
 	}
 }
+
+func TestHandleRepresentatieAfvoer_RejectsNonExistentRepresentation(t *testing.T) {
+	// Given: U7 bestaat niet bij A1.
+	// When: U7 wordt afgevoerd.
+	// Then: er volgt een 404 en er wordt geen afvoer wijziging vastgelegd.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = .*WHERE \(a_id = 1\) AND \(rel_id = 7\) AND \(opvoer <= '2026-02-25 10:00:00\+00:00'\) AND \(afvoer IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT "opvoer", "afvoer" FROM "a_u" WHERE \(a_id = 1\) AND \(rel_id = 7\) LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"opvoer", "afvoer"}))
+
+	err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A_U", &model.A_U{A_ID: 1, Rel_ID: 7})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusNotFound {
+		t.Fatalf("expected 404 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_RejectsAlreadyAfgevoerdRepresentation(t *testing.T) {
+	// Given: U5 bij A1 is al afgevoerd om 09:00.
+	// When: U5 om 10:00 nogmaals wordt afgevoerd.
+	// Then: er volgt een 409 die het oorspronkelijke afvoertijdstip noemt; dat blijft staan.
+	ctx, tx, mock := nieuweMockTx(t)
+	opvoer := time.Date(2026, 2, 25, 8, 0, 0, 0, time.UTC)
+	afvoer := time.Date(2026, 2, 25, 9, 0, 0, 0, time.UTC)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = .*AND \(afvoer IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT "opvoer", "afvoer" FROM "a_u"`).
+		WillReturnRows(sqlmock.NewRows([]string{"opvoer", "afvoer"}).AddRow(opvoer, afvoer))
+
+	err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A_U", &model.A_U{A_ID: 1, Rel_ID: 5})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "al afgevoerd op 2026-02-25T09:00:00Z") {
+		t.Fatalf("expected the original afvoer in the message, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_RecordsFullKeyInWijziging(t *testing.T) {
+	// Given: U1 bestaat bij A2 (en ook bij andere A's, want het rel_id is relatief).
+	// When: U1 bij A2 wordt afgevoerd.
+	// Then: alleen U1 van A2 wordt afgevoerd en de wijziging legt a_id 2 én rel_id 1 vast.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = .*WHERE \(a_id = 2\) AND \(rel_id = 1\) AND`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging" .*VALUES \(DEFAULT, 'afvoer', 42, 'A_U', '2', '1', `).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+
+	if err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A_U", &model.A_U{A_ID: 2, Rel_ID: 1}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== IDEMPOTENTIE ===========================
+
+Een client kan bij POST /registratie/ een Idempotency-Key header meesturen. Bij een geslaagde registratie
+wordt de sleutel in dezelfde transactie vastgelegd (tabel idempotentiesleutel), met de SHA-256 van het request,
+de registratie en het antwoord. Dat overleeft dus een herstart.
+Het request is alles wat het antwoord bepaalt: de body, de query parameters dryrun en return en de If-Match header.
+
+Een herhaling met dezelfde sleutel:
+- en hetzelfde request: krijgt het oorspronkelijke antwoord terug (met header Idempotent-Replayed: true), zonder nieuwe registratie
+- en een ander request (andere body, ?return=full of If-Match): 409
+
+Alleen geslaagde registraties leggen een sleutel vast; na een fout (4xx/5xx) kan het request met dezelfde sleutel
+opnieuw worden aangeboden. Een dryrun kijkt niet naar de sleutel.
+
+Twee gelijktijdige requests met dezelfde sleutel: de insert van de sleutel in de tweede transactie wacht op de eerste.
+Is die gecommit, dan wordt de tweede registratie teruggedraaid en krijgt ook zij het antwoord van de eerste.
+*/
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotentieSleutelLen = 255
+)
+
+// leesIdempotentie leest de Idempotency-Key header en berekent de hash van het request (zie requestHashVan).
+// De body blijft beschikbaar voor de binding. Zonder header geeft het een lege sleutel.
+func leesIdempotentie(c *gin.Context) (string, string, error) {
+	sleutel := c.GetHeader(IdempotencyKeyHeader)
+	if sleutel == "" {
+		return "", "", nil
+	}
+	if len(sleutel) > maxIdempotentieSleutelLen {
+		return "", "", nieuweValidatieFout(http.StatusBadRequest,
+			"%s is te lang (maximaal %d tekens)", IdempotencyKeyHeader, maxIdempotentieSleutelLen)
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", "", fmt.Errorf("HANDLER: kon request body niet lezen: %v", err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	return sleutel, requestHashVan(body, c.Query("dryrun"), c.Query("return"), c.GetHeader("If-Match")), nil
+}
+
+// requestHashVan geeft de SHA-256 van de body met de parameters die het antwoord bepalen.
+// Een nul byte scheidt de delen, zodat een parameter niet in de body kan overlopen.
+func requestHashVan(body []byte, dryrun string, antwoord string, ifMatch string) string {
+	hash := sha256.New()
+	hash.Write(body)
+	for _, deel := range []string{
+		"dryrun=" + strings.ToLower(dryrun),
+		"return=" + strings.ToLower(antwoord),
+		"If-Match=" + strings.TrimSpace(ifMatch),
+	} {
+		hash.Write([]byte{0})
+		hash.Write([]byte(deel))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// speelIdempotentAntwoordAf geeft het vastgelegde antwoord voor de sleutel terug, als die er is.
+// Hoort de sleutel bij een ander request (andere hash), dan volgt een 409.
+func speelIdempotentAntwoordAf(c *gin.Context, db bun.IDB, sleutel string, requestHash string) (bool, error) {
+	var vastgelegd model.Idempotentiesleutel
+	err := db.NewSelect().
+		Model(&vastgelegd).
+		Where("sleutel = ?", sleutel).
+		Scan(c.Request.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon %s niet opzoeken: %v", IdempotencyKeyHeader, err)
+	}
+
+	if vastgelegd.RequestHash != requestHash {
+		return false, nieuweValidatieFout(http.StatusConflict,
+			"%s %q is al gebruikt voor registratie %d met een ander request", IdempotencyKeyHeader, sleutel, vastgelegd.RegistratieID)
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(vastgelegd.Status, "application/json; charset=utf-8", vastgelegd.Antwoord)
+	return true, nil
+}
+
+// legIdempotentieVast legt de sleutel met het antwoord vast in de transactie van de registratie.
+// Geeft false als de sleutel al (door een gelijktijdig request) is vastgelegd.
+func legIdempotentieVast(c *gin.Context, tx bun.Tx, sleutel string, requestHash string,
+	registratieID int64, tijdstip time.Time, status int, antwoord any) (bool, error) {
+	antwoordJSON, err := json.Marshal(antwoord)
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon antwoord niet vastleggen bij %s: %v", IdempotencyKeyHeader, err)
+	}
+
+	vastgelegd := model.Idempotentiesleutel{
+		Sleutel:       sleutel,
+		RequestHash:   requestHash,
+		RegistratieID: registratieID,
+		Status:        status,
+		Antwoord:      antwoordJSON,
+		Tijdstip:      tijdstip,
+	}
+	result, err := tx.NewInsert().
+		Model(&vastgelegd).
+		On("CONFLICT (sleutel) DO NOTHING").
+		Exec(c.Request.Context())
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon %s niet vastleggen: %v", IdempotencyKeyHeader, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("HANDLER: kon %s niet vastleggen: %v", IdempotencyKeyHeader, err)
+	}
+	return rows == 1, nil
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== KARDINALITEIT ===========================
+
+Per rol van een entiteit (OnderliggendGegevenselement) kan een Minimum en Maximum aantal actieve records
+worden opgegeven, bijv. een A heeft altijd precies één U (1..1) en hoogstens 5 V's (0..5).
+De standaard MetaRegistry geeft geen minimum of maximum op; dan wordt er niets gecontroleerd.
+
+De controle gebeurt aan het eind van de registratie, vlak voor de commit, tegen de resulterende toestand.
+Afvoer van U5 en opvoer van U6 in één registratie mag dus. Een registratie die een entiteit in een ongeldige
+toestand achterlaat, wordt geweigerd met een 422 die alle geschonden regels noemt.
+
+Gecontroleerd worden alleen de actieve entiteiten die de registratie raakt: de entiteit zelf of een
+gegevenselement/relatie van de entiteit is opgevoerd of afgevoerd. Dat zijn de rijen met opvoer of afvoer op het
+registratietijdstip (dat is strikt oplopend, dus uniek). Daarnaast raakt een materiële wijziging (alleen in het
+logboek te zien) de entiteit zelf of, bij een relatie, de bovenliggende entiteit. Dezelfde geraakte entiteiten
+krijgen een nieuwe versie, zie registration_helpers_versie.go. Bij een ongedaanmaking is dat het tijdstip van de ongedaan
+gemaakte registratie; die rijen worden vóór en na het terugdraaien (of heraanbrengen) verzameld.
+*/
+
+// geraakteEntiteiten zijn per entiteittype de IDs van de entiteiten die een registratie raakt.
+type geraakteEntiteiten map[string]map[int]bool
+
+func (g geraakteEntiteiten) voegToe(typenaam string, ids []int) {
+	if len(ids) == 0 {
+		return
+	}
+	if g[typenaam] == nil {
+		g[typenaam] = map[int]bool{}
+	}
+	for _, id := range ids {
+		g[typenaam][id] = true
+	}
+}
+
+func (g geraakteEntiteiten) gesorteerd(typenaam string) []int {
+	ids := make([]int, 0, len(g[typenaam]))
+	for id := range g[typenaam] {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// entiteittypen geeft alle entiteittypen uit de MetaRegistry, op naam gesorteerd.
+func entiteittypen() []model.TypeMeta {
+	typen := make([]model.TypeMeta, 0)
+	for _, meta := range model.MetaRegistry {
+		if meta.Metatype == model.MetatypeEntiteit {
+			typen = append(typen, meta)
+		}
+	}
+	sort.Slice(typen, func(i, j int) bool { return typen[i].Typenaam < typen[j].Typenaam })
+	return typen
+}
+
+// entiteittypenMetKardinaliteit geeft de entiteittypen (op naam gesorteerd) met minstens één rol met een minimum of maximum.
+func entiteittypenMetKardinaliteit() []model.TypeMeta {
+	typen := make([]model.TypeMeta, 0)
+	for _, meta := range entiteittypen() {
+		for _, rol := range meta.OnderliggendeGegevenselementen {
+			if rol.HeeftKardinaliteit() {
+				typen = append(typen, meta)
+				break
+			}
+		}
+	}
+	return typen
+}
+
+// haalGeraakteEntiteitenUitDB voegt de entiteiten (van de gegeven typen) toe die zelf, of waarvan een gegevenselement/relatie,
+// op het tijdstip zijn opgevoerd of afgevoerd, of die op het tijdstip een materiële wijziging hebben gehad.
+func haalGeraakteEntiteitenUitDB(c *gin.Context, tx bun.Tx, tijdstip time.Time, typen []model.TypeMeta, geraakt geraakteEntiteiten) error {
+	for _, meta := range typen {
+		var ids []int
+		err := tx.NewSelect().
+			Table(meta.Tabelnaam).
+			Column(meta.IDKolom).
+			Where("opvoer = ? OR afvoer = ?", tijdstip, tijdstip).
+			Scan(c.Request.Context(), &ids)
+		if err != nil {
+			return fmt.Errorf("HANDLER: kon geraakte %s niet bepalen: %v", meta.Typenaam, err)
+		}
+		geraakt.voegToe(meta.Typenaam, ids)
+
+		for _, rol := range meta.OnderliggendeGegevenselementen {
+			doelMeta, err := metaVoorRolnaam(meta, rol.Rolnaam)
+			if err != nil {
+				return err
+			}
+
+			var entiteitIDs []int
+			err = tx.NewSelect().
+				Table(doelMeta.Tabelnaam).
+				Distinct().
+				Column(doelMeta.EntiteitIDKolom).
+				Where("opvoer = ? OR afvoer = ?", tijdstip, tijdstip).
+				Scan(c.Request.Context(), &entiteitIDs)
+			if err != nil {
+				return fmt.Errorf("HANDLER: kon geraakte %s niet bepalen: %v", doelMeta.Typenaam, err)
+			}
+			geraakt.voegToe(meta.Typenaam, entiteitIDs)
+		}
+	}
+
+	return haalMaterieelGeraakteEntiteitenUitDB(c, tx, tijdstip, typen, geraakt)
+}
+
+// haalMaterieelGeraakteEntiteitenUitDB voegt de entiteiten toe die op het tijdstip een materiële wijziging hebben gehad,
+// zelf of via een gegevenselement/relatie. Bij een PFK (bijv. B_X) is het rel_id alleen uniek binnen de bovenliggende
+// entiteit; die staat dan als EntiteitID in de wijziging. Anders is de RepresentatieID uniek en zoeken we de entiteit op.
+func haalMaterieelGeraakteEntiteitenUitDB(c *gin.Context, tx bun.Tx, tijdstip time.Time, typen []model.TypeMeta, geraakt geraakteEntiteiten) error {
+	var wijzigingen []model.Wijziging
+	err := tx.NewSelect().
+		Model(&wijzigingen).
+		Where("tijdstip = ?", tijdstip).
+		Where("wijzigingstype = ?", model.WijzigingstypeMaterieel).
+		Order("id").
+		Scan(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon materiële wijzigingen niet ophalen: %v", err)
+	}
+
+	gevraagd := map[string]bool{}
+	for _, meta := range typen {
+		gevraagd[meta.Typenaam] = true
+	}
+
+	for _, wijziging := range wijzigingen {
+		meta, ok := model.MetaRegistry.GetTypeMeta(wijziging.Representatienaam)
+		if !ok {
+			return fmt.Errorf("HANDLER: geen metadata voor %s", wijziging.Representatienaam)
+		}
+
+		if meta.Metatype == model.MetatypeEntiteit {
+			if !gevraagd[meta.Typenaam] {
+				continue
+			}
+			id, err := strconv.Atoi(wijziging.RepresentatieID)
+			if err != nil {
+				return fmt.Errorf("HANDLER: ongeldige id '%s' voor %s: %v", wijziging.RepresentatieID, meta.Typenaam, err)
+			}
+			geraakt.voegToe(meta.Typenaam, []int{id})
+			continue
+		}
+
+		bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+		if !ok || !gevraagd[bovenliggend.ParentType.Typenaam] {
+			continue
+		}
+		if wijziging.EntiteitID != "" {
+			id, err := strconv.Atoi(wijziging.EntiteitID)
+			if err != nil {
+				return fmt.Errorf("HANDLER: ongeldige entiteit_id '%s' voor %s: %v", wijziging.EntiteitID, meta.Typenaam, err)
+			}
+			geraakt.voegToe(bovenliggend.ParentType.Typenaam, []int{id})
+			continue
+		}
+		var entiteitIDs []int
+		err := tx.NewSelect().
+			Table(meta.Tabelnaam).
+			Column(meta.EntiteitIDKolom).
+			Where(fmt.Sprintf("%s = ?", meta.IDKolom), wijziging.RepresentatieID).
+			Scan(c.Request.Context(), &entiteitIDs)
+		if err != nil {
+			return fmt.Errorf("HANDLER: kon entiteit van %s %s niet bepalen: %v", meta.Typenaam, wijziging.RepresentatieID, err)
+		}
+		geraakt.voegToe(bovenliggend.ParentType.Typenaam, entiteitIDs)
+	}
+
+	return nil
+}
+
+// controleerKardinaliteit telt per geraakte, actieve entiteit de actieve records per rol
+// en weigert de registratie (422) als een minimum of maximum is geschonden.
+func controleerKardinaliteit(c *gin.Context, tx bun.Tx, geraakt geraakteEntiteiten) error {
+	var schendingen []string
+
+	for _, meta := range entiteittypenMetKardinaliteit() {
+		ids := geraakt.gesorteerd(meta.Typenaam)
+		if len(ids) == 0 {
+			continue
+		}
+
+		// afgevoerde of (door een ongedaanmaking) niet meer opgevoerde entiteiten doen niet mee
+		var actief []int
+		err := tx.NewSelect().
+			Table(meta.Tabelnaam).
+			Column(meta.IDKolom).
+			Where(fmt.Sprintf("%s IN (?)", meta.IDKolom), bun.In(ids)).
+			Where("opvoer IS NOT NULL").
+			Where("afvoer IS NULL").
+			Order(meta.IDKolom).
+			Scan(c.Request.Context(), &actief)
+		if err != nil {
+			return fmt.Errorf("HANDLER: kon actieve %s niet bepalen: %v", meta.Typenaam, err)
+		}
+		if len(actief) == 0 {
+			continue
+		}
+
+		for _, rol := range meta.OnderliggendeGegevenselementen {
+			if !rol.HeeftKardinaliteit() {
+				continue
+			}
+			doelMeta, err := metaVoorRolnaam(meta, rol.Rolnaam)
+			if err != nil {
+				return err
+			}
+
+			var aantallen []struct {
+				EntiteitID int `bun:"entiteit_id"`
+				Aantal     int `bun:"aantal"`
+			}
+			err = tx.NewSelect().
+				Table(doelMeta.Tabelnaam).
+				ColumnExpr("? AS entiteit_id", bun.Ident(doelMeta.EntiteitIDKolom)).
+				ColumnExpr("COUNT(*) AS aantal").
+				Where(fmt.Sprintf("%s IN (?)", doelMeta.EntiteitIDKolom), bun.In(actief)).
+				Where("opvoer IS NOT NULL").
+				Where("afvoer IS NULL").
+				Group(doelMeta.EntiteitIDKolom).
+				Scan(c.Request.Context(), &aantallen)
+			if err != nil {
+				return fmt.Errorf("HANDLER: kon actieve %s niet tellen: %v", doelMeta.Typenaam, err)
+			}
+
+			aantalPerEntiteit := map[int]int{}
+			for _, aantal := range aantallen {
+				aantalPerEntiteit[aantal.EntiteitID] = aantal.Aantal
+			}
+			for _, id := range actief {
+				if err := rol.ValideerAantal(aantalPerEntiteit[id]); err != nil {
+					schendingen = append(schendingen, fmt.Sprintf("%s %d: %v", meta.Typenaam, id, err))
+				}
+			}
+		}
+	}
+
+	if len(schendingen) > 0 {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"de registratie laat entiteiten in een ongeldige toestand achter: %s", strings.Join(schendingen, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+// metVoorbeeldKardinaliteit geeft A tijdens de test de kardinaliteit uit het voorbeeld: altijd precies één U (1..1)
+// en hoogstens 5 V's (0..5). De standaard MetaRegistry heeft geen minimum of maximum.
+func metVoorbeeldKardinaliteit(t *testing.T) {
+	t.Helper()
+	oud := model.MetaRegistry.MustTypeMeta("A")
+	nieuw := oud
+	nieuw.OnderliggendeGegevenselementen = append([]model.OnderliggendGegevenselement(nil), oud.OnderliggendeGegevenselementen...)
+	for i, rol := range nieuw.OnderliggendeGegevenselementen {
+		switch rol.Rolnaam {
+		case "Us":
+			nieuw.OnderliggendeGegevenselementen[i].Minimum, nieuw.OnderliggendeGegevenselementen[i].Maximum = 1, 1
+		case "Vs":
+			nieuw.OnderliggendeGegevenselementen[i].Maximum = 5
+		}
+	}
+	model.MetaRegistry["A"] = nieuw
+	t.Cleanup(func() { model.MetaRegistry["A"] = oud })
+}
+
+func TestControleerKardinaliteit(t *testing.T) {
+	t.Run("lists every violated constraint of the touched entities", func(t *testing.T) {
+		// Given: A heeft de voorbeeldkardinaliteit; de registratie raakt A2 en A3; A2 heeft één U en zes V's, A3 heeft geen U.
+		// When: de kardinaliteit wordt gecontroleerd.
+		// Then: een 422 die beide schendingen noemt.
+		ctx, tx, mock := nieuweMockTx(t)
+		metVoorbeeldKardinaliteit(t)
+
+		mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(id IN \(2, 3\)\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\) ORDER BY "id"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2).AddRow(3))
+		mock.ExpectQuery(`SELECT "a_id" AS entiteit_id, COUNT\(\*\) AS aantal FROM "a_u" WHERE \(a_id IN \(2, 3\)\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\) GROUP BY "a_id"`).
+			WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 1))
+		mock.ExpectQuery(`FROM "a_v" WHERE \(a_id IN \(2, 3\)\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 6).AddRow(3, 1))
+
+		err := controleerKardinaliteit(ctx, tx, geraakteEntiteiten{"A": {3: true, 2: true}})
+		if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+			t.Fatalf("expected 422 validation error, got %v", err)
+		}
+		for _, verwacht := range []string{"A 2: Vs heeft 6 actieve A_V (maximaal 5)", "A 3: Us heeft 0 actieve A_U (minimaal 1)"} {
+			if !strings.Contains(err.Error(), verwacht) {
+				t.Fatalf("expected %q in error, got: %v", verwacht, err)
+			}
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+
+	t.Run("skips entities that are no longer active", func(t *testing.T) {
+		// Given: de registratie raakt alleen A2, die in dezelfde registratie is afgevoerd.
+		// When: de kardinaliteit wordt gecontroleerd.
+		// Then: geen fout en geen telling van de rollen.
+		ctx, tx, mock := nieuweMockTx(t)
+		metVoorbeeldKardinaliteit(t)
+
+		mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(id IN \(2\)\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if err := controleerKardinaliteit(ctx, tx, geraakteEntiteiten{"A": {2: true}}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+}
+
+func TestHaalGeraakteEntiteitenUitDB(t *testing.T) {
+	// Given: op het registratietijdstip is A5 opgevoerd en is een V van A2 afgevoerd.
+	// When: de geraakte entiteiten worden bepaald.
+	// Then: A2 en A5 zijn geraakt.
+	ctx, tx, mock := nieuweMockTx(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(opvoer = '2026-02-25 10:00:00\+00:00' OR afvoer = '2026-02-25 10:00:00\+00:00'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_u" WHERE \(opvoer = '2026-02-25 10:00:00\+00:00' OR afvoer = '2026-02-25 10:00:00\+00:00'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}).AddRow(5))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_v"`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "rel_a_b"`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(tijdstip = '2026-02-25 10:00:00\+00:00'\) AND \(wijzigingstype = 'materieel'\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+
+	geraakt := geraakteEntiteiten{}
+	if err := haalGeraakteEntiteitenUitDB(ctx, tx, tijdstip, entiteittypenMetKardinaliteit(), geraakt); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ids := geraakt.gesorteerd("A"); len(ids) != 2 || ids[0] != 2 || ids[1] != 5 {
+		t.Fatalf("expected A 2 and 5 to be touched, got %v", ids)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHaalGeraakteEntiteitenUitDB_MaterieleWijzigingRaaktBovenliggendeEntiteit(t *testing.T) {
+	// Given: op het registratietijdstip is alleen de aanvang/einde van relatie 4 (van A3) gewijzigd.
+	// When: de geraakte entiteiten worden bepaald.
+	// Then: A3 is geraakt, via de relatie.
+	ctx, tx, mock := nieuweMockTx(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "id" FROM "a"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_u"`).WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "a_v"`).WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT DISTINCT "a_id" FROM "rel_a_b"`).WillReturnRows(sqlmock.NewRows([]string{"a_id"}))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(tijdstip = .*\) AND \(wijzigingstype = 'materieel'\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).AddRow(30, "materieel", 9, "Rel_A_B", nil, "4", tijdstip))
+	mock.ExpectQuery(`SELECT "a_id" FROM "rel_a_b" WHERE \(id = '4'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id"}).AddRow(3))
+
+	geraakt := geraakteEntiteiten{}
+	if err := haalGeraakteEntiteitenUitDB(ctx, tx, tijdstip, entiteittypenMetKardinaliteit(), geraakt); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ids := geraakt.gesorteerd("A"); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("expected A 3 to be touched, got %v", ids)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHaalMaterieelGeraakteEntiteitenUitDB_PFKRaaktAlleenEigenEntiteit(t *testing.T) {
+	// Given: B1 en B2 hebben allebei een X met rel_id 1; op het registratietijdstip is alleen X1 van B2 materieel gewijzigd.
+	// When: de geraakte entiteiten worden bepaald.
+	// Then: alleen B2 is geraakt (via de entiteit_id van de wijziging), niet elke B met een X1.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(tijdstip = .*\) AND \(wijzigingstype = 'materieel'\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).AddRow(30, "materieel", 9, "B_X", "2", "1", tijdstip))
+
+	geraakt := geraakteEntiteiten{}
+	if err := haalMaterieelGeraakteEntiteitenUitDB(ctx, tx, tijdstip, []model.TypeMeta{model.MetaRegistry.MustTypeMeta("B")}, geraakt); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if ids := geraakt.gesorteerd("B"); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("expected only B 2 to be touched, got %v", ids)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== MATERIËLE TIJD ===========================
+
+Materiële representaties (MetaRegistry: IsMaterieel, bijv. A, B en Rel_A_B; een gegevenselement als het zo is ingesteld)
+hebben naast opvoer/afvoer ook aanvang/einde. Die worden bij een opvoer gewoon meegegeven en mee opgeslagen.
+Gegevenselementen hebben de velden altijd, maar alleen als ze materieel zijn mogen ze gevuld worden.
+
+Aanvang/einde van een bestaande, actieve representatie wijzigen gaat met een "materieel" wijziging:
+
+	{
+		"registratie": {"registratietype": "registratie", "opmerking": "A2 is per 1 maart beëindigd"},
+		"wijzigingen": [
+			{"materieel": {"a": {"id": 2, "aanvang": "2026-01-01T00:00:00Z", "einde": "2026-03-01T00:00:00Z"}}}
+		]
+	}
+
+Alleen id (en bij een PFK de FK naar de entiteit), aanvang en einde worden gebruikt; onderliggende
+gegevenselementen en andere velden worden genegeerd. De velden aanvang/einde worden in place
+bijgewerkt (het zijn afgeleide velden, net als opvoer/afvoer). De bron van waarheid is het wijziging record
+van type materieel, met de nieuwe én de vorige aanvang/einde, zodat een ongedaanmaking ze kan herstellen.
+In een correctie kan een entiteit zo ook haar materiële tijd wijzigen.
+
+VALIDATIE: einde moet later zijn dan aanvang (als beide gevuld zijn).
+*/
+
+// valideerMaterieleTijd controleert aanvang/einde van een representatie.
+// Een niet-materieel type (MetaRegistry) mag geen aanvang/einde hebben.
+func valideerMaterieleTijd(meta model.TypeMeta, representatie model.Representatie) error {
+	materieel, ok := representatie.(model.HeeftAanvangEinde)
+	if !ok {
+		return nil
+	}
+	if !meta.IsMaterieel {
+		if materieel.GetAanvang() != nil || materieel.GetEinde() != nil {
+			return nieuweValidatieFout(http.StatusUnprocessableEntity,
+				"%s is niet materieel en kan geen aanvang/einde hebben", meta.Typenaam)
+		}
+		return nil
+	}
+	if err := model.ValideerAanvangEinde(materieel.GetAanvang(), materieel.GetEinde()); err != nil {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity, "%s %v: %v", meta.Typenaam, representatie.GetID(), err)
+	}
+	return nil
+}
+
+// handleRepresentatieMaterieel wijzigt alleen de aanvang/einde van een bestaande, actieve representatie.
+func handleRepresentatieMaterieel(c *gin.Context, tx bun.Tx, registratieID int64, tijdstip time.Time,
+	representatienaam string, representatie model.Representatie) error {
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatienaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: onbekend type voor materiële wijziging: %s", representatienaam)
+	}
+
+	materieel, ok := representatie.(model.HeeftAanvangEinde)
+	if !meta.IsMaterieel || !ok {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity, "%s heeft geen materiële tijd (aanvang/einde)", representatienaam)
+	}
+	if err := valideerMaterieleTijd(meta, representatie); err != nil {
+		return err
+	}
+
+	return wijzigMaterieleTijd(c, tx, registratieID, tijdstip, meta, representatie, materieel.GetAanvang(), materieel.GetEinde())
+}
+
+// wijzigMaterieleTijd zet aanvang/einde van een actieve representatie en legt de wijziging (met de vorige waarden) vast.
+// Als er niets verandert, gebeurt er niets.
+func wijzigMaterieleTijd(c *gin.Context, tx bun.Tx, registratieID int64, tijdstip time.Time,
+	meta model.TypeMeta, representatie model.Representatie, aanvang *time.Time, einde *time.Time) error {
+	waar, err := waarRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+
+	var vorigeAanvang, vorigeEinde *time.Time
+	err = tx.NewSelect().
+		Table(meta.Tabelnaam).
+		Column("aanvang", "einde").
+		ApplyQueryBuilder(waar).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Limit(1).
+		Scan(c.Request.Context(), &vorigeAanvang, &vorigeEinde)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"%s %v bestaat niet of is afgevoerd; de materiële tijd kan niet gewijzigd worden", meta.Typenaam, representatie.GetID())
+	}
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon aanvang/einde van %s %v niet ophalen: %v", meta.Typenaam, representatie.GetID(), err)
+	}
+
+	if model.ZelfdeTijdstip(aanvang, vorigeAanvang) && model.ZelfdeTijdstip(einde, vorigeEinde) {
+		return nil
+	}
+	if err := controleerGeenOverlapNaWijziging(c, tx, meta, representatie, aanvang, einde); err != nil {
+		return err
+	}
+
+	_, err = tx.NewUpdate().
+		Table(meta.Tabelnaam).
+		Set("aanvang = ?", aanvang).
+		Set("einde = ?", einde).
+		ApplyQueryBuilder(waar).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Exec(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: failed to update %s aanvang/einde: %v", meta.Typenaam, err)
+	}
+
+	sleutel, err := sleutelVoorRepresentatie(meta, representatie)
+	if err != nil {
+		return err
+	}
+	wijziging := model.Wijziging{
+		Wijzigingstype:    model.WijzigingstypeMaterieel,
+		RegistratieID:     registratieID,
+		Representatienaam: sleutel.Representatienaam,
+		EntiteitID:        sleutel.EntiteitID,
+		RepresentatieID:   sleutel.RepresentatieID,
+		Tijdstip:          tijdstip,
+		Aanvang:           aanvang,
+		Einde:             einde,
+		VorigeAanvang:     vorigeAanvang,
+		VorigeEinde:       vorigeEinde,
+	}
+	if _, err := tx.NewInsert().Model(&wijziging).Exec(c.Request.Context()); err != nil {
+		return fmt.Errorf("failed to insert wijziging: %v", err)
+	}
+
+	return nil
+}
+
+// controleerGeenOverlapNaWijziging weigert (409) een nieuwe periode van een enkelvoudig gegevenselement die overlapt
+// met die van een ander actief record bij dezelfde entiteit (zoals bij de opvoer, zie sluitActieveEnkelvoudigeVoorgangersAf).
+// Anders zou pas de exclusion constraint (zie dbsetup/enkelvoudig_constraints.go) de wijziging tegenhouden, met een 500.
+func controleerGeenOverlapNaWijziging(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.Representatie,
+	aanvang *time.Time, einde *time.Time) error {
+	if meta.Metatype == model.MetatypeEntiteit || meta.Momentvoorkomen != model.Enkelvoudig {
+		return nil
+	}
+
+	entiteitID, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.EntiteitIDKolom)
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon entiteit id niet bepalen voor %s: %v", meta.Typenaam, err)
+	}
+	eigenID, _ := anyNaarInt(representatie.GetID())
+
+	overlappend, err := haalActieveIDsMetOverlapUitDB(c, tx, meta, meta.EntiteitIDKolom, entiteitID, aanvang, einde)
+	if err != nil {
+		return err
+	}
+	for _, id := range overlappend {
+		if id != eigenID {
+			return nieuweValidatieFout(http.StatusConflict,
+				"de nieuwe periode van %s %d overlapt met die van actief %s %d bij %s=%d (enkelvoudig)",
+				meta.Typenaam, eigenID, meta.Typenaam, id, meta.EntiteitIDKolom, entiteitID)
+		}
+	}
+	return nil
+}
+
+// zetMaterieleTijd zet bij een ongedaanmaking aanvang/einde uit een materieel wijziging record
+// van (vanAanvang, vanEinde) naar (naarAanvang, naarEinde).
+func zetMaterieleTijd(c *gin.Context, tx bun.Tx, wijziging model.Wijziging,
+	vanAanvang *time.Time, vanEinde *time.Time, naarAanvang *time.Time, naarEinde *time.Time) error {
+	meta, ok := model.MetaRegistry.GetTypeMeta(wijziging.Representatienaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: onbekend type %s in wijziging %d", wijziging.Representatienaam, wijziging.ID)
+	}
+
+	result, err := tx.NewUpdate().
+		Table(meta.Tabelnaam).
+		Set("aanvang = ?", naarAanvang).
+		Set("einde = ?", naarEinde).
+		ApplyQueryBuilder(waarSleutel(meta, wijziging.Sleutel())).
+		Where("aanvang IS NOT DISTINCT FROM ?", vanAanvang).
+		Where("einde IS NOT DISTINCT FROM ?", vanEinde).
+		Exec(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon aanvang/einde van %s %s niet herstellen: %v", meta.Typenaam, wijziging.RepresentatieID, err)
+	}
+	aantal, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon aantal herstelde %s records niet bepalen: %v", meta.Typenaam, err)
+	}
+	if aantal == 0 {
+		return fmt.Errorf("HANDLER: aanvang/einde van %s %s wijkt af van wijziging %d; de afgeleide velden zijn niet meer consistent",
+			meta.Typenaam, wijziging.RepresentatieID, wijziging.ID)
+	}
+
+	return nil
+}
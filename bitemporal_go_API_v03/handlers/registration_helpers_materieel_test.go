@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+func TestHandleRepresentatieMaterieel_WijzigtAanvangEindeEnBewaartVorigeWaarden(t *testing.T) {
+	// Given: A2 is actief met aanvang 1 januari en zonder einde.
+	// When: in registratie 7 het einde op 1 maart wordt gezet.
+	// Then: aanvang/einde worden in place bijgewerkt en er komt een materieel wijziging record met de vorige waarden.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	aanvang := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	einde := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "aanvang", "einde" FROM "a" WHERE \(id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\) LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"aanvang", "einde"}).AddRow(aanvang, nil))
+	mock.ExpectExec(`UPDATE "a" SET aanvang = '2026-01-01 00:00:00\+00:00', einde = '2026-03-01 00:00:00\+00:00' WHERE \(id = 2\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'materieel'.*'A', DEFAULT, '2'.*'2026-03-01 00:00:00\+00:00'.*'2026-01-01 00:00:00\+00:00', DEFAULT\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(50))
+
+	representatie := &model.Full_A{ID: 2, Aanvang: &aanvang, Einde: &einde}
+	if err := handleRepresentatieMaterieel(ctx, tx, 7, tijdstip, "A", representatie); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieMaterieel_RejectsEindeVoorAanvang(t *testing.T) {
+	// Given: een einde dat voor de aanvang ligt.
+	// When: de materiële wijziging wordt verwerkt.
+	// Then: er volgt een 422 en de database wordt niet geraakt.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	aanvang := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	einde := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	err := handleRepresentatieMaterieel(ctx, tx, 7, time.Now(), "Rel_A_B", &model.Rel_A_B{ID: 4, Aanvang: &aanvang, Einde: &einde})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleOngedaanmaking_HersteltVorigeAanvangEinde(t *testing.T) {
+	// Given: registratie 7 zette het einde van A2 op 1 maart (daarvoor leeg).
+	// When: registratie 7 ongedaan wordt gemaakt.
+	// Then: het einde wordt weer leeggemaakt, mits de huidige waarden nog die van registratie 7 zijn.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)
+	aanvang := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	einde := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7`).
+		WillReturnRows(sqlmock.NewRows(append(wijzigingKolommen, "aanvang", "einde", "vorige_aanvang", "vorige_einde")).
+			AddRow(50, "materieel", 7, "A", nil, "2", tijdstip, aanvang, einde, aanvang, nil))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+	mock.ExpectExec(`UPDATE "a" SET aanvang = '2026-01-01 00:00:00\+00:00', einde = NULL WHERE \(id = '2'\) AND \(aanvang IS NOT DISTINCT FROM '2026-01-01 00:00:00\+00:00'\) AND \(einde IS NOT DISTINCT FROM '2026-03-01 00:00:00\+00:00'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+// metMaterieel maakt een type tijdens de test materieel (in de standaard MetaRegistry is alleen A, B en Rel_A_B dat).
+func metMaterieel(t *testing.T, typenaam string) {
+	t.Helper()
+	oud := model.MetaRegistry.MustTypeMeta(typenaam)
+	nieuw := oud
+	nieuw.IsMaterieel = true
+	model.MetaRegistry[typenaam] = nieuw
+	t.Cleanup(func() { model.MetaRegistry[typenaam] = oud })
+}
+
+func TestSluitActieveEnkelvoudigeVoorgangersAf_MaterieelSluitAlleenOverlappendePeriodes(t *testing.T) {
+	// Given: B_X is (in deze test) materieel; bij B1 zijn X5 (jan-mrt) en X6 (vanaf mrt, open einde) actief.
+	// When: X met aanvang juni wordt opgevoerd.
+	// Then: alleen X6 (overlapt) wordt afgevoerd; X5 blijft actief.
+	ctx, tx, mock := nieuweMockTx(t)
+	metMaterieel(t, "B_X")
+
+	meta := model.MetaRegistry.MustTypeMeta("B_X")
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mrt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "rel_id" AS id, "aanvang", "einde" FROM "b_x" WHERE \(b_id = 1\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "aanvang", "einde"}).
+			AddRow(5, jan, mrt).
+			AddRow(6, mrt, nil))
+	mock.ExpectExec(`UPDATE "b_x" SET afvoer = .*WHERE \(rel_id = 6\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'afvoer'.*'B_X', '1', '6'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+
+	representatie := &model.B_X{B_ID: 1, Fff: "nieuw", Aanvang: &jun}
+	if err := sluitActieveEnkelvoudigeVoorgangersAf(ctx, tx, 42, tijdstip, "B_X", representatie, meta); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestValideerMaterieleTijd_RejectsAanvangOpNietMaterieelGegevenselement(t *testing.T) {
+	// Given: A_U is niet materieel.
+	// When: een A_U met aanvang wordt gevalideerd.
+	// Then: er volgt een 422.
+	aanvang := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := valideerMaterieleTijd(model.MetaRegistry.MustTypeMeta("A_U"), &model.A_U{A_ID: 1, Aanvang: &aanvang})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+}
+
+func TestHandleRepresentatieMaterieel_RejectsOverlapMetActieveEnkelvoudige(t *testing.T) {
+	// Given: B_X is (in deze test) materieel; bij B1 zijn X5 (jan-mrt) en X6 (vanaf mrt, open einde) actief.
+	// When: het einde van X5 naar juni wordt verschoven.
+	// Then: er volgt een 409 die X6 noemt, vóór de update (niet pas de exclusion constraint met een 500).
+	ctx, tx, mock := nieuweMockTx(t)
+	metMaterieel(t, "B_X")
+
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mrt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "aanvang", "einde" FROM "b_x" WHERE \(b_id = 1\) AND \(rel_id = 5\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\) LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"aanvang", "einde"}).AddRow(jan, mrt))
+	mock.ExpectQuery(`SELECT "rel_id" AS id, "aanvang", "einde" FROM "b_x" WHERE \(b_id = 1\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "aanvang", "einde"}).
+			AddRow(5, jan, mrt).
+			AddRow(6, mrt, nil))
+
+	err := handleRepresentatieMaterieel(ctx, tx, 7, time.Now(), "B_X", &model.B_X{B_ID: 1, Rel_ID: 5, Aanvang: &jan, Einde: &jun})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict ||
+		!strings.Contains(err.Error(), "B_X 6") {
+		t.Fatalf("expected 409 naming B_X 6, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
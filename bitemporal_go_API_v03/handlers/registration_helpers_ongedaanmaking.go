@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== ONGEDAANMAKING ===========================
+
+Een ongedaanmaking is een registratie met Registratietype = ongedaanmaking en een verwijzing
+naar de registratie die ongedaan wordt gemaakt (MaaktOngedaanRegistratieID), zonder wijzigingen:
+
+	{
+		"registratie": {"registratietype": "ongedaanmaking", "maakt_ongedaan_registratie_id": 7, "opmerking": "Registratie 7 was een vergissing"},
+		"wijzigingen": []
+	}
+
+VOORWAARDEN:
+- de ongedaan te maken registratie bestaat en is eerder dan de ongedaanmaking
+- zij is nog niet eerder ongedaan gemaakt (wie dat wil herroepen, maakt die eerdere ongedaanmaking ongedaan)
+- de keten van ongedaanmakingen via maakt_ongedaan_registratie_id bevat geen cyclus
+- de representaties die geraakt worden zijn daarna niet meer gewijzigd door een (netto niet ongedaan gemaakte) registratie;
+  anders moet eerst die latere registratie ongedaan gemaakt worden
+- wat weer actief wordt (heropend, opnieuw opgevoerd of met een andere periode), past nog in het register:
+  een enkelvoudig gegevenselement/relatie krijgt geen tweede actieve waarde (materieel: geen overlappende periode),
+  anders 409; en de entiteiten waar het bij hoort (bij een relatie: beide kanten) zijn nog actief, anders 422.
+  Bijv. de afvoer van Y1 ongedaan maken nadat Y2 is opgevoerd, kan niet; voer dan eerst Y2 af.
+
+ACTIES:
+De registratie met de verwijzing is de bron van waarheid. Er komen dus geen wijziging records bij.
+Wel passen we de afgeleide velden opvoer/afvoer aan van alle representaties die de basis van de keten raakte
+(zie model/ongedaanmaking.go voor de semantiek van ketens):
+- terugdraaien (ongedaanmaking van een registratie of correctie): de wijzigingen van de basis in omgekeerde volgorde
+  - opvoer: de representatie bestond daarvoor niet, dus de opvoer wordt leeggemaakt
+  - afvoer: de representatie was daarvoor actief, dus de afvoer wordt leeggemaakt (heropend)
+  - materieel: aanvang/einde krijgen weer de vorige waarden uit het wijziging record
+  Bij een correctie komen zo de waarden van vóór de correctie terug.
+- heraanbrengen (ongedaanmaking van een ongedaanmaking): de wijzigingen van de basis in oorspronkelijke volgorde
+  - opvoer en afvoer krijgen weer het tijdstip van de basis
+  - materieel: aanvang/einde krijgen weer de nieuwe waarden uit het wijziging record
+Dit gaat via de metaregistry, dus werkt voor alle entiteiten, relaties en gegevenselementen.
+*/
+
+// valideerOngedaanmaking controleert de voorwaarden voor een ongedaanmaking en geeft de opgeloste keten terug.
+func valideerOngedaanmaking(c *gin.Context, tx bun.Tx, ongedaanmaking model.Registratie) (model.Ongedaanmakingsketen, error) {
+	if ongedaanmaking.MaaktOngedaanRegistratieID == nil {
+		return model.Ongedaanmakingsketen{}, nieuweValidatieFout(http.StatusBadRequest, "ongedaanmaking zonder maakt_ongedaan_registratie_id")
+	}
+
+	ongedaanTeMaken, gevonden, err := haalRegistratieUitDB(c, tx, *ongedaanmaking.MaaktOngedaanRegistratieID)
+	if err != nil {
+		return model.Ongedaanmakingsketen{}, err
+	}
+	if !gevonden {
+		return model.Ongedaanmakingsketen{}, nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"ongedaan te maken registratie %d bestaat niet", *ongedaanmaking.MaaktOngedaanRegistratieID)
+	}
+	if !ongedaanTeMaken.Tijdstip.Before(ongedaanmaking.Tijdstip) {
+		return model.Ongedaanmakingsketen{}, nieuweValidatieFout(http.StatusUnprocessableEntity,
+			"ongedaanmaking (%s) moet later zijn dan de ongedaan te maken registratie %d (%s)",
+			ongedaanmaking.Tijdstip.Format(time.RFC3339Nano), ongedaanTeMaken.ID, ongedaanTeMaken.Tijdstip.Format(time.RFC3339Nano))
+	}
+
+	eerdere, err := haalOngedaanmakingenVanRegistratieUitDB(c, tx, ongedaanTeMaken.ID, ongedaanmaking.ID)
+	if err != nil {
+		return model.Ongedaanmakingsketen{}, err
+	}
+	if len(eerdere) > 0 {
+		return model.Ongedaanmakingsketen{}, nieuweValidatieFout(http.StatusConflict,
+			"registratie %d is al ongedaan gemaakt door registratie %d; maak die ongedaanmaking ongedaan om haar te herroepen",
+			ongedaanTeMaken.ID, eerdere[0].ID)
+	}
+
+	keten, err := model.LosOngedaanmakingsketenOp(ongedaanTeMaken, func(id int64) (model.Registratie, bool, error) {
+		return haalRegistratieUitDB(c, tx, id)
+	})
+	if errors.Is(err, model.ErrOngedaanmakingscyclus) {
+		return model.Ongedaanmakingsketen{}, nieuweValidatieFout(http.StatusUnprocessableEntity, "ongeldige ongedaanmaking: %v", err)
+	}
+	if err != nil {
+		return model.Ongedaanmakingsketen{}, fmt.Errorf("HANDLER: kon keten van ongedaanmakingen niet oplossen: %v", err)
+	}
+
+	return keten, nil
+}
+
+// handleOngedaanmaking draait de wijzigingen van de basis van de keten terug, of brengt ze opnieuw aan.
+func handleOngedaanmaking(c *gin.Context, tx bun.Tx, ongedaanmaking model.Registratie, keten model.Ongedaanmakingsketen) error {
+	wijzigingen, err := haalWijzigingenVanRegistratieUitDB(c, tx, keten.Basis.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := controleerGeenLatereWijzigingen(c, tx, ongedaanmaking, keten.Basis, wijzigingen); err != nil {
+		return err
+	}
+
+	if keten.Heraanbrengen {
+		for _, wijziging := range wijzigingen {
+			if err := brengWijzigingOpnieuwAan(c, tx, wijziging, keten.Basis.Tijdstip); err != nil {
+				return err
+			}
+		}
+		return controleerHeractiveerdeRepresentaties(c, tx, ongedaanmaking, wijzigingen, model.WijzigingstypeOpvoer)
+	}
+
+	// in omgekeerde volgorde, zodat bijv. een automatisch afgevoerde enkelvoudige voorganger
+	// pas heropend wordt nadat zijn opvolger is leeggemaakt
+	for i := len(wijzigingen) - 1; i >= 0; i-- {
+		if err := draaiWijzigingTerug(c, tx, wijzigingen[i], keten.Basis.Tijdstip); err != nil {
+			return err
+		}
+	}
+
+	return controleerHeractiveerdeRepresentaties(c, tx, ongedaanmaking, wijzigingen, model.WijzigingstypeAfvoer)
+}
+
+// controleerHeractiveerdeRepresentaties controleert, nadat de wijzigingen zijn teruggedraaid of opnieuw aangebracht,
+// de representaties die daardoor weer actief zijn: die van de wijzigingen van het type heractiverend
+// (afvoer bij terugdraaien, opvoer bij heraanbrengen) en die met een gewijzigde materiële periode.
+// Een enkelvoudig gegevenselement/relatie mag dan geen tweede actieve (materieel: overlappende) waarde hebben (409),
+// en de entiteiten waar het bij hoort moeten nog actief zijn (422).
+func controleerHeractiveerdeRepresentaties(c *gin.Context, tx bun.Tx, ongedaanmaking model.Registratie,
+	wijzigingen []model.Wijziging, heractiverend model.WijzigingstypeEnum) error {
+	gecontroleerd := map[model.RepresentatieSleutel]bool{}
+	for _, wijziging := range wijzigingen {
+		if wijziging.Wijzigingstype != heractiverend && wijziging.Wijzigingstype != model.WijzigingstypeMaterieel {
+			continue
+		}
+		sleutel := wijziging.Sleutel()
+		if gecontroleerd[sleutel] {
+			continue
+		}
+		gecontroleerd[sleutel] = true
+
+		meta, ok := model.MetaRegistry.GetTypeMeta(wijziging.Representatienaam)
+		if !ok {
+			return fmt.Errorf("HANDLER: onbekend type %s in wijziging %d", wijziging.Representatienaam, wijziging.ID)
+		}
+		if meta.Metatype == model.MetatypeEntiteit {
+			continue
+		}
+
+		representatie, actief, err := haalActieveRepresentatieUitDB(c, tx, meta, sleutel)
+		if err != nil {
+			return err
+		}
+		if !actief {
+			// bijv. een materiële wijziging op een representatie die (nog steeds) is afgevoerd
+			continue
+		}
+
+		if err := controleerEnkelvoudigNaHeractivering(c, tx, meta, representatie); err != nil {
+			return err
+		}
+
+		if meta.Metatype == model.MetatypeRelatie {
+			err = valideerRelatieEindpunten(c, tx, meta, representatie, ongedaanmaking.Tijdstip)
+		} else {
+			err = valideerBovenliggendeEntiteit(c, tx, meta, representatie, ongedaanmaking.Tijdstip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// haalActieveRepresentatieUitDB haalt de representatie met de sleutel uit een wijziging op, als die actief is.
+func haalActieveRepresentatieUitDB(c *gin.Context, tx bun.Tx, meta model.TypeMeta, sleutel model.RepresentatieSleutel) (model.FormeleRepresentatie, bool, error) {
+	representatie, ok := meta.Factory().(model.FormeleRepresentatie)
+	if !ok {
+		return nil, false, fmt.Errorf("HANDLER: %s is geen formele representatie", meta.Typenaam)
+	}
+
+	err := tx.NewSelect().
+		Model(representatie).
+		ApplyQueryBuilder(waarSleutel(meta, sleutel)).
+		Where("opvoer IS NOT NULL").
+		Where("afvoer IS NULL").
+		Scan(c.Request.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("HANDLER: kon %s %s niet ophalen: %v", meta.Typenaam, sleutel.RepresentatieID, err)
+	}
+
+	return representatie, true, nil
+}
+
+// controleerEnkelvoudigNaHeractivering geeft een 409 als een weer actief enkelvoudig gegevenselement/relatie
+// niet de enige actieve (materieel: niet overlappende) waarde bij zijn entiteit is.
+func controleerEnkelvoudigNaHeractivering(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.FormeleRepresentatie) error {
+	if meta.Momentvoorkomen != model.Enkelvoudig || meta.EntiteitIDKolom == "" {
+		return nil
+	}
+
+	entiteitID, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.EntiteitIDKolom)
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", meta.EntiteitIDKolom, meta.Typenaam, err)
+	}
+
+	var actieveIDs []int
+	if meta.IsMaterieel {
+		actieveIDs, err = haalOverlappendeActieveIDsGegevenselementUitDB(c, tx, meta, meta.EntiteitIDKolom, entiteitID, representatie)
+	} else {
+		actieveIDs, err = haalActieveIDsGegevenselementUitDB(c, tx, meta, meta.EntiteitIDKolom, entiteitID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// de representatie zelf telt mee
+	if len(actieveIDs) > 1 {
+		return nieuweValidatieFout(http.StatusConflict,
+			"ongedaanmaking maakt %s %v weer actief, maar %s=%d heeft al een andere actieve %s (enkelvoudig); voer die eerst af",
+			meta.Typenaam, representatie.GetID(), meta.EntiteitIDKolom, entiteitID, meta.Typenaam)
+	}
+
+	return nil
+}
+
+// draaiWijzigingTerug maakt het afgeleide veld leeg dat door de wijziging werd gezet (of herstelt aanvang/einde).
+func draaiWijzigingTerug(c *gin.Context, tx bun.Tx, wijziging model.Wijziging, tijdstip time.Time) error {
+	if wijziging.Wijzigingstype == model.WijzigingstypeMaterieel {
+		return zetMaterieleTijd(c, tx, wijziging, wijziging.Aanvang, wijziging.Einde, wijziging.VorigeAanvang, wijziging.VorigeEinde)
+	}
+
+	kolom, err := afgeleideKolomVoorWijziging(wijziging)
+	if err != nil {
+		return err
+	}
+
+	return zetAfgeleidTijdstip(c, tx, wijziging, kolom, &tijdstip, nil)
+}
+
+// brengWijzigingOpnieuwAan zet het afgeleide veld weer op het tijdstip van de oorspronkelijke registratie (of zet aanvang/einde opnieuw).
+func brengWijzigingOpnieuwAan(c *gin.Context, tx bun.Tx, wijziging model.Wijziging, tijdstip time.Time) error {
+	if wijziging.Wijzigingstype == model.WijzigingstypeMaterieel {
+		return zetMaterieleTijd(c, tx, wijziging, wijziging.VorigeAanvang, wijziging.VorigeEinde, wijziging.Aanvang, wijziging.Einde)
+	}
+
+	kolom, err := afgeleideKolomVoorWijziging(wijziging)
+	if err != nil {
+		return err
+	}
+
+	return zetAfgeleidTijdstip(c, tx, wijziging, kolom, nil, &tijdstip)
+}
+
+// afgeleideKolomVoorWijziging geeft de kolom (opvoer of afvoer) die een wijziging afleidt.
+func afgeleideKolomVoorWijziging(wijziging model.Wijziging) (string, error) {
+	switch wijziging.Wijzigingstype {
+	case model.WijzigingstypeOpvoer:
+		return "opvoer", nil
+	case model.WijzigingstypeAfvoer:
+		return "afvoer", nil
+	default:
+		return "", fmt.Errorf("HANDLER: onbekend wijzigingstype %s in wijziging %d", wijziging.Wijzigingstype, wijziging.ID)
+	}
+}
+
+// zetAfgeleidTijdstip zet het afgeleide veld (opvoer of afvoer) van de representatie uit een wijziging
+// van 'van' naar 'naar'. Door op de huidige waarde te filteren raken we alleen het record dat bij de wijziging hoort.
+func zetAfgeleidTijdstip(c *gin.Context, tx bun.Tx, wijziging model.Wijziging, kolom string, van *time.Time, naar *time.Time) error {
+	meta, ok := model.MetaRegistry.GetTypeMeta(wijziging.Representatienaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: onbekend type %s in wijziging %d", wijziging.Representatienaam, wijziging.ID)
+	}
+
+	query := tx.NewUpdate().
+		Table(meta.Tabelnaam).
+		Set(fmt.Sprintf("%s = ?", kolom), naar).
+		ApplyQueryBuilder(waarSleutel(meta, wijziging.Sleutel()))
+	if van == nil {
+		query = query.Where(fmt.Sprintf("%s IS NULL", kolom))
+	} else {
+		query = query.Where(fmt.Sprintf("%s = ?", kolom), *van)
+	}
+
+	result, err := query.Exec(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon %s van %s %s niet herstellen: %v", kolom, meta.Typenaam, wijziging.RepresentatieID, err)
+	}
+	aantal, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon aantal herstelde %s records niet bepalen: %v", meta.Typenaam, err)
+	}
+	if aantal == 0 {
+		return fmt.Errorf("HANDLER: %s van %s %s wijkt af van wijziging %d; de afgeleide velden zijn niet meer consistent",
+			kolom, meta.Typenaam, wijziging.RepresentatieID, wijziging.ID)
+	}
+
+	return nil
+}
+
+// controleerGeenLatereWijzigingen weigert de ongedaanmaking als een geraakte representatie na de basis
+// nog door een andere, netto niet ongedaan gemaakte, registratie is gewijzigd.
+func controleerGeenLatereWijzigingen(c *gin.Context, tx bun.Tx, ongedaanmaking model.Registratie, basis model.Registratie, wijzigingen []model.Wijziging) error {
+	if len(wijzigingen) == 0 {
+		return nil
+	}
+
+	ongedaanGemaakt, err := haalOngedaanGemaakteRegistratieIDs(c, tx, ongedaanmaking.ID)
+	if err != nil {
+		return err
+	}
+
+	representaties := make([][]string, 0, len(wijzigingen))
+	for _, wijziging := range wijzigingen {
+		representaties = append(representaties, []string{wijziging.Representatienaam, wijziging.EntiteitID, wijziging.RepresentatieID})
+	}
+
+	var latere []model.Wijziging
+	query := tx.NewSelect().
+		Model(&latere).
+		Join("JOIN registratie AS r ON r.id = wijziging.registratie_id").
+		Where("r.tijdstip > ?", basis.Tijdstip).
+		Where("(wijziging.representatienaam, COALESCE(wijziging.entiteit_id, ''), wijziging.representatie_id) IN (?)", bun.In(representaties))
+	if len(ongedaanGemaakt) > 0 {
+		query = query.Where("wijziging.registratie_id NOT IN (?)", bun.In(ongedaanGemaakt))
+	}
+	err = query.Order("wijziging.id").Scan(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon latere wijzigingen niet raadplegen: %v", err)
+	}
+	if len(latere) == 0 {
+		return nil
+	}
+
+	beschrijvingen := make([]string, 0, len(latere))
+	for _, wijziging := range latere {
+		beschrijvingen = append(beschrijvingen, fmt.Sprintf("%s %s (registratie %d)",
+			wijziging.Representatienaam, wijziging.RepresentatieID, wijziging.RegistratieID))
+	}
+
+	return nieuweValidatieFout(http.StatusConflict,
+		"wijzigingen van registratie %d kunnen niet teruggedraaid of heraangebracht worden, want later gewijzigd: %s; maak eerst die registratie(s) ongedaan",
+		basis.ID, strings.Join(beschrijvingen, ", "))
+}
+
+// haalOngedaanGemaakteRegistratieIDs bepaalt welke registraties netto ongedaan gemaakt zijn,
+// zonder de ongedaanmaking die nu verwerkt wordt (met ID behalveID) mee te tellen.
+func haalOngedaanGemaakteRegistratieIDs(c *gin.Context, tx bun.Tx, behalveID int64) ([]int64, error) {
+	ongedaanmakingen := make([]model.Registratie, 0)
+	err := tx.NewSelect().
+		Model(&ongedaanmakingen).
+		Where("registratietype = ?", model.RegistratietypeOngedaanmaking).
+		Where("id <> ?", behalveID).
+		Scan(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon ongedaanmakingen niet ophalen: %v", err)
+	}
+
+	ongedaanGemaakt, err := model.BepaalOngedaanGemaakteRegistraties(ongedaanmakingen)
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon ongedaan gemaakte registraties niet bepalen: %v", err)
+	}
+
+	ids := make([]int64, 0, len(ongedaanGemaakt))
+	for id := range ongedaanGemaakt {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// haalOngedaanmakingenVanRegistratieUitDB haalt de ongedaanmakingen op die naar een registratie verwijzen,
+// zonder de ongedaanmaking die nu verwerkt wordt (met ID behalveID).
+func haalOngedaanmakingenVanRegistratieUitDB(c *gin.Context, tx bun.Tx, registratieID int64, behalveID int64) ([]model.Registratie, error) {
+	ongedaanmakingen := make([]model.Registratie, 0)
+	err := tx.NewSelect().
+		Model(&ongedaanmakingen).
+		Where("maakt_ongedaan_registratie_id = ?", registratieID).
+		Where("id <> ?", behalveID).
+		Order("id").
+		Scan(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon ongedaanmakingen van registratie %d niet ophalen: %v", registratieID, err)
+	}
+
+	return ongedaanmakingen, nil
+}
+
+// haalWijzigingenVanRegistratieUitDB haalt de wijzigingen van een registratie op, in volgorde van verwerking.
+func haalWijzigingenVanRegistratieUitDB(c *gin.Context, tx bun.Tx, registratieID int64) ([]model.Wijziging, error) {
+	wijzigingen := make([]model.Wijziging, 0)
+	err := tx.NewSelect().
+		Model(&wijzigingen).
+		Where("registratie_id = ?", registratieID).
+		Order("id").
+		Scan(c.Request.Context())
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon wijzigingen van registratie %d niet ophalen: %v", registratieID, err)
+	}
+
+	return wijzigingen, nil
+}
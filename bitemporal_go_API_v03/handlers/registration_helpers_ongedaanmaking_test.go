@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+var wijzigingKolommen = []string{"id", "wijzigingstype", "registratie_id", "representatienaam", "entiteit_id", "representatie_id", "tijdstip"}
+
+// verwachtHeractiveringVanU verwacht de controle van een weer actieve U bij A2:
+// de U zelf, de actieve U's bij A2 (actieveRelIDs) en, als die enkelvoudig blijkt, of A2 nog actief is.
+func verwachtHeractiveringVanU(mock sqlmock.Sqlmock, relID int, actieveRelIDs ...int) {
+	mock.ExpectQuery(`SELECT .* FROM "a_u" WHERE \(rel_id = '` + strconv.Itoa(relID) + `'\) AND \(a_id = '2'\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}).AddRow(2, relID))
+	actief := sqlmock.NewRows([]string{"rel_id"})
+	for _, id := range actieveRelIDs {
+		actief.AddRow(id)
+	}
+	mock.ExpectQuery(`SELECT "rel_id" FROM "a_u" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(actief)
+	if len(actieveRelIDs) > 1 {
+		return
+	}
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 2\) AND \(opvoer <= `).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+}
+
+func TestHandleOngedaanmaking_HersteltOpvoerEnAfvoerInOmgekeerdeVolgorde(t *testing.T) {
+	// Given: registratie 7 voerde U1 af (automatisch) en U2 op.
+	// When: registratie 7 ongedaan wordt gemaakt.
+	// Then: eerst wordt de opvoer van U2 leeggemaakt, daarna wordt U1 heropend.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(2 * time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7.*ORDER BY "?id"?`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(30, "afvoer", 7, "A_U", "2", "1", tijdstip).
+			AddRow(31, "opvoer", 7, "A_U", "2", "2", tijdstip))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'.*id <> 9`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r.*IN \(\('A_U', '2', '1'\), \('A_U', '2', '2'\)\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+	mock.ExpectExec(`UPDATE "a_u" SET opvoer = NULL WHERE \(rel_id = '2'\) AND \(a_id = '2'\) AND \(opvoer = `).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = NULL WHERE \(rel_id = '1'\) AND \(a_id = '2'\) AND \(afvoer = `).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	verwachtHeractiveringVanU(mock, 1, 1)
+
+	if err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleOngedaanmaking_BrengtWijzigingenOpnieuwAanBijOngedaanmakingVanOngedaanmaking(t *testing.T) {
+	// Given: registratie 7 voerde U1 af en U2 op, en is door registratie 8 ongedaan gemaakt.
+	// When: registratie 8 ongedaan wordt gemaakt door registratie 9.
+	// Then: in oorspronkelijke volgorde wordt U1 weer afgevoerd en U2 weer opgevoerd, op het tijdstip van 7.
+	//       Registratie 7 zelf telt bij de controle op latere wijzigingen niet mee (is netto ongedaan gemaakt door 8).
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	basis := model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}
+	teOngedaanMaken := int64(7)
+	schakel := model.Registratie{ID: 8, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(time.Hour), MaaktOngedaanRegistratieID: &teOngedaanMaken}
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(2 * time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: basis, Schakels: []model.Registratie{schakel}, Heraanbrengen: true}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(30, "afvoer", 7, "A_U", "2", "1", tijdstip).
+			AddRow(31, "opvoer", 7, "A_U", "2", "2", tijdstip))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(8, "ongedaanmaking", schakel.Tijdstip, nil, nil, 7))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r.*registratie_id NOT IN \(7\)`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = '2026-01-01 07:00:00\+00:00' WHERE \(rel_id = '1'\) AND \(a_id = '2'\) AND \(afvoer IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "a_u" SET opvoer = '2026-01-01 07:00:00\+00:00' WHERE \(rel_id = '2'\) AND \(a_id = '2'\) AND \(opvoer IS NULL\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	verwachtHeractiveringVanU(mock, 2, 2)
+
+	if err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleOngedaanmaking_RejectsLaterGewijzigdeRepresentatie(t *testing.T) {
+	// Given: U2 uit registratie 7 is later door registratie 8 afgevoerd.
+	// When: registratie 7 ongedaan wordt gemaakt.
+	// Then: er volgt een 409 met verwijzing naar registratie 8 en er wordt niets hersteld.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(2 * time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(31, "opvoer", 7, "A_U", "2", "2", tijdstip))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(40, "afvoer", 8, "A_U", "2", "2", tijdstip.Add(time.Hour)))
+
+	err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten)
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestValideerOngedaanmaking_RejectsLaterRegistratie(t *testing.T) {
+	// Given: de ongedaan te maken registratie is niet eerder dan de ongedaanmaking.
+	// When: de ongedaanmaking wordt gevalideerd.
+	// Then: er volgt een 422 validatiefout.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*id = 7`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(7, "registratie", tijdstip, nil, nil, nil))
+
+	teOngedaanMaken := int64(7)
+	_, err := valideerOngedaanmaking(ctx, tx, model.Registratie{
+		ID:                         9,
+		Registratietype:            model.RegistratietypeOngedaanmaking,
+		Tijdstip:                   tijdstip,
+		MaaktOngedaanRegistratieID: &teOngedaanMaken,
+	})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestValideerOngedaanmaking_RejectsDubbeleOngedaanmaking(t *testing.T) {
+	// Given: registratie 7 is al ongedaan gemaakt door registratie 8.
+	// When: registratie 9 registratie 7 nogmaals ongedaan wil maken.
+	// Then: er volgt een 409 met verwijzing naar registratie 8.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*id = 7`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(7, "registratie", tijdstip, nil, nil, nil))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*maakt_ongedaan_registratie_id = 7.*id <> 9`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen).
+			AddRow(8, "ongedaanmaking", tijdstip.Add(time.Hour), nil, nil, 7))
+
+	teOngedaanMaken := int64(7)
+	_, err := valideerOngedaanmaking(ctx, tx, model.Registratie{
+		ID:                         9,
+		Registratietype:            model.RegistratietypeOngedaanmaking,
+		Tijdstip:                   tijdstip.Add(2 * time.Hour),
+		MaaktOngedaanRegistratieID: &teOngedaanMaken,
+	})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestSluitActieveEnkelvoudigeVoorgangersAf_NegeertOngedaanGemaakteOpvoer(t *testing.T) {
+	// Given: de opvoer van U2 bij A2 is ongedaan gemaakt (opvoer en afvoer leeg); er is geen andere actieve U.
+	// When: bij A2 een nieuwe U wordt opgevoerd.
+	// Then: U2 telt niet als actieve voorganger en wordt dus niet afgevoerd.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT "rel_id" FROM "a_u" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+
+	err := sluitActieveEnkelvoudigeVoorgangersAf(ctx, tx, 42, tijdstip, "A_U", &model.A_U{A_ID: 2}, model.MetaRegistry.MustTypeMeta("A_U"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_NegeertOngedaanGemaakteOpvoerVanOnderliggende(t *testing.T) {
+	// Given: de opvoer van U2 bij A2 is ongedaan gemaakt (opvoer en afvoer leeg).
+	// When: A2 wordt afgevoerd.
+	// Then: U2 wordt niet meegenomen in de cascade, dus geen afvoer die op "niet opgevoerd" strandt.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a" SET afvoer = .*WHERE \(id = 2\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'A', DEFAULT, '2'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(`SELECT "rel_id" FROM "a_u" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`SELECT "rel_id" FROM "a_v" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`SELECT "id" FROM "rel_a_b" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A", &model.Full_A{ID: 2}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleOngedaanmaking_RejectsHeropeningNaastActieveEnkelvoudigeOpvolger(t *testing.T) {
+	// Given: registratie 7 voerde U1 bij A2 af; daarna is U2 bij A2 opgevoerd (U is enkelvoudig).
+	// When: registratie 7 ongedaan wordt gemaakt.
+	// Then: U1 zou naast U2 actief worden: er volgt een 409 (in plaats van een unique violation).
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(2 * time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(30, "afvoer", 7, "A_U", "2", "1", tijdstip))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+	mock.ExpectExec(`UPDATE "a_u" SET afvoer = NULL WHERE \(rel_id = '1'\) AND \(a_id = '2'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	verwachtHeractiveringVanU(mock, 1, 1, 2)
+
+	err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten)
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleOngedaanmaking_RejectsHeropeningVanRelatieNaarAfgevoerdeEntiteit(t *testing.T) {
+	// Given: registratie 7 voerde Rel_A_B 4 (A1-B7) af; daarna is B7 afgevoerd.
+	// When: registratie 7 ongedaan wordt gemaakt.
+	// Then: relatie 4 zou naar een afgevoerde B wijzen: er volgt een 422.
+	ctx, tx, mock := nieuweMockTx(t)
+
+	tijdstip := time.Date(2026, 1, 1, 7, 0, 0, 0, time.UTC)
+	ongedaanmaking := model.Registratie{ID: 9, Registratietype: model.RegistratietypeOngedaanmaking, Tijdstip: tijdstip.Add(2 * time.Hour)}
+	keten := model.Ongedaanmakingsketen{Basis: model.Registratie{ID: 7, Registratietype: model.RegistratietypeRegistratie, Tijdstip: tijdstip}}
+
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*registratie_id = 7`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(30, "afvoer", 7, "Rel_A_B", nil, "4", tijdstip))
+	mock.ExpectQuery(`SELECT .*FROM "registratie".*registratietype = 'ongedaanmaking'`).
+		WillReturnRows(sqlmock.NewRows(registratieKolommen))
+	mock.ExpectQuery(`SELECT .*FROM "wijziging".*JOIN registratie AS r`).
+		WillReturnRows(sqlmock.NewRows(wijzigingKolommen))
+	mock.ExpectExec(`UPDATE "rel_a_b" SET afvoer = NULL WHERE \(id = '4'\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT .* FROM "rel_a_b" WHERE \(id = '4'\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "a_id", "b_id"}).AddRow(4, 1, 7))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 1\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "b" WHERE \(id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	err := handleOngedaanmaking(ctx, tx, ongedaanmaking, keten)
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
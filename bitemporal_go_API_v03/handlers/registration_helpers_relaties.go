@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== REFERENTIËLE INTEGRITEIT VAN RELATIES ===========================
+
+Een relatie (MetaRegistry: MetatypeRelatie, bijv. Rel_A_B) verwijst naar twee entiteiten:
+- de primaire entiteit (EntiteitIDKolom, bijv. a_id): de entiteit waar de relatie onder hangt
+- de secundaire entiteit (SecondaireEntiteitIDKolom + SecondaireEntiteittype, bijv. b_id naar B)
+
+OPVOER: beide entiteiten moeten op het tijdstip van de registratie bestaan en actief zijn
+(opgevoerd, uiterlijk op dat tijdstip, en niet afgevoerd). Anders volgt een 422.
+Een entiteit die in dezelfde registratie eerder is opgevoerd telt mee.
+
+AFVOER van een entiteit: per kant van de relatie bepaalt het afvoerbeleid (model.Afvoerbeleid) wat er
+met de actieve relaties gebeurt:
+- primaire kant: OnderliggendGegevenselement.Afvoerbeleid van de entiteit (geldt voor alle gegevenselementen/relaties),
+  zie handleRepresentatieAfvoer
+- secundaire kant: TypeMeta.SecondairAfvoerbeleid van de relatie, zie handleAfvoerVerwijzendeRelaties
+Cascade voert ze mee af (met eigen afvoer wijzigingen), restrict weigert de afvoer met een 409,
+detach laat ze actief (de consistentiecontrole meldt ze dan ook niet).
+*/
+
+// valideerRelatieEindpunten controleert bij de opvoer van een relatie dat beide entiteiten actief zijn.
+func valideerRelatieEindpunten(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.Representatie, tijdstip time.Time) error {
+	if meta.Metatype != model.MetatypeRelatie {
+		return nil
+	}
+
+	bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: geen bovenliggende entiteit gevonden voor relatie %s", meta.Typenaam)
+	}
+	if err := valideerRelatieEindpunt(c, tx, meta, representatie, bovenliggend.ParentType, meta.EntiteitIDKolom, tijdstip); err != nil {
+		return err
+	}
+
+	if meta.SecondaireEntiteittype == "" {
+		return nil
+	}
+	secundair, ok := model.MetaRegistry.GetTypeMeta(meta.SecondaireEntiteittype)
+	if !ok {
+		return fmt.Errorf("HANDLER: geen metadata voor %s (secundaire entiteit van %s)", meta.SecondaireEntiteittype, meta.Typenaam)
+	}
+	return valideerRelatieEindpunt(c, tx, meta, representatie, secundair, meta.SecondaireEntiteitIDKolom, tijdstip)
+}
+
+// valideerBovenliggendeEntiteit controleert dat de entiteit waar een gegevenselement bij hoort actief is op het tijdstip.
+func valideerBovenliggendeEntiteit(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.Representatie, tijdstip time.Time) error {
+	bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+	if !ok {
+		return fmt.Errorf("HANDLER: geen bovenliggende entiteit gevonden voor %s", meta.Typenaam)
+	}
+	return valideerRelatieEindpunt(c, tx, meta, representatie, bovenliggend.ParentType, meta.EntiteitIDKolom, tijdstip)
+}
+
+// valideerRelatieEindpunt controleert één kant van een relatie: de entiteit in kolom fkKolom moet actief zijn op het tijdstip.
+func valideerRelatieEindpunt(c *gin.Context, tx bun.Tx, meta model.TypeMeta, representatie model.Representatie,
+	entiteitMeta model.TypeMeta, fkKolom string, tijdstip time.Time) error {
+	entiteitID, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, fkKolom)
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", fkKolom, meta.Typenaam, err)
+	}
+	if entiteitID == 0 {
+		return nieuweValidatieFout(http.StatusBadRequest, "%s ontbreekt voor %s %v", fkKolom, meta.Typenaam, representatie.GetID())
+	}
+
+	actief, err := tx.NewSelect().
+		Table(entiteitMeta.Tabelnaam).
+		Where(fmt.Sprintf("%s = ?", entiteitMeta.IDKolom), entiteitID).
+		Where("opvoer <= ?", tijdstip).
+		Where("afvoer IS NULL").
+		Exists(c.Request.Context())
+	if err != nil {
+		return fmt.Errorf("HANDLER: kon %s %d niet raadplegen: %v", entiteitMeta.Typenaam, entiteitID, err)
+	}
+	if !actief {
+		return nieuweValidatieFout(http.StatusUnprocessableEntity, "%s %v verwijst naar %s %d, die op %s niet bestaat of niet actief is",
+			meta.Typenaam, representatie.GetID(), entiteitMeta.Typenaam, entiteitID, tijdstip.Format(time.RFC3339Nano))
+	}
+
+	return nil
+}
+
+// handleAfvoerVerwijzendeRelaties past bij afvoer van een entiteit het SecondairAfvoerbeleid toe
+// op de actieve relaties die (als secundaire entiteit) naar haar verwijzen, bijv. Rel_A_B bij afvoer van B.
+func handleAfvoerVerwijzendeRelaties(c *gin.Context, tx bun.Tx, registratieID int64, afvoerTijdstip time.Time,
+	entiteitMeta model.TypeMeta, entiteitID int) error {
+	for _, relatieMeta := range model.MetaRegistry.GetVerwijzendeRelatieMetas(entiteitMeta.Typenaam) {
+		fkColumn := relatieMeta.SecondaireEntiteitIDKolom
+		activeIDs, err := haalActieveIDsGegevenselementUitDB(c, tx, relatieMeta, fkColumn, entiteitID)
+		if err != nil {
+			return err
+		}
+
+		if err := pasAfvoerbeleidToe(c, tx, registratieID, afvoerTijdstip, entiteitMeta, entiteitID,
+			relatieMeta, relatieMeta.SecondairAfvoerbeleid, fkColumn, activeIDs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pasAfvoerbeleidToe handelt de actieve representaties af die bij afvoer van een entiteit naar haar verwijzen (via fkColumn):
+// bij cascade worden ze afgevoerd (met afvoer wijzigingen), bij restrict wordt de afvoer van de entiteit geweigerd,
+// bij detach blijven ze actief.
+func pasAfvoerbeleidToe(c *gin.Context, tx bun.Tx, registratieID int64, afvoerTijdstip time.Time,
+	entiteitMeta model.TypeMeta, entiteitID int, meta model.TypeMeta, beleid model.Afvoerbeleid, fkColumn string, activeIDs []int) error {
+	switch beleid.MetStandaard() {
+	case model.AfvoerbeleidRestrict:
+		if len(activeIDs) > 0 {
+			return nieuweValidatieFout(http.StatusConflict, "%s %d kan niet afgevoerd worden: er zijn nog actieve %s records (%s) %v",
+				entiteitMeta.Typenaam, entiteitID, meta.Typenaam, fkColumn, activeIDs)
+		}
+		return nil
+	case model.AfvoerbeleidCascade:
+		for _, id := range activeIDs {
+			if err := updateAfvoerByID(c, tx, meta, id, waarRepresentatieBijEntiteit(meta, fkColumn, entiteitID, id), afvoerTijdstip); err != nil {
+				return err
+			}
+			if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID,
+				sleutelBijEntiteit(meta, fkColumn, entiteitID, id), afvoerTijdstip); err != nil {
+				return err
+			}
+		}
+		return nil
+	case model.AfvoerbeleidDetach:
+		return nil
+	default:
+		return fmt.Errorf("HANDLER: onbekend afvoerbeleid '%s' voor %s", beleid, meta.Typenaam)
+	}
+}
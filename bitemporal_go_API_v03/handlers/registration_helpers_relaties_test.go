@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+)
+
+// metAfvoerbeleid vervangt tijdens de test het secundaire afvoerbeleid van een relatietype in de MetaRegistry.
+func metAfvoerbeleid(t *testing.T, typenaam string, beleid model.Afvoerbeleid) {
+	t.Helper()
+	oud := model.MetaRegistry.MustTypeMeta(typenaam)
+	nieuw := oud
+	nieuw.SecondairAfvoerbeleid = beleid
+	model.MetaRegistry[typenaam] = nieuw
+	t.Cleanup(func() { model.MetaRegistry[typenaam] = oud })
+}
+
+func TestValideerRelatieEindpunten_RejectsInactiveSecondaryEntity(t *testing.T) {
+	// Given: A1 is actief, B7 niet (afgevoerd of onbekend).
+	// When: een Rel_A_B van A1 naar B7 wordt opgevoerd.
+	// Then: er volgt een 422 die B7 noemt, nog vóór de insert.
+	ctx, tx, mock := nieuweMockTx(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "a" WHERE \(id = 1\) AND \(opvoer <= '2026-02-25 10:00:00\+00:00'\) AND \(afvoer IS NULL\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT .*FROM "b" WHERE \(id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	err := handleRepresentatieOpvoerMeta(ctx, tx, 42, tijdstip, "Rel_A_B", &model.Rel_A_B{A_ID: 1, B_ID: 7})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_CascadesToRelationsPointingAtEntity(t *testing.T) {
+	// Given: Rel_A_B 4 verwijst naar B7; het secundaire afvoerbeleid is cascade.
+	// When: B7 wordt afgevoerd.
+	// Then: ook relatie 4 wordt afgevoerd, met een eigen afvoer wijziging.
+	ctx, tx, mock := nieuweMockTx(t)
+	metAfvoerbeleid(t, "Rel_A_B", model.AfvoerbeleidCascade)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "b" SET afvoer = .*WHERE \(id = 7\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'B', DEFAULT, '7'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(`SELECT "rel_id" FROM "b_x" WHERE \(b_id = 7\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`SELECT "rel_id" FROM "b_y" WHERE \(b_id = 7\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`SELECT "id" FROM "rel_a_b" WHERE \(b_id = 7\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec(`UPDATE "rel_a_b" SET afvoer = .*WHERE \(id = 4\) AND \(b_id = 7\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'afvoer'.*'Rel_A_B', DEFAULT, '4'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+
+	if err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "B", &model.Full_B{ID: 7}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_RestrictRejectsWhileRelationsActive(t *testing.T) {
+	// Given: Rel_A_B 4 verwijst naar B7; het secundaire afvoerbeleid is restrict.
+	// When: B7 wordt afgevoerd.
+	// Then: er volgt een 409 en relatie 4 wordt niet aangeraakt.
+	ctx, tx, mock := nieuweMockTx(t)
+	metAfvoerbeleid(t, "Rel_A_B", model.AfvoerbeleidRestrict)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "b" SET afvoer = `).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(`FROM "b_x"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`FROM "b_y"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`SELECT "id" FROM "rel_a_b" WHERE \(b_id = 7\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+
+	err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "B", &model.Full_B{ID: 7})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+// metOnderliggendAfvoerbeleid vervangt tijdens de test het afvoerbeleid van een rol van een entiteit in de MetaRegistry.
+func metOnderliggendAfvoerbeleid(t *testing.T, typenaam string, rolnaam string, beleid model.Afvoerbeleid) {
+	t.Helper()
+	oud := model.MetaRegistry.MustTypeMeta(typenaam)
+	nieuw := oud
+	nieuw.OnderliggendeGegevenselementen = append([]model.OnderliggendGegevenselement(nil), oud.OnderliggendeGegevenselementen...)
+	for i := range nieuw.OnderliggendeGegevenselementen {
+		if nieuw.OnderliggendeGegevenselementen[i].Rolnaam == rolnaam {
+			nieuw.OnderliggendeGegevenselementen[i].Afvoerbeleid = beleid
+		}
+	}
+	model.MetaRegistry[typenaam] = nieuw
+	t.Cleanup(func() { model.MetaRegistry[typenaam] = oud })
+}
+
+func TestHandleRepresentatieAfvoer_RestrictOnRolRejectsWhileChildrenActive(t *testing.T) {
+	// Given: A2 heeft een actieve U1; de rol Us heeft afvoerbeleid restrict.
+	// When: A2 wordt afgevoerd.
+	// Then: er volgt een 409; U1 wordt niet afgevoerd.
+	ctx, tx, mock := nieuweMockTx(t)
+	metOnderliggendAfvoerbeleid(t, "A", "Us", model.AfvoerbeleidRestrict)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a" SET afvoer = .*WHERE \(id = 2\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging".*'A', DEFAULT, '2'`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(`SELECT "rel_id" FROM "a_u" WHERE \(a_id = 2\) AND \(opvoer IS NOT NULL\) AND \(afvoer IS NULL\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}).AddRow(1))
+
+	err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A", &model.Full_A{ID: 2})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409 validation error, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestHandleRepresentatieAfvoer_DetachLeavesChildrenActive(t *testing.T) {
+	// Given: A2 heeft een actieve V3; de rol Vs heeft afvoerbeleid detach.
+	// When: A2 wordt afgevoerd.
+	// Then: A2 wordt afgevoerd, V3 blijft actief (geen update, geen wijziging).
+	ctx, tx, mock := nieuweMockTx(t)
+	metOnderliggendAfvoerbeleid(t, "A", "Vs", model.AfvoerbeleidDetach)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE "a" SET afvoer = `).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectQuery(`FROM "a_u"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}))
+	mock.ExpectQuery(`FROM "a_v"`).
+		WillReturnRows(sqlmock.NewRows([]string{"rel_id"}).AddRow(3))
+	mock.ExpectQuery(`FROM "rel_a_b"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if err := handleRepresentatieAfvoer(ctx, tx, 42, tijdstip, "A", &model.Full_A{ID: 2}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
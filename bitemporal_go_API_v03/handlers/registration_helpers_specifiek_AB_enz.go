@@ -87,7 +87,7 @@ func handleOpvoerFullA(c *gin.Context, tx bun.Tx, fullA *model.Full_A, registrat
 	}
 
 	// Create wijziging record for A
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, "A", fmt.Sprintf("%d", fullA.ID), tijdstip); err != nil {
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("A"), "", 0, fullA.ID), tijdstip); err != nil {
 		return err
 	}
 
@@ -142,7 +142,11 @@ func handleOpvoerElement[T model.HasID](c *gin.Context, tx bun.Tx, element *T,
 		return fmt.Errorf("failed to insert %s: %v", representatienaam, err)
 	}
 
-	return persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, representatienaam, fmt.Sprint((*element).GetID()), tijdstip)
+	sleutel, err := sleutelVoorRepresentatie(model.MetaRegistry.MustTypeMeta(representatienaam), *element)
+	if err != nil {
+		return err
+	}
+	return persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, sleutel, tijdstip)
 }
 
 // handleAfvoerA processes an afvoer for Full_A or its data elements
@@ -154,10 +158,10 @@ func handleAfvoerA(c *gin.Context, tx bun.Tx, afvoer *model.OpvoerAfvoerA, regis
 
 	// Scenario 3: Afvoer van individuele gegevenselementen
 	if afvoer.U != nil {
-		return handleAfvoerA_U(c, tx, afvoer.U.Rel_ID, registratieID, tijdstip)
+		return handleAfvoerA_U(c, tx, afvoer.U.A_ID, afvoer.U.Rel_ID, registratieID, tijdstip)
 	}
 	if afvoer.V != nil {
-		return handleAfvoerA_V(c, tx, afvoer.V.Rel_ID, registratieID, tijdstip)
+		return handleAfvoerA_V(c, tx, afvoer.V.A_ID, afvoer.V.Rel_ID, registratieID, tijdstip)
 	}
 	if afvoer.Rel_A_B != nil {
 		return handleAfvoerRel_A_B(c, tx, afvoer.Rel_A_B.ID, registratieID, tijdstip)
@@ -166,14 +170,14 @@ func handleAfvoerA(c *gin.Context, tx bun.Tx, afvoer *model.OpvoerAfvoerA, regis
 	// Batch afvoer
 	if len(afvoer.Us) > 0 {
 		for _, u := range afvoer.Us {
-			if err := handleAfvoerA_U(c, tx, u.Rel_ID, registratieID, tijdstip); err != nil {
+			if err := handleAfvoerA_U(c, tx, u.A_ID, u.Rel_ID, registratieID, tijdstip); err != nil {
 				return err
 			}
 		}
 	}
 	if len(afvoer.Vs) > 0 {
 		for _, v := range afvoer.Vs {
-			if err := handleAfvoerA_V(c, tx, v.Rel_ID, registratieID, tijdstip); err != nil {
+			if err := handleAfvoerA_V(c, tx, v.A_ID, v.Rel_ID, registratieID, tijdstip); err != nil {
 				return err
 			}
 		}
@@ -202,7 +206,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	}
 
 	// Create wijziging record for A
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "A", fmt.Sprintf("%d", aID), tijdstip); err != nil {
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("A"), "", 0, aID), tijdstip); err != nil {
 		return err
 	}
 
@@ -211,6 +215,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	err = tx.NewSelect().
 		Model(&activeUs).
 		Where("a_id = ?", aID).
+		Where("opvoer IS NOT NULL").
 		Where("afvoer IS NULL").
 		Scan(c.Request.Context())
 	if err != nil && err != sql.ErrNoRows {
@@ -218,7 +223,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	}
 
 	for _, u := range activeUs {
-		if err := handleAfvoerA_U(c, tx, u.Rel_ID, registratieID, tijdstip); err != nil {
+		if err := handleAfvoerA_U(c, tx, u.A_ID, u.Rel_ID, registratieID, tijdstip); err != nil {
 			return err
 		}
 	}
@@ -228,6 +233,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	err = tx.NewSelect().
 		Model(&activeVs).
 		Where("a_id = ?", aID).
+		Where("opvoer IS NOT NULL").
 		Where("afvoer IS NULL").
 		Scan(c.Request.Context())
 	if err != nil && err != sql.ErrNoRows {
@@ -235,7 +241,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	}
 
 	for _, v := range activeVs {
-		if err := handleAfvoerA_V(c, tx, v.Rel_ID, registratieID, tijdstip); err != nil {
+		if err := handleAfvoerA_V(c, tx, v.A_ID, v.Rel_ID, registratieID, tijdstip); err != nil {
 			return err
 		}
 	}
@@ -245,6 +251,7 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 	err = tx.NewSelect().
 		Model(&activeRels).
 		Where("a_id = ?", aID).
+		Where("opvoer IS NOT NULL").
 		Where("afvoer IS NULL").
 		Scan(c.Request.Context())
 	if err != nil && err != sql.ErrNoRows {
@@ -261,11 +268,12 @@ func handleAfvoerFullA(c *gin.Context, tx bun.Tx, aID int, registratieID int64,
 }
 
 // handleAfvoerA_U marks A_U as afgevoerd
-func handleAfvoerA_U(c *gin.Context, tx bun.Tx, relID int, registratieID int64, tijdstip time.Time) error {
+func handleAfvoerA_U(c *gin.Context, tx bun.Tx, aID int, relID int, registratieID int64, tijdstip time.Time) error {
 	// Update afvoer timestamp
 	_, err := tx.NewUpdate().
 		Model((*model.A_U)(nil)).
 		Set("afvoer = ?", tijdstip).
+		Where("a_id = ?", aID).
 		Where("rel_id = ?", relID).
 		Exec(c.Request.Context())
 	if err != nil {
@@ -273,15 +281,16 @@ func handleAfvoerA_U(c *gin.Context, tx bun.Tx, relID int, registratieID int64,
 	}
 
 	// Create wijziging record
-	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "A_U", fmt.Sprintf("%d", relID), tijdstip)
+	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("A_U"), "a_id", aID, relID), tijdstip)
 }
 
 // handleAfvoerA_V marks A_V as afgevoerd
-func handleAfvoerA_V(c *gin.Context, tx bun.Tx, relID int, registratieID int64, tijdstip time.Time) error {
+func handleAfvoerA_V(c *gin.Context, tx bun.Tx, aID int, relID int, registratieID int64, tijdstip time.Time) error {
 	// Update afvoer timestamp
 	_, err := tx.NewUpdate().
 		Model((*model.A_V)(nil)).
 		Set("afvoer = ?", tijdstip).
+		Where("a_id = ?", aID).
 		Where("rel_id = ?", relID).
 		Exec(c.Request.Context())
 	if err != nil {
@@ -289,7 +298,7 @@ func handleAfvoerA_V(c *gin.Context, tx bun.Tx, relID int, registratieID int64,
 	}
 
 	// Create wijziging record
-	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "A_V", fmt.Sprintf("%d", relID), tijdstip)
+	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("A_V"), "a_id", aID, relID), tijdstip)
 }
 
 // handleAfvoerRel_A_B marks Rel_A_B as afgevoerd
@@ -305,7 +314,7 @@ func handleAfvoerRel_A_B(c *gin.Context, tx bun.Tx, id int, registratieID int64,
 	}
 
 	// Create wijziging record
-	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "Rel_A_B", fmt.Sprintf("%d", id), tijdstip)
+	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("Rel_A_B"), "", 0, id), tijdstip)
 }
 
 // handleOpvoerB processes an opvoer for Full_B or its data elements
@@ -358,7 +367,7 @@ func handleOpvoerFullB(c *gin.Context, tx bun.Tx, fullB *model.Full_B, registrat
 		return fmt.Errorf("failed to insert B: %v", err)
 	}
 
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, "B", fmt.Sprintf("%d", fullB.ID), tijdstip); err != nil {
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeOpvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("B"), "", 0, fullB.ID), tijdstip); err != nil {
 		return err
 	}
 
@@ -394,22 +403,22 @@ func handleAfvoerB(c *gin.Context, tx bun.Tx, afvoer *model.OpvoerAfvoerB, regis
 	}
 
 	if afvoer.X != nil {
-		return handleAfvoerB_X(c, tx, afvoer.X.Rel_ID, registratieID, tijdstip)
+		return handleAfvoerB_X(c, tx, afvoer.X.B_ID, afvoer.X.Rel_ID, registratieID, tijdstip)
 	}
 	if afvoer.Y != nil {
-		return handleAfvoerB_Y(c, tx, afvoer.Y.Rel_ID, registratieID, tijdstip)
+		return handleAfvoerB_Y(c, tx, afvoer.Y.B_ID, afvoer.Y.Rel_ID, registratieID, tijdstip)
 	}
 
 	if len(afvoer.Xs) > 0 {
 		for _, x := range afvoer.Xs {
-			if err := handleAfvoerB_X(c, tx, x.Rel_ID, registratieID, tijdstip); err != nil {
+			if err := handleAfvoerB_X(c, tx, x.B_ID, x.Rel_ID, registratieID, tijdstip); err != nil {
 				return err
 			}
 		}
 	}
 	if len(afvoer.Ys) > 0 {
 		for _, y := range afvoer.Ys {
-			if err := handleAfvoerB_Y(c, tx, y.Rel_ID, registratieID, tijdstip); err != nil {
+			if err := handleAfvoerB_Y(c, tx, y.B_ID, y.Rel_ID, registratieID, tijdstip); err != nil {
 				return err
 			}
 		}
@@ -429,7 +438,7 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 		return fmt.Errorf("failed to update B afvoer: %v", err)
 	}
 
-	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "B", fmt.Sprintf("%d", bID), tijdstip); err != nil {
+	if err := persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("B"), "", 0, bID), tijdstip); err != nil {
 		return err
 	}
 
@@ -437,6 +446,7 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 	err = tx.NewSelect().
 		Model(&activeXs).
 		Where("b_id = ?", bID).
+		Where("opvoer IS NOT NULL").
 		Where("afvoer IS NULL").
 		Scan(c.Request.Context())
 	if err != nil && err != sql.ErrNoRows {
@@ -444,7 +454,7 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 	}
 
 	for _, x := range activeXs {
-		if err := handleAfvoerB_X(c, tx, x.Rel_ID, registratieID, tijdstip); err != nil {
+		if err := handleAfvoerB_X(c, tx, x.B_ID, x.Rel_ID, registratieID, tijdstip); err != nil {
 			return err
 		}
 	}
@@ -453,6 +463,7 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 	err = tx.NewSelect().
 		Model(&activeYs).
 		Where("b_id = ?", bID).
+		Where("opvoer IS NOT NULL").
 		Where("afvoer IS NULL").
 		Scan(c.Request.Context())
 	if err != nil && err != sql.ErrNoRows {
@@ -460,7 +471,7 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 	}
 
 	for _, y := range activeYs {
-		if err := handleAfvoerB_Y(c, tx, y.Rel_ID, registratieID, tijdstip); err != nil {
+		if err := handleAfvoerB_Y(c, tx, y.B_ID, y.Rel_ID, registratieID, tijdstip); err != nil {
 			return err
 		}
 	}
@@ -469,31 +480,33 @@ func handleAfvoerFullB(c *gin.Context, tx bun.Tx, bID int, registratieID int64,
 }
 
 // handleAfvoerB_X marks B_X as afgevoerd
-func handleAfvoerB_X(c *gin.Context, tx bun.Tx, relID int, registratieID int64, tijdstip time.Time) error {
+func handleAfvoerB_X(c *gin.Context, tx bun.Tx, bID int, relID int, registratieID int64, tijdstip time.Time) error {
 	// Update afvoer timestamp
 	_, err := tx.NewUpdate().
 		Model((*model.B_X)(nil)).
 		Set("afvoer = ?", tijdstip).
+		Where("b_id = ?", bID).
 		Where("rel_id = ?", relID).
 		Exec(c.Request.Context())
 	if err != nil {
 		return fmt.Errorf("failed to update B_X afvoer: %v", err)
 	}
 
-	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "B_X", fmt.Sprintf("%d", relID), tijdstip)
+	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("B_X"), "b_id", bID, relID), tijdstip)
 }
 
 // handleAfvoerB_Y marks B_Y as afgevoerd
-func handleAfvoerB_Y(c *gin.Context, tx bun.Tx, relID int, registratieID int64, tijdstip time.Time) error {
+func handleAfvoerB_Y(c *gin.Context, tx bun.Tx, bID int, relID int, registratieID int64, tijdstip time.Time) error {
 	// Update afvoer timestamp
 	_, err := tx.NewUpdate().
 		Model((*model.B_Y)(nil)).
 		Set("afvoer = ?", tijdstip).
+		Where("b_id = ?", bID).
 		Where("rel_id = ?", relID).
 		Exec(c.Request.Context())
 	if err != nil {
 		return fmt.Errorf("failed to update B_Y afvoer: %v", err)
 	}
 
-	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, "B_Y", fmt.Sprintf("%d", relID), tijdstip)
+	return persisteerWijziging(c, tx, model.WijzigingstypeAfvoer, registratieID, sleutelBijEntiteit(model.MetaRegistry.MustTypeMeta("B_Y"), "b_id", bID, relID), tijdstip)
 }
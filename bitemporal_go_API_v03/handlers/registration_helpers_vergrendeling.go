@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+/*
+===================== VERGRENDELING (PESSIMISTISCHE CONCURRENCY) ===========================
+
+Twee gelijktijdige registraties op dezelfde entiteit zien elkaars (nog niet gecommitte) wijzigingen niet.
+Zo kunnen ze bijvoorbeeld allebei "geen actieve voorganger" zien bij de opvoer van een enkelvoudig gegevenselement,
+en allebei een U opvoeren bij dezelfde A.
+
+Daarom vergrendelt een registratie de entiteiten die ze raakt, vóór ze iets schrijft:
+- welke entiteiten: afgeleid uit de wijzigingen in het request (de entiteit zelf, of bij een gegevenselement/relatie
+  de bovenliggende entiteit, en bij een relatie ook de entiteit aan de andere kant)
+- een entiteit zonder id (nog) niet: die kan een andere registratie nog niet raken
+- hoe: pg_advisory_xact_lock(hashtext(typenaam), id), vrijgegeven bij commit of rollback
+- wanneer: direct na de start van de transactie, dus ook vóór het bepalen van het registratietijdstip.
+  Zo krijgt de registratie die moest wachten ook een later tijdstip.
+- in vaste volgorde (typenaam, id), zodat twee registraties op dezelfde entiteiten niet op elkaar vastlopen
+
+Een ongedaanmaking heeft geen wijzigingen in het request: die vergrendelt de entiteiten die de ongedaan te maken
+registratie raakte, zodra die bekend zijn en vóór de representaties worden teruggezet.
+Het is haar enige (gesorteerde) vergrendeling, dus ook dan in de vaste volgorde.
+
+Loopt een poging toch vast op een serialisatiefout (40001), een deadlock (40P01) of een botsend registratietijdstip
+(23505 op registratieTijdstipConstraint), dan wordt de hele registratie in een nieuwe transactie opnieuw uitgevoerd,
+maximaal maxRegistratiePogingen keer, met een oplopende wachttijd.
+*/
+
+// maxRegistratiePogingen is het maximale aantal pogingen van één registratie.
+const maxRegistratiePogingen = 3
+
+// registratieWachttijd is de wachttijd vóór de tweede poging; daarna verdubbelt die per poging.
+// Een variabele, zodat tests niet hoeven te wachten.
+var registratieWachttijd = 20 * time.Millisecond
+
+// teVergrendelenEntiteiten geeft de entiteiten die de wijzigingen in het request raken, gesorteerd op typenaam en id.
+func teVergrendelenEntiteiten(request model.RegistreerRequest) ([]entiteitSleutel, error) {
+	sleutels := map[entiteitSleutel]bool{}
+	for i, wijziging := range request.Wijzigingen {
+		rep := wijziging.Opvoer
+		if rep == nil {
+			rep = wijziging.Afvoer
+		}
+		if rep == nil {
+			rep = wijziging.Materieel
+		}
+		if rep == nil || rep.Representatie == nil {
+			// de verwerking van de wijziging geeft hiervoor een 400
+			continue
+		}
+
+		entiteiten, err := entiteitenVanRepresentatie(rep.Representatienaam, rep.Representatie)
+		if err != nil {
+			return nil, fmt.Errorf("wijzigingen[%d]: %v", i, err)
+		}
+		for _, sleutel := range entiteiten {
+			sleutels[sleutel] = true
+		}
+	}
+
+	gesorteerd := make([]entiteitSleutel, 0, len(sleutels))
+	for sleutel := range sleutels {
+		gesorteerd = append(gesorteerd, sleutel)
+	}
+	sorteerEntiteitSleutels(gesorteerd)
+	return gesorteerd, nil
+}
+
+// entiteitenVanRepresentatie geeft de entiteiten waar een representatie bij hoort, voor zover het id bekend is:
+// de entiteit zelf, of de bovenliggende entiteit en (bij een relatie) de entiteit aan de andere kant.
+func entiteitenVanRepresentatie(representatienaam string, representatie model.Representatie) ([]entiteitSleutel, error) {
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatienaam)
+	if !ok {
+		return nil, fmt.Errorf("HANDLER: geen metadata voor %s", representatienaam)
+	}
+
+	var sleutels []entiteitSleutel
+	voegToe := func(typenaam string, kolom string) error {
+		id, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, kolom)
+		if err != nil {
+			return fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", kolom, meta.Typenaam, err)
+		}
+		if id != 0 {
+			sleutels = append(sleutels, entiteitSleutel{Typenaam: typenaam, ID: id})
+		}
+		return nil
+	}
+
+	if meta.Metatype == model.MetatypeEntiteit {
+		return sleutels, voegToe(meta.Typenaam, meta.IDKolom)
+	}
+
+	bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+	if !ok {
+		return nil, fmt.Errorf("HANDLER: geen bovenliggende entiteit gevonden voor %s", meta.Typenaam)
+	}
+	if err := voegToe(bovenliggend.ParentType.Typenaam, meta.EntiteitIDKolom); err != nil {
+		return nil, err
+	}
+	if meta.SecondaireEntiteittype != "" {
+		if err := voegToe(meta.SecondaireEntiteittype, meta.SecondaireEntiteitIDKolom); err != nil {
+			return nil, err
+		}
+	}
+	return sleutels, nil
+}
+
+// geraakteEntiteitSleutels zet geraakte entiteiten om naar sleutels, gesorteerd op typenaam en id.
+func geraakteEntiteitSleutels(geraakt geraakteEntiteiten) []entiteitSleutel {
+	var sleutels []entiteitSleutel
+	for typenaam, ids := range geraakt {
+		for id := range ids {
+			sleutels = append(sleutels, entiteitSleutel{Typenaam: typenaam, ID: id})
+		}
+	}
+	sorteerEntiteitSleutels(sleutels)
+	return sleutels
+}
+
+// vergrendelEntiteiten neemt per entiteit een advisory lock tot het eind van de transactie, in de gegeven volgorde.
+// Is de entiteit al vergrendeld door een andere transactie, dan wacht deze tot die klaar is.
+func vergrendelEntiteiten(c *gin.Context, tx bun.Tx, sleutels []entiteitSleutel) error {
+	for _, sleutel := range sleutels {
+		_, err := tx.ExecContext(c.Request.Context(),
+			"SELECT pg_advisory_xact_lock(hashtext(?), ?)", sleutel.Typenaam, sleutel.ID)
+		if err != nil {
+			return fmt.Errorf("HANDLER: kon %s %d niet vergrendelen: %v", sleutel.Typenaam, sleutel.ID, err)
+		}
+	}
+	return nil
+}
+
+// isHerhaalbareFout geeft aan of een poging is gestrand op een serialisatiefout, deadlock of botsend registratietijdstip,
+// zodat de registratie in een nieuwe transactie opnieuw kan worden uitgevoerd.
+// De helpers geven databasefouten meestal als tekst door (%v), dus we kijken ook naar de SQLSTATE in de melding.
+func isHerhaalbareFout(err error) bool {
+	if err == nil {
+		return false
+	}
+	var pgFout pgdriver.Error
+	if errors.As(err, &pgFout) {
+		code := pgFout.Field('C')
+		return code == "40001" || code == "40P01" ||
+			(code == "23505" && pgFout.Field('n') == registratieTijdstipConstraint)
+	}
+	melding := err.Error()
+	return strings.Contains(melding, "SQLSTATE=40001") || strings.Contains(melding, "SQLSTATE=40P01") ||
+		(strings.Contains(melding, "SQLSTATE=23505") && strings.Contains(melding, `"`+registratieTijdstipConstraint+`"`))
+}
+
+// wachtOpNieuwePoging wacht na de mislukte poging (1, 2, ...) met een verdubbelende wachttijd plus wat spreiding,
+// zodat gelijktijdige registraties elkaar niet opnieuw treffen. Geeft false als het request intussen is afgebroken.
+func wachtOpNieuwePoging(c *gin.Context, poging int) bool {
+	wachttijd := registratieWachttijd << (poging - 1)
+	if wachttijd > 0 {
+		wachttijd += time.Duration(rand.Int63n(int64(wachttijd)/2 + 1))
+	}
+	select {
+	case <-time.After(wachttijd):
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
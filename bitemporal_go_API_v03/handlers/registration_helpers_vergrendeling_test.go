@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// verwachtVergrendeling verwacht de advisory lock op een entiteit.
+func verwachtVergrendeling(mock sqlmock.Sqlmock, typenaam string, id int) {
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(hashtext\('` + typenaam + `'\), ` + strconv.Itoa(id) + `\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// zonderWachttijd zet de wachttijd tussen pogingen tijdens de test op 0.
+func zonderWachttijd(t *testing.T) {
+	oud := registratieWachttijd
+	registratieWachttijd = 0
+	t.Cleanup(func() { registratieWachttijd = oud })
+}
+
+const serialisatiefout = "ERROR: could not serialize access due to concurrent update (SQLSTATE=40001)"
+
+func TestTeVergrendelenEntiteiten_SortedAndIncludingBothEndsOfARelatie(t *testing.T) {
+	// Given: wijzigingen op een relatie A3-B1, twee keer op A2 (een V en een U) en een nieuwe A zonder id.
+	// When: de te vergrendelen entiteiten worden bepaald.
+	// Then: A2, A3 en B1, elk één keer en in vaste volgorde; de nieuwe A niet.
+	var request model.RegistreerRequest
+	body := `{"registratie": {"registratietype": "registratie"}, "wijzigingen": [
+		{"opvoer": {"rel_a_b": {"a_id": 3, "b_id": 1}}},
+		{"afvoer": {"v": {"a_id": 2, "rel_id": 3}}},
+		{"opvoer": {"a": {"id": 0}}},
+		{"opvoer": {"u": {"a_id": 2}}}]}`
+	if err := binding.JSON.BindBody([]byte(body), &request); err != nil {
+		t.Fatalf("invalid request: %v", err)
+	}
+
+	sleutels, err := teVergrendelenEntiteiten(request)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	verwacht := []entiteitSleutel{{Typenaam: "A", ID: 2}, {Typenaam: "A", ID: 3}, {Typenaam: "B", ID: 1}}
+	if !reflect.DeepEqual(sleutels, verwacht) {
+		t.Fatalf("expected %v, got %v", verwacht, sleutels)
+	}
+}
+
+func TestIsHerhaalbareFout_SerializationFailureDeadlockAndTijdstipCollisionOnly(t *testing.T) {
+	// Given: een doorgegeven serialisatiefout, een deadlock, een botsend registratietijdstip en een andere unique violation.
+	// When: bepaald wordt of een nieuwe poging zin heeft.
+	// Then: alleen bij de serialisatiefout, de deadlock en het botsende registratietijdstip.
+	gevallen := map[string]bool{
+		"HANDLER: failed to update A_V afvoer: " + serialisatiefout: true,
+		"ERROR: deadlock detected (SQLSTATE=40P01)":                 true,
+		"failed to insert registratie: ERROR: duplicate key value violates unique constraint \"registratie_tijdstip_key\" (SQLSTATE=23505)": true,
+		"ERROR: duplicate key value violates unique constraint \"x\" (SQLSTATE=23505)":                                                      false,
+	}
+	for melding, verwacht := range gevallen {
+		if herhaalbaar := isHerhaalbareFout(errors.New(melding)); herhaalbaar != verwacht {
+			t.Errorf("%q: expected %v, got %v", melding, verwacht, herhaalbaar)
+		}
+	}
+	if isHerhaalbareFout(nil) {
+		t.Error("expected nil not to be retryable")
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_RetriesAfterSerializationFailure(t *testing.T) {
+	// Given: de eerste poging van de afvoer van V3 strandt op een serialisatiefout.
+	// When: de registratie wordt aangeboden.
+	// Then: de eerste transactie wordt teruggedraaid en de tweede poging legt de registratie vast (201).
+	zonderWachttijd(t)
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	verwachtVergrendeling(mock, "A", 2)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectQuery(`INSERT INTO "registratie" .* RETURNING id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(`UPDATE "a_v" SET afvoer = `).
+		WillReturnError(errors.New(serialisatiefout))
+	mock.ExpectRollback()
+
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectCommit()
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3)
+
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_RetriesAfterBotsendRegistratieTijdstip(t *testing.T) {
+	// Given: de insert van de registratie in de eerste poging botst op de unique constraint op registratie.tijdstip.
+	// When: de registratie wordt aangeboden.
+	// Then: er volgt geen 500 maar een tweede poging, die de registratie vastlegt (201).
+	zonderWachttijd(t)
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+	tijdstip := time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	verwachtVergrendeling(mock, "A", 2)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectQuery(`INSERT INTO "registratie" .* RETURNING id`).
+		WillReturnError(errors.New(`ERROR: duplicate key value violates unique constraint "registratie_tijdstip_key" (SQLSTATE=23505)`))
+	mock.ExpectRollback()
+
+	verwachtAfvoerVanV3(mock, tijdstip)
+	mock.ExpectCommit()
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3)
+
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestRegistreerMetNieuweAanpak_GivesUpAfterMaxPogingen(t *testing.T) {
+	// Given: elke poging strandt op een serialisatiefout bij het vergrendelen.
+	// When: de registratie wordt aangeboden.
+	// Then: na maxRegistratiePogingen pogingen volgt een 500 met de serialisatiefout.
+	zonderWachttijd(t)
+	mock := nieuweMockDB(t)
+
+	for poging := 0; poging < maxRegistratiePogingen; poging++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+			WillReturnError(errors.New(serialisatiefout))
+		mock.ExpectRollback()
+	}
+
+	recorder := registreerVoorTest(t, "", afvoerVanV3)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
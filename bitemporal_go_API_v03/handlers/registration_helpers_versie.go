@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== OPTIMISTISCHE CONCURRENCY ===========================
+
+Elke entiteit heeft een versie: de laatste registratie die haar raakte (model.Entiteitversie, tabel entiteitversie).
+GET /full/as/:id (en /full/bs/:id) geeft die als ETag terug, bijv. "A/1/17".
+
+Een registratie kan vooraf stellen op welke versie ze is gebaseerd (voorwaarden):
+- per request: If-Match header met één of meer ETags, bijv. If-Match: "A/1/17", "B/3/12"
+- per wijziging: "laatste_registratie_id": 17, voor de entiteit van de wijziging (bij een gegevenselement/relatie de bovenliggende)
+
+Is de entiteit intussen door een andere registratie geraakt, dan wordt de registratie geweigerd met een 409.
+Een entiteit die nog nooit is geraakt heeft versie 0.
+
+Aan het eind van de registratie (vlak voor de commit) worden de voorwaarden gecontroleerd, met een row lock op de versies,
+en krijgen alle geraakte entiteiten (zie registration_helpers_kardinaliteit.go) deze registratie als versie.
+Twee gelijktijdige registraties op dezelfde versie: de tweede wacht op de row lock en krijgt daarna een 409.
+*/
+
+// entiteitSleutel identificeert een entiteit.
+type entiteitSleutel struct {
+	Typenaam string
+	ID       int
+}
+
+// versievoorwaarden zijn per entiteit de verwachte laatste registratie.
+type versievoorwaarden map[entiteitSleutel]int64
+
+// voegToe voegt een voorwaarde toe; twee verschillende verwachtingen voor dezelfde entiteit geven een 400.
+func (v versievoorwaarden) voegToe(typenaam string, id int, registratieID int64) error {
+	sleutel := entiteitSleutel{Typenaam: typenaam, ID: id}
+	if eerder, ok := v[sleutel]; ok && eerder != registratieID {
+		return nieuweValidatieFout(http.StatusBadRequest,
+			"tegenstrijdige voorwaarden voor %s %d: laatste registratie %d en %d", typenaam, id, eerder, registratieID)
+	}
+	v[sleutel] = registratieID
+	return nil
+}
+
+func (v versievoorwaarden) gesorteerd() []entiteitSleutel {
+	sleutels := make([]entiteitSleutel, 0, len(v))
+	for sleutel := range v {
+		sleutels = append(sleutels, sleutel)
+	}
+	sorteerEntiteitSleutels(sleutels)
+	return sleutels
+}
+
+// sorteerEntiteitSleutels sorteert op typenaam en id: de vaste volgorde voor locks.
+func sorteerEntiteitSleutels(sleutels []entiteitSleutel) {
+	sort.Slice(sleutels, func(i, j int) bool {
+		if sleutels[i].Typenaam != sleutels[j].Typenaam {
+			return sleutels[i].Typenaam < sleutels[j].Typenaam
+		}
+		return sleutels[i].ID < sleutels[j].ID
+	})
+}
+
+// leesVersievoorwaarden leest de ETags uit de If-Match header (gescheiden door komma's). "*" stelt geen voorwaarde.
+func leesVersievoorwaarden(c *gin.Context) (versievoorwaarden, error) {
+	voorwaarden := versievoorwaarden{}
+	for _, etag := range strings.Split(c.GetHeader("If-Match"), ",") {
+		etag = strings.TrimSpace(etag)
+		if etag == "" || etag == "*" {
+			continue
+		}
+		versie, err := model.ParseETag(etag)
+		if err != nil {
+			return nil, nieuweValidatieFout(http.StatusBadRequest, "ongeldige If-Match: %v", err)
+		}
+		meta, ok := model.MetaRegistry.GetTypeMeta(versie.Typenaam)
+		if !ok || meta.Metatype != model.MetatypeEntiteit {
+			return nil, nieuweValidatieFout(http.StatusBadRequest, "ongeldige If-Match: %s is geen entiteittype", versie.Typenaam)
+		}
+		if err := voorwaarden.voegToe(versie.Typenaam, versie.EntiteitID, versie.RegistratieID); err != nil {
+			return nil, err
+		}
+	}
+	return voorwaarden, nil
+}
+
+// entiteitVanRepresentatie geeft de entiteit waar een representatie bij hoort: de entiteit zelf,
+// of bij een gegevenselement/relatie de bovenliggende entiteit.
+func entiteitVanRepresentatie(representatienaam string, representatie model.Representatie) (entiteitSleutel, error) {
+	meta, ok := model.MetaRegistry.GetTypeMeta(representatienaam)
+	if !ok {
+		return entiteitSleutel{}, fmt.Errorf("HANDLER: geen metadata voor %s", representatienaam)
+	}
+
+	if meta.Metatype == model.MetatypeEntiteit {
+		id, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.IDKolom)
+		if err != nil {
+			return entiteitSleutel{}, fmt.Errorf("HANDLER: kon id van %s niet bepalen: %v", meta.Typenaam, err)
+		}
+		if id == 0 {
+			return entiteitSleutel{}, nieuweValidatieFout(http.StatusBadRequest,
+				"laatste_registratie_id kan alleen bij een bestaande %s (met id)", meta.Typenaam)
+		}
+		return entiteitSleutel{Typenaam: meta.Typenaam, ID: id}, nil
+	}
+
+	bovenliggend, ok := model.MetaRegistry.GetBovenliggendeRelatieMeta(meta.Typenaam)
+	if !ok {
+		return entiteitSleutel{}, fmt.Errorf("HANDLER: geen bovenliggende entiteit gevonden voor %s", meta.Typenaam)
+	}
+	id, err := haalIntWaardeVoorKolomUitRepresentatie(representatie, meta.EntiteitIDKolom)
+	if err != nil {
+		return entiteitSleutel{}, fmt.Errorf("HANDLER: kon %s niet bepalen voor %s: %v", meta.EntiteitIDKolom, meta.Typenaam, err)
+	}
+	if id == 0 {
+		return entiteitSleutel{}, nieuweValidatieFout(http.StatusBadRequest, "%s ontbreekt voor %s", meta.EntiteitIDKolom, meta.Typenaam)
+	}
+	return entiteitSleutel{Typenaam: bovenliggend.ParentType.Typenaam, ID: id}, nil
+}
+
+// haalEntiteitversieUitDB geeft de laatste registratie die de entiteit raakte (0 als die er niet is).
+// Met voorUpdate wordt de rij gelockt tot het eind van de transactie.
+func haalEntiteitversieUitDB(c *gin.Context, db bun.IDB, sleutel entiteitSleutel, voorUpdate bool) (int64, error) {
+	var versie model.Entiteitversie
+	query := db.NewSelect().
+		Model(&versie).
+		Where("typenaam = ?", sleutel.Typenaam).
+		Where("entiteit_id = ?", sleutel.ID)
+	if voorUpdate {
+		query = query.For("UPDATE")
+	}
+	err := query.Scan(c.Request.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("HANDLER: kon versie van %s %d niet ophalen: %v", sleutel.Typenaam, sleutel.ID, err)
+	}
+	return versie.RegistratieID, nil
+}
+
+// werkEntiteitversiesBij controleert de voorwaarden en maakt de registratie de versie van alle geraakte entiteiten.
+func werkEntiteitversiesBij(c *gin.Context, tx bun.Tx, registratieID int64, geraakt geraakteEntiteiten, voorwaarden versievoorwaarden) error {
+	for _, sleutel := range voorwaarden.gesorteerd() {
+		huidig, err := haalEntiteitversieUitDB(c, tx, sleutel, true)
+		if err != nil {
+			return err
+		}
+		if huidig != voorwaarden[sleutel] {
+			return versieConflict(sleutel, voorwaarden[sleutel], huidig)
+		}
+	}
+
+	for _, meta := range entiteittypen() {
+		for _, id := range geraakt.gesorteerd(meta.Typenaam) {
+			sleutel := entiteitSleutel{Typenaam: meta.Typenaam, ID: id}
+			query := tx.NewInsert().
+				Model(&model.Entiteitversie{Typenaam: sleutel.Typenaam, EntiteitID: sleutel.ID, RegistratieID: registratieID}).
+				On("CONFLICT (typenaam, entiteit_id) DO UPDATE").
+				Set("registratie_id = EXCLUDED.registratie_id")
+			verwacht, metVoorwaarde := voorwaarden[sleutel]
+			if metVoorwaarde {
+				// een gelijktijdige registratie kan de versie (zonder rij om te locken) net hebben aangemaakt
+				query = query.Where("entiteitversie.registratie_id = ?", verwacht)
+			}
+			result, err := query.Exec(c.Request.Context())
+			if err != nil {
+				return fmt.Errorf("HANDLER: kon versie van %s %d niet bijwerken: %v", sleutel.Typenaam, sleutel.ID, err)
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("HANDLER: kon versie van %s %d niet bijwerken: %v", sleutel.Typenaam, sleutel.ID, err)
+			}
+			if rows == 0 && metVoorwaarde {
+				huidig, err := haalEntiteitversieUitDB(c, tx, sleutel, false)
+				if err != nil {
+					return err
+				}
+				return versieConflict(sleutel, verwacht, huidig)
+			}
+		}
+	}
+
+	return nil
+}
+
+// zetETag zet de ETag header met de huidige versie van de entiteit.
+func zetETag(c *gin.Context, typenaam string, id any) error {
+	entiteitID, ok := id.(int)
+	if !ok {
+		return fmt.Errorf("HANDLER: %s heeft geen numerieke id (%T)", typenaam, id)
+	}
+	registratieID, err := haalEntiteitversieUitDB(c, DB, entiteitSleutel{Typenaam: typenaam, ID: entiteitID}, false)
+	if err != nil {
+		return err
+	}
+	c.Header("ETag", model.Entiteitversie{Typenaam: typenaam, EntiteitID: entiteitID, RegistratieID: registratieID}.ETag())
+	return nil
+}
+
+func versieConflict(sleutel entiteitSleutel, verwacht int64, huidig int64) error {
+	return nieuweValidatieFout(http.StatusConflict,
+		"%s %d is gewijzigd: de laatste registratie is %d, verwacht %d (ETag %s)", sleutel.Typenaam, sleutel.ID, huidig, verwacht,
+		model.Entiteitversie{Typenaam: sleutel.Typenaam, EntiteitID: sleutel.ID, RegistratieID: huidig}.ETag())
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+)
+
+// versieKolommen zijn de kolommen van de tabel entiteitversie.
+var versieKolommen = []string{"typenaam", "entiteit_id", "registratie_id"}
+
+func TestLeesVersievoorwaarden(t *testing.T) {
+	lees := func(ifMatch string) (versievoorwaarden, error) {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/registratie/", nil)
+		ctx.Request.Header.Set("If-Match", ifMatch)
+		return leesVersievoorwaarden(ctx)
+	}
+
+	t.Run("several ETags", func(t *testing.T) {
+		// Given: If-Match met twee ETags en een "*".
+		// When: de voorwaarden worden gelezen.
+		// Then: A1 verwacht registratie 17 en B3 registratie 0.
+		voorwaarden, err := lees(`"A/1/17", *, W/"B/3/0"`)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if len(voorwaarden) != 2 || voorwaarden[entiteitSleutel{"A", 1}] != 17 || voorwaarden[entiteitSleutel{"B", 3}] != 0 {
+			t.Fatalf("unexpected voorwaarden %v", voorwaarden)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		// Given: een ongeldig ETag, een ETag van een gegevenselement en tegenstrijdige ETags.
+		// When: de voorwaarden worden gelezen.
+		// Then: volgt telkens een 400.
+		for _, ifMatch := range []string{`A/1/17`, `"A_U/1/17"`, `"A/1/17", "A/1/18"`} {
+			_, err := lees(ifMatch)
+			if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusBadRequest {
+				t.Fatalf("expected 400 for %s, got %v", ifMatch, err)
+			}
+		}
+	})
+}
+
+func TestEntiteitVanRepresentatie(t *testing.T) {
+	// Given: een V van A2 en A5 zelf.
+	// When: de entiteit van de representatie wordt bepaald.
+	// Then: dat is telkens de A.
+	sleutel, err := entiteitVanRepresentatie("A_V", &model.A_V{A_ID: 2, Rel_ID: 3})
+	if err != nil || sleutel != (entiteitSleutel{"A", 2}) {
+		t.Fatalf("expected A 2, got %v (%v)", sleutel, err)
+	}
+	sleutel, err = entiteitVanRepresentatie("A", &model.Full_A{ID: 5})
+	if err != nil || sleutel != (entiteitSleutel{"A", 5}) {
+		t.Fatalf("expected A 5, got %v (%v)", sleutel, err)
+	}
+}
+
+func TestWerkEntiteitversiesBij_UpdatesEveryTouchedEntity(t *testing.T) {
+	// Given: registratie 9 raakt A2 en B3, zonder voorwaarden.
+	// When: de versies worden bijgewerkt.
+	// Then: beide krijgen versie 9.
+	ctx, tx, mock := nieuweMockTx(t)
+	geraakt := geraakteEntiteiten{}
+	geraakt.voegToe("A", []int{2})
+	geraakt.voegToe("B", []int{3})
+
+	mock.ExpectExec(`INSERT INTO "entiteitversie" .*VALUES \('A', 2, 9\) ON CONFLICT \(typenaam, entiteit_id\) DO UPDATE SET registratie_id = EXCLUDED.registratie_id$`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO "entiteitversie" .*VALUES \('B', 3, 9\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := werkEntiteitversiesBij(ctx, tx, 9, geraakt, versievoorwaarden{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestWerkEntiteitversiesBij_RejectsEntityChangedInTheMeantime(t *testing.T) {
+	// Given: de registratie is gebaseerd op A2 met versie 7, maar registratie 8 heeft A2 intussen geraakt.
+	// When: de versies worden bijgewerkt.
+	// Then: volgt een 409 met de huidige versie, en er wordt niets bijgewerkt.
+	ctx, tx, mock := nieuweMockTx(t)
+	geraakt := geraakteEntiteiten{}
+	geraakt.voegToe("A", []int{2})
+
+	mock.ExpectQuery(`SELECT .*FROM "entiteitversie" WHERE \(typenaam = 'A'\) AND \(entiteit_id = 2\) FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(versieKolommen).AddRow("A", 2, 8))
+
+	err := werkEntiteitversiesBij(ctx, tx, 9, geraakt, versievoorwaarden{entiteitSleutel{"A", 2}: 7})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `de laatste registratie is 8, verwacht 7 (ETag "A/2/8")`) {
+		t.Fatalf("expected the current version in the message, got: %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestWerkEntiteitversiesBij_ConcurrentFirstVersionIsConflict(t *testing.T) {
+	// Given: A2 heeft nog geen versie (0), maar een gelijktijdige registratie 8 maakt die net aan.
+	// When: de versie van A2 op 9 wordt gezet met voorwaarde 0.
+	// Then: de upsert raakt geen rij en er volgt een 409.
+	ctx, tx, mock := nieuweMockTx(t)
+	geraakt := geraakteEntiteiten{}
+	geraakt.voegToe("A", []int{2})
+
+	mock.ExpectQuery(`SELECT .*FROM "entiteitversie" WHERE \(typenaam = 'A'\) AND \(entiteit_id = 2\) FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(versieKolommen))
+	mock.ExpectExec(`INSERT INTO "entiteitversie" .*DO UPDATE SET registratie_id = EXCLUDED.registratie_id WHERE \(entiteitversie.registratie_id = 0\)`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT .*FROM "entiteitversie" WHERE \(typenaam = 'A'\) AND \(entiteit_id = 2\)$`).
+		WillReturnRows(sqlmock.NewRows(versieKolommen).AddRow("A", 2, 8))
+
+	err := werkEntiteitversiesBij(ctx, tx, 9, geraakt, versievoorwaarden{entiteitSleutel{"A", 2}: 0})
+	if status := httpStatusVoorFout(err, http.StatusInternalServerError); err == nil || status != http.StatusConflict {
+		t.Fatalf("expected 409, got %v", err)
+	}
+
+	rondMockTxAf(t, tx, mock)
+}
+
+func TestRegistreerMetNieuweAanpak_RejectsStaleLaatsteRegistratieID(t *testing.T) {
+	// Given: A2 is het laatst geraakt door registratie 8.
+	// When: de afvoer van V3 wordt aangeboden met laatste_registratie_id 7.
+	// Then: volgt een 409 en de registratie wordt teruggedraaid.
+	mock := nieuweMockDB(t)
+	metVoorbeeldKardinaliteit(t)
+
+	mock.ExpectBegin()
+	verwachtVergrendeling(mock, "A", 2)
+	mock.ExpectQuery(`SELECT MAX\(tijdstip\) FROM "registratie"`).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectQuery(`INSERT INTO "registratie" .* RETURNING id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(`UPDATE "a_v" SET afvoer = .*WHERE \(a_id = 2\) AND \(rel_id = 3\)`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "wijziging"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+	verwachtGeraakteEntiteitA2(mock)
+	mock.ExpectQuery(`SELECT "id" FROM "a" WHERE \(id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`FROM "a_u" WHERE \(a_id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 1))
+	mock.ExpectQuery(`FROM "a_v" WHERE \(a_id IN \(2\)\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"entiteit_id", "aantal"}).AddRow(2, 2))
+	mock.ExpectQuery(`SELECT .*FROM "entiteitversie" WHERE \(typenaam = 'A'\) AND \(entiteit_id = 2\) FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(versieKolommen).AddRow("A", 2, 8))
+	mock.ExpectRollback()
+
+	recorder := registreerVoorTest(t, "",
+		`{"registratie": {"registratietype": "registratie"}, "wijzigingen": [{"afvoer": {"v": {"a_id": 2, "rel_id": 3}}, "laatste_registratie_id": 7}]}`)
+
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
+
+func TestMakeGetFullEntityHandler_SetsETag(t *testing.T) {
+	// Given: A2 is het laatst geraakt door registratie 9.
+	// When: /full/as/2 wordt opgevraagd (zonder peilmoment).
+	// Then: de ETag is "A/2/9".
+	mock := nieuweMockDB(t)
+	opvoer := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT .*FROM "a" WHERE \("a"\.id = '2'\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer"}).AddRow(2, opvoer))
+	mock.ExpectQuery(`FROM "a_u"`).WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+	mock.ExpectQuery(`FROM "a_v"`).WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+	mock.ExpectQuery(`FROM "rel_a_b"`).WillReturnRows(sqlmock.NewRows([]string{"id", "a_id"}))
+	mock.ExpectQuery(`SELECT .*FROM "entiteitversie" WHERE \(typenaam = 'A'\) AND \(entiteit_id = 2\)$`).
+		WillReturnRows(sqlmock.NewRows(versieKolommen).AddRow("A", 2, 9))
+
+	router := gin.New()
+	router.GET("/full/as/:id", MakeGetFullEntityHandler[model.Full_A]("A", []string{"Us", "Vs", "RelABs"}))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/full/as/2", nil))
+
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != `"A/2/9"` {
+		t.Fatalf("expected 200 with ETag \"A/2/9\", got %d %q", recorder.Code, recorder.Header().Get("ETag"))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
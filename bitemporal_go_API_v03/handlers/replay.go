@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== REPLAY ===========================
+
+De kolommen opvoer/afvoer (en aanvang/einde) zijn afgeleide gegevens; wijziging + registratie zijn de bron van waarheid.
+SpeelLogboekAf leidt de kolommen opnieuw af uit het logboek (in registratievolgorde, met alle ongedaanmakingen,
+zie model.LeidGeldigheidAf) en herstelt de rijen die afwijken. Het rapport noemt elke herstelde kolom.
+
+- zonder typenaam: alle typen in de MetaRegistry
+- met typenaam (een entiteit) en id: die entiteit en haar onderliggende gegevenselementen/relaties
+
+Niet aangeraakt worden:
+- rijen zonder wijziging records (bijv. via de plain of full POST endpoints ingevoerd): daar valt niets af te leiden
+- aanvang/einde van rijen zonder materieel wijziging: die komen uit de opvoer zelf
+
+Bij gegevenselementen met een PFK is de sleutel (entiteit id, relatief id), zowel in de tabel als in het logboek.
+
+Beschikbaar als POST /admin/replay/:password (?type=A&id=2, met het admin wachtwoord van droptables)
+en als subcommando: go run . replay [type id]
+Een replay herstelt de rijen één voor één; de enkelvoudig constraints zijn daarom deferred (zie dbsetup/enkelvoudig_constraints.go).
+*/
+
+// eindeDerTijden is het peiltijdstip voor een replay: alles wat in het logboek staat telt mee.
+var eindeDerTijden = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// ReplayVerschil is een afgeleide kolom die afweek van het logboek en is hersteld.
+type ReplayVerschil struct {
+	Representatienaam string     `json:"representatienaam"`
+	EntiteitID        string     `json:"entiteit_id,omitempty"` // alleen bij een PFK
+	RepresentatieID   string     `json:"representatie_id"`
+	Kolom             string     `json:"kolom"`
+	Was               *time.Time `json:"was"`
+	Wordt             *time.Time `json:"wordt"`
+}
+
+// ReplayRapport is het resultaat van een replay.
+type ReplayRapport struct {
+	Typen         []string         `json:"typen"`
+	AantalRijen   int              `json:"aantal_rijen"`
+	Hersteld      []ReplayVerschil `json:"hersteld"`
+	ZonderLogboek int              `json:"zonder_logboek"`
+}
+
+// replayRij zijn de afgeleide kolommen van een rij.
+type replayRij struct {
+	ID         string     `bun:"id"`
+	EntiteitID string     `bun:"entiteit_id"` // alleen bij een PFK
+	Opvoer     *time.Time `bun:"opvoer"`
+	Afvoer     *time.Time `bun:"afvoer"`
+	Aanvang    *time.Time `bun:"aanvang"`
+	Einde      *time.Time `bun:"einde"`
+}
+
+// SpeelLogboekAf herbouwt de afgeleide kolommen uit het logboek. Roep het aan binnen een transactie.
+// typenaam en entiteitID zijn beide leeg (alles) of beide gevuld (één entiteit).
+func SpeelLogboekAf(ctx context.Context, db bun.IDB, typenaam string, entiteitID string) (ReplayRapport, error) {
+	rapport := ReplayRapport{Typen: []string{}, Hersteld: []ReplayVerschil{}}
+
+	typen, err := typenVoorReplay(typenaam, entiteitID)
+	if err != nil {
+		return ReplayRapport{}, err
+	}
+	for _, meta := range typen {
+		rapport.Typen = append(rapport.Typen, meta.Typenaam)
+	}
+
+	var wijzigingen []model.Wijziging
+	err = db.NewSelect().
+		Model(&wijzigingen).
+		Where("representatienaam IN (?)", bun.In(rapport.Typen)).
+		Order("tijdstip", "id").
+		Scan(ctx)
+	if err != nil {
+		return ReplayRapport{}, fmt.Errorf("HANDLER: kon wijzigingen voor de replay niet ophalen: %v", err)
+	}
+
+	var ongedaanmakingen []model.Registratie
+	err = db.NewSelect().
+		Model(&ongedaanmakingen).
+		Where("registratietype = ?", model.RegistratietypeOngedaanmaking).
+		Scan(ctx)
+	if err != nil {
+		return ReplayRapport{}, fmt.Errorf("HANDLER: kon ongedaanmakingen voor de replay niet ophalen: %v", err)
+	}
+
+	afgeleid, err := model.LeidGeldigheidAf(wijzigingen, ongedaanmakingen, eindeDerTijden)
+	if err != nil {
+		return ReplayRapport{}, fmt.Errorf("HANDLER: kon de afgeleide kolommen niet bepalen: %v", err)
+	}
+
+	for i, meta := range typen {
+		kolom := ""
+		if entiteitID != "" {
+			kolom = meta.IDKolom
+			if i > 0 {
+				kolom = meta.EntiteitIDKolom
+			}
+		}
+		if err := herstelAfgeleideKolommen(ctx, db, meta, kolom, entiteitID, afgeleid, &rapport); err != nil {
+			return ReplayRapport{}, err
+		}
+	}
+
+	return rapport, nil
+}
+
+// typenVoorReplay bepaalt welke typen worden afgespeeld: alle typen (op naam gesorteerd) of een entiteit met haar onderliggende typen.
+func typenVoorReplay(typenaam string, entiteitID string) ([]model.TypeMeta, error) {
+	if typenaam == "" && entiteitID == "" {
+		namen := make([]string, 0, len(model.MetaRegistry))
+		for naam := range model.MetaRegistry {
+			namen = append(namen, naam)
+		}
+		sort.Strings(namen)
+
+		typen := make([]model.TypeMeta, 0, len(namen))
+		for _, naam := range namen {
+			typen = append(typen, model.MetaRegistry.MustTypeMeta(naam))
+		}
+		return typen, nil
+	}
+
+	if typenaam == "" || entiteitID == "" {
+		return nil, nieuweValidatieFout(http.StatusBadRequest, "geef voor een replay van één entiteit zowel het type als het id op")
+	}
+	meta, ok := model.MetaRegistry.GetTypeMeta(typenaam)
+	if !ok {
+		return nil, nieuweValidatieFout(http.StatusNotFound, "onbekend type '%s'", typenaam)
+	}
+	if meta.Metatype != model.MetatypeEntiteit {
+		return nil, nieuweValidatieFout(http.StatusBadRequest, "%s is een %s; een replay per entiteit kan alleen voor entiteiten", meta.Typenaam, meta.Metatype)
+	}
+
+	typen := []model.TypeMeta{meta}
+	for _, onderliggend := range meta.OnderliggendeGegevenselementen {
+		doelMeta, err := metaVoorRolnaam(meta, onderliggend.Rolnaam)
+		if err != nil {
+			return nil, err
+		}
+		typen = append(typen, doelMeta)
+	}
+	return typen, nil
+}
+
+// herstelAfgeleideKolommen vergelijkt de rijen van een type met het logboek en herstelt de afwijkende rijen.
+// Met een filterkolom worden alleen de rijen met filterkolom = filterwaarde bekeken.
+func herstelAfgeleideKolommen(ctx context.Context, db bun.IDB, meta model.TypeMeta, filterkolom string, filterwaarde string,
+	afgeleid map[model.RepresentatieSleutel]model.AfgeleideGeldigheid, rapport *ReplayRapport) error {
+	var rijen []replayRij
+	query := db.NewSelect().
+		Table(meta.Tabelnaam).
+		ColumnExpr("? AS id", bun.Ident(meta.IDKolom))
+	if meta.HeeftPFK {
+		query = query.ColumnExpr("? AS entiteit_id", bun.Ident(meta.EntiteitIDKolom))
+	}
+	query = query.
+		Column("opvoer", "afvoer", "aanvang", "einde").
+		Order(meta.IDKolom)
+	if filterkolom != "" {
+		query = query.Where("? = ?", bun.Ident(filterkolom), filterwaarde)
+	}
+	if err := query.Scan(ctx, &rijen); err != nil {
+		return fmt.Errorf("HANDLER: kon %s niet ophalen voor de replay: %v", meta.Typenaam, err)
+	}
+	rapport.AantalRijen += len(rijen)
+
+	for _, rij := range rijen {
+		sleutel := model.RepresentatieSleutel{Representatienaam: meta.Typenaam, EntiteitID: rij.EntiteitID, RepresentatieID: rij.ID}
+		geldigheid, ok := afgeleid[sleutel]
+		if !ok {
+			rapport.ZonderLogboek++
+			continue
+		}
+
+		verschillen := vergelijkMetLogboek(meta, rij, geldigheid)
+		if len(verschillen) == 0 {
+			continue
+		}
+
+		update := db.NewUpdate().
+			Table(meta.Tabelnaam).
+			Where("? = ?", bun.Ident(meta.IDKolom), rij.ID)
+		switch {
+		case meta.HeeftPFK:
+			update = update.Where("? = ?", bun.Ident(meta.EntiteitIDKolom), rij.EntiteitID)
+		case filterkolom != "":
+			update = update.Where("? = ?", bun.Ident(filterkolom), filterwaarde)
+		}
+		for _, verschil := range verschillen {
+			update = update.Set("? = ?", bun.Ident(verschil.Kolom), verschil.Wordt)
+		}
+		if _, err := update.Exec(ctx); err != nil {
+			return fmt.Errorf("HANDLER: kon %s %s niet herstellen: %v", meta.Typenaam, rij.ID, err)
+		}
+		rapport.Hersteld = append(rapport.Hersteld, verschillen...)
+	}
+
+	return nil
+}
+
+// vergelijkMetLogboek geeft de afgeleide kolommen van een rij die afwijken van het logboek.
+func vergelijkMetLogboek(meta model.TypeMeta, rij replayRij, geldigheid model.AfgeleideGeldigheid) []ReplayVerschil {
+	type kolomwaarden struct {
+		kolom string
+		was   *time.Time
+		wordt *time.Time
+	}
+	kolommen := []kolomwaarden{
+		{"opvoer", rij.Opvoer, geldigheid.Opvoer},
+		{"afvoer", rij.Afvoer, geldigheid.Afvoer},
+	}
+	if meta.IsMaterieel && geldigheid.MaterieelBekend {
+		kolommen = append(kolommen,
+			kolomwaarden{"aanvang", rij.Aanvang, geldigheid.Aanvang},
+			kolomwaarden{"einde", rij.Einde, geldigheid.Einde})
+	}
+
+	var verschillen []ReplayVerschil
+	for _, k := range kolommen {
+		if model.ZelfdeTijdstip(k.was, k.wordt) {
+			continue
+		}
+		verschillen = append(verschillen, ReplayVerschil{
+			Representatienaam: meta.Typenaam, EntiteitID: rij.EntiteitID, RepresentatieID: rij.ID, Kolom: k.kolom, Was: k.was, Wordt: k.wordt,
+		})
+	}
+	return verschillen
+}
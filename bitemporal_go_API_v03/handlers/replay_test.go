@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSpeelLogboekAf(t *testing.T) {
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+
+	t.Run("restores drifted derived columns of one entity", func(t *testing.T) {
+		// Given: A2 opgevoerd om 01:00 en U1 opgevoerd om 01:00 en afgevoerd om 02:00,
+		//        maar in de tabellen heeft A2 een verkeerde opvoer en U1 geen afvoer.
+		// When: de replay voor A 2 draait.
+		// Then: beide rijen worden hersteld en gerapporteerd; V1 zonder logboek blijft ongemoeid.
+		ctx, tx, mock := nieuweMockTx(t)
+
+		mock.ExpectQuery(`SELECT .*FROM "wijziging" WHERE \(representatienaam IN \('A', 'A_U', 'A_V', 'Rel_A_B'\)\) ORDER BY "tijdstip", "id"`).
+			WillReturnRows(sqlmock.NewRows(wijzigingKolommen).
+				AddRow(1, "opvoer", 1, "A", nil, "2", uur(1)).
+				AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(1)).
+				AddRow(3, "afvoer", 2, "A_U", "2", "1", uur(2)))
+		mock.ExpectQuery(`SELECT .*FROM "registratie" WHERE \(registratietype = 'ongedaanmaking'\)`).
+			WillReturnRows(sqlmock.NewRows(registratieKolommen))
+		mock.ExpectQuery(`SELECT "id" AS id, "opvoer", "afvoer", "aanvang", "einde" FROM "a" WHERE \("id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer", "afvoer", "aanvang", "einde"}).AddRow("2", uur(5), nil, nil, nil))
+		mock.ExpectExec(`UPDATE "a" SET "opvoer" = '2026-01-01 01:00:00\+00:00' WHERE \("id" = '2'\) AND \("id" = '2'\)`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT "rel_id" AS id, "a_id" AS entiteit_id, .* FROM "a_u" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "entiteit_id", "opvoer", "afvoer", "aanvang", "einde"}).AddRow("1", "2", uur(1), nil, nil, nil))
+		mock.ExpectExec(`UPDATE "a_u" SET "afvoer" = '2026-01-01 02:00:00\+00:00' WHERE \("rel_id" = '1'\) AND \("a_id" = '2'\)`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`FROM "a_v" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "entiteit_id", "opvoer", "afvoer", "aanvang", "einde"}).AddRow("1", "2", uur(1), nil, nil, nil))
+		mock.ExpectQuery(`FROM "rel_a_b" WHERE \("a_id" = '2'\)`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "opvoer", "afvoer", "aanvang", "einde"}))
+
+		rapport, err := SpeelLogboekAf(ctx.Request.Context(), tx, "A", "2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rapport.Hersteld) != 2 || rapport.Hersteld[0].Kolom != "opvoer" || rapport.Hersteld[1].Kolom != "afvoer" {
+			t.Fatalf("expected opvoer of A2 and afvoer of U1 restored, got %+v", rapport.Hersteld)
+		}
+		if rapport.AantalRijen != 3 || rapport.ZonderLogboek != 1 {
+			t.Fatalf("expected 3 rows of which 1 without log, got %+v", rapport)
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+
+	t.Run("requires both type and id for a single entity", func(t *testing.T) {
+		// Given: alleen een type.
+		// When: de replay draait.
+		// Then: er volgt een 400 zonder database verkeer.
+		ctx, tx, mock := nieuweMockTx(t)
+
+		_, err := SpeelLogboekAf(ctx.Request.Context(), tx, "A", "")
+		if err == nil || httpStatusVoorFout(err, 0) != 400 {
+			t.Fatalf("expected 400 validation error, got %v", err)
+		}
+
+		rondMockTxAf(t, tx, mock)
+	})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// nieuweMockTx zet een gin testcontext en een bun transactie op een sqlmock database klaar.
+// De aanroeper zet de verwachtingen en sluit af met tx.Rollback of tx.Commit (plus de bijbehorende Expect).
+func nieuweMockTx(t *testing.T) (*gin.Context, bun.Tx, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+	db := bun.NewDB(sqlDB, pgdialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+
+	mock.ExpectBegin()
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+
+	return ctx, tx, mock
+}
+
+// nieuweMockDB vervangt de globale DB (gebruikt door de lees endpoints) tijdens de test door een sqlmock database.
+func nieuweMockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	oudeDB := DB
+	DB = bun.NewDB(sqlDB, pgdialect.New())
+	t.Cleanup(func() {
+		DB = oudeDB
+		_ = sqlDB.Close()
+	})
+
+	return mock
+}
+
+// rondMockTxAf rolt de transactie terug en controleert dat aan alle verwachtingen is voldaan.
+func rondMockTxAf(t *testing.T, tx bun.Tx, mock sqlmock.Sqlmock) {
+	t.Helper()
+	mock.ExpectRollback()
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to rollback tx: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet sql expectations: %v", err)
+	}
+}
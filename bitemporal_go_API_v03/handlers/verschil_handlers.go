@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MarkWestbroek/Bitemporal_2026/bitemporal_go_API_v03/model"
+	"github.com/gin-gonic/gin"
+	"github.com/uptrace/bun"
+)
+
+/*
+===================== VERSCHIL ===========================
+
+GET /verschil/{type}/{id}?van=...&tot=... vergelijkt een entiteit op twee formele peiltijdstippen
+(RFC3339, zie PEILMOMENT) en geeft een gestructureerd verschil terug (model.VergelijkEntiteiten):
+
+	{
+		"typenaam": "A", "id": "2", "van": "...", "tot": "...",
+		"verschil": {
+			"bestond_oud": true, "bestaat_nieuw": true,
+			"velden": [{"veld": "einde", "oud": null, "nieuw": "2026-03-01T00:00:00Z"}],
+			"rollen": {
+				"Us": {"gewijzigd": [{"oud": {...}, "nieuw": {...}, "velden": [...]}]},
+				"Vs": {"toegevoegd": [{...}], "verwijderd": [{...}]}
+			}
+		}
+	}
+
+De toestanden worden net zo opgehaald als bij /full/{type}s/{id}?peiltijdstip=... (afgeleid uit het logboek).
+{type} is de typenaam (A) of de JSON veldnaam (a) van een entiteit.
+*/
+
+// MakeGetVerschilHandler returns a gin.HandlerFunc for GET /verschil/:type/:id
+func MakeGetVerschilHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		meta, err := entiteitMetaUitParameter(c)
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+
+		van, err := leesTijdstipParameter(c, "van")
+		if err == nil && van == nil {
+			err = nieuweValidatieFout(http.StatusBadRequest, "'van' is verplicht")
+		}
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+		tot, err := leesTijdstipParameter(c, "tot")
+		if err == nil && tot == nil {
+			err = nieuweValidatieFout(http.StatusBadRequest, "'tot' is verplicht")
+		}
+		if err != nil {
+			c.JSON(httpStatusVoorFout(err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+			return
+		}
+
+		entiteitID := c.Param("id")
+		oud, err := haalEntiteitOpPeiltijdstipUitDB(c, meta, entiteitID, *van)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		nieuw, err := haalEntiteitOpPeiltijdstipUitDB(c, meta, entiteitID, *tot)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if oud == nil && nieuw == nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": fmt.Sprintf("%s %s bestond op geen van beide peiltijdstippen", meta.Typenaam, entiteitID)})
+			return
+		}
+
+		verschil, err := model.VergelijkEntiteiten(meta, oud, nieuw)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"typenaam": meta.Typenaam,
+			"id":       entiteitID,
+			"van":      van,
+			"tot":      tot,
+			"verschil": verschil,
+		})
+	}
+}
+
+// haalEntiteitOpPeiltijdstipUitDB haalt een entiteit met haar rollen op zoals geldig op het peiltijdstip.
+// Geeft nil als de entiteit toen niet geldig was.
+func haalEntiteitOpPeiltijdstipUitDB(c *gin.Context, meta model.TypeMeta, entiteitID string, peiltijdstip time.Time) (model.Representatie, error) {
+	rolnamen := make([]string, 0, len(meta.OnderliggendeGegevenselementen))
+	for _, onderliggend := range meta.OnderliggendeGegevenselementen {
+		rolnamen = append(rolnamen, onderliggend.Rolnaam)
+	}
+
+	entiteit := meta.Factory()
+	filter, err := nieuwPeilfilter(c, entiteit, rolnamen, peilmoment{Peiltijdstip: &peiltijdstip}, []string{entiteitID})
+	if err != nil {
+		return nil, err
+	}
+
+	err = filter.voegRelatiesToe(DB.NewSelect().Model(entiteit), rolnamen).
+		Where("?TableAlias.? = ?", bun.Ident(meta.IDKolom), entiteitID).
+		Scan(c.Request.Context())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("HANDLER: kon %s %s op %s niet ophalen: %v", meta.Typenaam, entiteitID, peiltijdstip.Format(time.RFC3339Nano), err)
+	}
+	filter.pasToe(entiteit, rolnamen)
+
+	return entiteit, nil
+}
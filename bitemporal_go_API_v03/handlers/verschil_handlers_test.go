@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMakeGetVerschilHandler(t *testing.T) {
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+
+	t.Run("shows a replaced enkelvoudige U as changed", func(t *testing.T) {
+		// Given: A2 en U1 opgevoerd om 09:00; om 11:00 is U1 vervangen door U2.
+		// When: het verschil tussen 10:00 en 12:00 wordt opgevraagd.
+		// Then: Us heeft één gewijzigde waarde (U1 -> U2) en de entiteit zelf is gelijk.
+		mock := nieuweMockDB(t)
+
+		verwachtToestand := func(wijzigingen *sqlmock.Rows, relID int, aaa string) {
+			mock.ExpectQuery(`SELECT .*FROM "wijziging"`).WillReturnRows(wijzigingen)
+			mock.ExpectQuery(`SELECT .*FROM "registratie"`).WillReturnRows(sqlmock.NewRows(registratieKolommen))
+			mock.ExpectQuery(`SELECT .*FROM "a" WHERE .*AND \("a"\."id" = '2'\)`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+			mock.ExpectQuery(`SELECT .*FROM "a_u"`).
+				WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id", "aaa"}).AddRow(2, relID, aaa))
+			mock.ExpectQuery(`SELECT .*FROM "a_v"`).WillReturnRows(sqlmock.NewRows([]string{"a_id", "rel_id"}))
+			mock.ExpectQuery(`SELECT .*FROM "rel_a_b"`).WillReturnRows(sqlmock.NewRows([]string{"id", "a_id"}))
+		}
+		verwachtToestand(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(1, "opvoer", 1, "A", nil, "2", uur(9)).
+			AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(9)), 1, "oud")
+		verwachtToestand(sqlmock.NewRows(wijzigingKolommen).
+			AddRow(1, "opvoer", 1, "A", nil, "2", uur(9)).
+			AddRow(2, "opvoer", 1, "A_U", "2", "1", uur(9)).
+			AddRow(3, "afvoer", 2, "A_U", "2", "1", uur(11)).
+			AddRow(4, "opvoer", 2, "A_U", "2", "2", uur(11)), 2, "nieuw")
+
+		router := gin.New()
+		router.GET("/verschil/:type/:id", MakeGetVerschilHandler())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet,
+			"/verschil/A/2?van=2026-01-01T10:00:00Z&tot=2026-01-01T12:00:00Z", nil))
+
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet sql expectations: %v", err)
+		}
+
+		var antwoord struct {
+			Verschil struct {
+				Velden []any `json:"velden"`
+				Rollen map[string]struct {
+					Gewijzigd  []any `json:"gewijzigd"`
+					Toegevoegd []any `json:"toegevoegd"`
+				} `json:"rollen"`
+			} `json:"verschil"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &antwoord); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		us := antwoord.Verschil.Rollen["Us"]
+		if len(antwoord.Verschil.Velden) != 0 || len(antwoord.Verschil.Rollen) != 1 || len(us.Gewijzigd) != 1 || len(us.Toegevoegd) != 0 {
+			t.Fatalf("expected only a changed U, got %s", recorder.Body.String())
+		}
+	})
+
+	t.Run("requires van and tot", func(t *testing.T) {
+		// Given: alleen 'van'.
+		// When: het verschil wordt opgevraagd.
+		// Then: er volgt een 400 zonder database verkeer.
+		mock := nieuweMockDB(t)
+
+		router := gin.New()
+		router.GET("/verschil/:type/:id", MakeGetVerschilHandler())
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/verschil/a/2?van=2026-01-01T10:00:00Z", nil))
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("unmet sql expectations: %v", err)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -41,6 +43,28 @@ func main() {
 	fmt.Println("Succesfully connected to the database.")
 	defer db.Close()
 
+	// Subcommando: go run . replay [type id]
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := runReplay(db, os.Args[2:]); err != nil {
+			fmt.Println("Replay failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Subcommando: go run . controleer (exit code 2 bij schendingen)
+	if len(os.Args) > 1 && os.Args[1] == "controleer" {
+		consistent, err := runControleer(db)
+		if err != nil {
+			fmt.Println("Consistentiecontrole failed:", err)
+			os.Exit(1)
+		}
+		if !consistent {
+			os.Exit(2)
+		}
+		return
+	}
+
 	// Create the "tasks" table in the database if it doesn't exist
 	err = dbsetup.CreateTables(db)
 	if err != nil {
@@ -75,6 +99,56 @@ func main() {
 
 }
 
+// runReplay herbouwt de afgeleide kolommen uit het logboek en print het rapport als JSON.
+func runReplay(db *bun.DB, args []string) error {
+	var typenaam, entiteitID string
+	switch len(args) {
+	case 0:
+	case 2:
+		typenaam, entiteitID = args[0], args[1]
+	default:
+		return fmt.Errorf("usage: replay [type id]")
+	}
+
+	var rapport handlers.ReplayRapport
+	err := db.RunInTx(context.Background(), nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rapport, err = handlers.SpeelLogboekAf(ctx, tx, typenaam, entiteitID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(rapport, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+// runControleer voert de consistentiecontrole uit en print het rapport als JSON.
+func runControleer(db *bun.DB) (bool, error) {
+	var rapport handlers.ConsistentieRapport
+	opties := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	err := db.RunInTx(context.Background(), opties, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		rapport, err = handlers.ControleerRegister(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	output, err := json.MarshalIndent(rapport, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	fmt.Println(string(output))
+	return rapport.Consistent, nil
+}
+
 func loadDotEnvIfPresent() {
 	err := godotenv.Load()
 	if err != nil {
@@ -119,6 +193,8 @@ func NewRouter() *gin.Engine {
 	// admin routes
 	router.DELETE("/admin/db/droptables/:password", handlers.DropTables)
 	router.POST("/admin/db/createtables", handlers.CreateTables)
+	router.POST("/admin/replay/:password", handlers.ReplayLogboek)
+	router.GET("/admin/consistentie/:password", handlers.ControleerConsistentie)
 
 	//Add all functional routes
 	routes.AddRoutes(router)
@@ -104,6 +104,70 @@ func TestDropTablesEndpoint_DisabledByDefault(t *testing.T) {
 	}
 }
 
+func TestReplayEndpoint_WrongPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_DROP_PASSWORD", "1234")
+
+	handlers.DB = nil
+	r := NewRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/replay/wrong", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestReplayEndpoint_DBNotInitialized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_DROP_PASSWORD", "1234")
+
+	handlers.DB = nil
+	r := NewRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/replay/1234", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+}
+
+func TestConsistentieEndpoint_WrongPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_DROP_PASSWORD", "1234")
+
+	handlers.DB = nil
+	r := NewRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/consistentie/wrong", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", w.Code)
+	}
+}
+
+func TestConsistentieEndpoint_DBNotInitialized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_DROP_PASSWORD", "1234")
+
+	handlers.DB = nil
+	r := NewRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/consistentie/1234", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", w.Code)
+	}
+}
+
 func TestIsProductionEnvironment_AppEnvProduction(t *testing.T) {
 	t.Setenv("APP_ENV", "production")
 	t.Setenv("GIN_MODE", "debug")
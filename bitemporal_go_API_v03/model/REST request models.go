@@ -24,6 +24,10 @@ type RegistreerRequest struct {
 type WijzigingRequest struct {
 	Opvoer *RepresentatiePlusNaam `json:"opvoer,omitempty"`
 	Afvoer *RepresentatiePlusNaam `json:"afvoer,omitempty"`
+	// Materieel wijzigt alleen aanvang/einde van een bestaande, actieve entiteit of relatie (zonder afvoer/opvoer)
+	Materieel *RepresentatiePlusNaam `json:"materieel,omitempty"`
+	// Optimistische concurrency (optioneel): de laatste registratie die de entiteit van deze wijziging raakte, zie Entiteitversie
+	LaatsteRegistratieID *int64 `json:"laatste_registratie_id,omitempty"`
 }
 
 /*
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+/*
+Afleiding van de geldigheid uit het logboek (wijziging + registratie).
+
+De kolommen opvoer/afvoer (en aanvang/einde) in de tabellen zijn afgeleide velden die de huidige kennis weergeven.
+Om te beantwoorden "wat wisten we op tijdstip T" leiden we ze opnieuw af uit de wijzigingen:
+- alleen registraties met tijdstip <= T tellen mee
+- registraties die op T (netto) ongedaan gemaakt waren tellen niet mee; een ongedaanmaking na T nog wel
+- opvoer en afvoer zetten het betreffende tijdstip; een materieel wijziging zet aanvang/einde
+
+Dit is het equivalent van de HRv4 view "niet_ongedaan_gemaakte_wijziging" (script 42).
+*/
+
+// RepresentatieSleutel identificeert een representatie in het logboek.
+// Bij gegevenselementen met een PFK is het (relatieve) ID alleen uniek binnen de bovenliggende entiteit;
+// dan hoort het ID van die entiteit bij de sleutel, anders is EntiteitID leeg.
+type RepresentatieSleutel struct {
+	Representatienaam string
+	EntiteitID        string
+	RepresentatieID   string
+}
+
+// Sleutel geeft de sleutel van de representatie waar de wijziging over gaat.
+func (wij Wijziging) Sleutel() RepresentatieSleutel {
+	return RepresentatieSleutel{Representatienaam: wij.Representatienaam, EntiteitID: wij.EntiteitID, RepresentatieID: wij.RepresentatieID}
+}
+
+// AfgeleideGeldigheid is de uit het logboek afgeleide formele (en materiële) geldigheid van een representatie.
+type AfgeleideGeldigheid struct {
+	Opvoer *time.Time
+	Afvoer *time.Time
+	// Aanvang/Einde zijn alleen afgeleid als MaterieelBekend; anders gelden de waarden uit de tabel
+	// (die zijn dan sinds de opvoer niet gewijzigd).
+	Aanvang         *time.Time
+	Einde           *time.Time
+	MaterieelBekend bool
+}
+
+// IsGeldigOp bepaalt of de representatie op het peiltijdstip formeel geldig is: opvoer <= t < afvoer.
+func (g AfgeleideGeldigheid) IsGeldigOp(peiltijdstip time.Time) bool {
+	if g.Opvoer == nil || g.Opvoer.After(peiltijdstip) {
+		return false
+	}
+	return g.Afvoer == nil || g.Afvoer.After(peiltijdstip)
+}
+
+// IsMaterieelGeldigOp bepaalt of een periode [aanvang, einde) de peildatum bevat; lege grenzen zijn onbegrensd.
+func IsMaterieelGeldigOp(aanvang *time.Time, einde *time.Time, peildatum time.Time) bool {
+	if aanvang != nil && aanvang.After(peildatum) {
+		return false
+	}
+	return einde == nil || einde.After(peildatum)
+}
+
+// LeidGeldigheidAf leidt per representatie de geldigheid af zoals bekend op het peiltijdstip.
+// wijzigingen mogen ook wijzigingen na het peiltijdstip bevatten (die tellen niet mee, maar zijn nodig
+// om de oorspronkelijke aanvang/einde te bepalen); ongedaanmakingen zijn alle registraties van dat type.
+func LeidGeldigheidAf(wijzigingen []Wijziging, ongedaanmakingen []Registratie, peiltijdstip time.Time) (map[RepresentatieSleutel]AfgeleideGeldigheid, error) {
+	bekendeOngedaanmakingen := make([]Registratie, 0, len(ongedaanmakingen))
+	for _, ongedaanmaking := range ongedaanmakingen {
+		if !ongedaanmaking.Tijdstip.After(peiltijdstip) {
+			bekendeOngedaanmakingen = append(bekendeOngedaanmakingen, ongedaanmaking)
+		}
+	}
+	ongedaanGemaakt, err := BepaalOngedaanGemaakteRegistraties(bekendeOngedaanmakingen)
+	if err != nil {
+		return nil, err
+	}
+
+	gesorteerd := make([]Wijziging, len(wijzigingen))
+	copy(gesorteerd, wijzigingen)
+	sort.SliceStable(gesorteerd, func(i, j int) bool {
+		if !gesorteerd[i].Tijdstip.Equal(gesorteerd[j].Tijdstip) {
+			return gesorteerd[i].Tijdstip.Before(gesorteerd[j].Tijdstip)
+		}
+		return gesorteerd[i].ID < gesorteerd[j].ID
+	})
+
+	afgeleid := map[RepresentatieSleutel]AfgeleideGeldigheid{}
+	for _, wijziging := range gesorteerd {
+		sleutel := wijziging.Sleutel()
+		geldigheid := afgeleid[sleutel]
+
+		telt := !wijziging.Tijdstip.After(peiltijdstip) && !ongedaanGemaakt[wijziging.RegistratieID]
+
+		switch wijziging.Wijzigingstype {
+		case WijzigingstypeOpvoer:
+			if telt {
+				tijdstip := wijziging.Tijdstip
+				geldigheid.Opvoer = &tijdstip
+			}
+		case WijzigingstypeAfvoer:
+			if telt {
+				tijdstip := wijziging.Tijdstip
+				geldigheid.Afvoer = &tijdstip
+			}
+		case WijzigingstypeMaterieel:
+			if telt {
+				geldigheid.Aanvang, geldigheid.Einde = wijziging.Aanvang, wijziging.Einde
+			} else if !geldigheid.MaterieelBekend {
+				// de eerste materiële wijziging weet wat er daarvoor gold (de waarden bij de opvoer)
+				geldigheid.Aanvang, geldigheid.Einde = wijziging.VorigeAanvang, wijziging.VorigeEinde
+			}
+			geldigheid.MaterieelBekend = true
+		}
+
+		afgeleid[sleutel] = geldigheid
+	}
+
+	return afgeleid, nil
+}
@@ -0,0 +1,117 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func wijzigingVoorTest(id int64, wijzigingstype WijzigingstypeEnum, registratieID int64, representatienaam string, representatieID string, tijdstip time.Time) Wijziging {
+	return Wijziging{ID: id, Wijzigingstype: wijzigingstype, RegistratieID: registratieID,
+		Representatienaam: representatienaam, RepresentatieID: representatieID, Tijdstip: tijdstip}
+}
+
+func TestLeidGeldigheidAf(t *testing.T) {
+	uur := func(h int) time.Time { return time.Date(2026, 1, 1, h, 0, 0, 0, time.UTC) }
+	u1 := RepresentatieSleutel{Representatienaam: "A_U", RepresentatieID: "1"}
+	u2 := RepresentatieSleutel{Representatienaam: "A_U", RepresentatieID: "2"}
+
+	// registratie 1 (01:00) voert U1 op; registratie 2 (02:00) voert U1 af en U2 op;
+	// registratie 3 (04:00) maakt registratie 2 ongedaan.
+	wijzigingen := []Wijziging{
+		wijzigingVoorTest(10, WijzigingstypeOpvoer, 1, "A_U", "1", uur(1)),
+		wijzigingVoorTest(20, WijzigingstypeAfvoer, 2, "A_U", "1", uur(2)),
+		wijzigingVoorTest(21, WijzigingstypeOpvoer, 2, "A_U", "2", uur(2)),
+	}
+	o3 := registratieVoorTest(3, RegistratietypeOngedaanmaking, 2)
+	o3.Tijdstip = uur(4)
+	ongedaanmakingen := []Registratie{o3}
+
+	t.Run("before the undo the undone registratie still counts", func(t *testing.T) {
+		// Given: peiltijdstip 03:00, voor de ongedaanmaking.
+		// When: de geldigheid wordt afgeleid.
+		// Then: U1 is afgevoerd en U2 is geldig, zoals we het toen wisten.
+		afgeleid, err := LeidGeldigheidAf(wijzigingen, ongedaanmakingen, uur(3))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if afgeleid[u1].IsGeldigOp(uur(3)) || !afgeleid[u2].IsGeldigOp(uur(3)) {
+			t.Fatalf("expected U1 afgevoerd and U2 geldig, got %+v", afgeleid)
+		}
+	})
+
+	t.Run("after the undo the undone registratie is ignored", func(t *testing.T) {
+		// Given: peiltijdstip 05:00, na de ongedaanmaking.
+		// When: de geldigheid wordt afgeleid.
+		// Then: U1 is (weer) geldig en U2 is nooit opgevoerd.
+		afgeleid, err := LeidGeldigheidAf(wijzigingen, ongedaanmakingen, uur(5))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !afgeleid[u1].IsGeldigOp(uur(5)) || afgeleid[u2].Opvoer != nil {
+			t.Fatalf("expected U1 geldig and U2 never opgevoerd, got %+v", afgeleid)
+		}
+	})
+
+	t.Run("wijzigingen after the peiltijdstip do not count", func(t *testing.T) {
+		// Given: peiltijdstip 01:30.
+		// When: de geldigheid wordt afgeleid.
+		// Then: U1 is opgevoerd zonder afvoer; U2 bestaat nog niet.
+		afgeleid, err := LeidGeldigheidAf(wijzigingen, ongedaanmakingen, uur(1).Add(30*time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if afgeleid[u1].Opvoer == nil || afgeleid[u1].Afvoer != nil || afgeleid[u2].Opvoer != nil {
+			t.Fatalf("expected only U1 opgevoerd, got %+v", afgeleid)
+		}
+	})
+
+	t.Run("materieel wijziging after the peiltijdstip yields the original aanvang/einde", func(t *testing.T) {
+		// Given: A2 opgevoerd om 01:00; om 02:00 is het einde op 1 maart gezet (daarvoor leeg).
+		// When: de geldigheid op 01:30 en op 03:00 wordt afgeleid.
+		// Then: op 01:30 is het einde leeg, op 03:00 is het 1 maart.
+		aanvang := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		einde := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		materieel := wijzigingVoorTest(30, WijzigingstypeMaterieel, 2, "A", "2", uur(2))
+		materieel.Aanvang, materieel.Einde, materieel.VorigeAanvang = &aanvang, &einde, &aanvang
+		logboek := []Wijziging{wijzigingVoorTest(11, WijzigingstypeOpvoer, 1, "A", "2", uur(1)), materieel}
+		a2 := RepresentatieSleutel{Representatienaam: "A", RepresentatieID: "2"}
+
+		eerder, err := LeidGeldigheidAf(logboek, nil, uur(1).Add(30*time.Minute))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !eerder[a2].MaterieelBekend || eerder[a2].Einde != nil || !ZelfdeTijdstip(eerder[a2].Aanvang, &aanvang) {
+			t.Fatalf("expected original aanvang and no einde, got %+v", eerder[a2])
+		}
+
+		later, err := LeidGeldigheidAf(logboek, nil, uur(3))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ZelfdeTijdstip(later[a2].Einde, &einde) {
+			t.Fatalf("expected einde 1 maart, got %+v", later[a2])
+		}
+	})
+
+	t.Run("a relative id under different entities is a different representatie", func(t *testing.T) {
+		// Given: U1 opgevoerd bij A2 en bij A3 om 01:00; om 02:00 is U1 van A3 afgevoerd.
+		// When: de geldigheid op 03:00 wordt afgeleid.
+		// Then: U1 van A2 is nog geldig, U1 van A3 niet.
+		opvoerA2 := wijzigingVoorTest(40, WijzigingstypeOpvoer, 1, "A_U", "1", uur(1))
+		opvoerA2.EntiteitID = "2"
+		opvoerA3 := wijzigingVoorTest(41, WijzigingstypeOpvoer, 1, "A_U", "1", uur(1))
+		opvoerA3.EntiteitID = "3"
+		afvoerA3 := wijzigingVoorTest(42, WijzigingstypeAfvoer, 2, "A_U", "1", uur(2))
+		afvoerA3.EntiteitID = "3"
+
+		afgeleid, err := LeidGeldigheidAf([]Wijziging{opvoerA2, opvoerA3, afvoerA3}, nil, uur(3))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		u1VanA2 := RepresentatieSleutel{Representatienaam: "A_U", EntiteitID: "2", RepresentatieID: "1"}
+		u1VanA3 := RepresentatieSleutel{Representatienaam: "A_U", EntiteitID: "3", RepresentatieID: "1"}
+		if !afgeleid[u1VanA2].IsGeldigOp(uur(3)) || afgeleid[u1VanA3].IsGeldigOp(uur(3)) {
+			t.Fatalf("expected U1 of A2 geldig and U1 of A3 afgevoerd, got %+v", afgeleid)
+		}
+	})
+}
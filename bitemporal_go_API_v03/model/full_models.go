@@ -73,10 +73,10 @@ func (b *Full_B) GeefOnderliggendeGegevenselementen() []OnderliggendeRepresentat
 type Full_A struct {
 	bun.BaseModel `bun:"table:a,alias:a"`
 	ID            int        `json:"id" bun:"id,pk"`
-	Opvoer        *time.Time `json:"opvoer,omitempty"` // afgeleid van registratie tijdstip opvoer
-	Afvoer        *time.Time `json:"afvoer,omitempty"` // afgeleid van registratie tijdstip afvoer
-	//Aanvang *time.Time `json:"aanvang,omitempty"` // afgeleid van A_Aanvang
-	//Einde   *time.Time `json:"einde,omitempty"`   // afgeleid van A_Einde
+	Opvoer        *time.Time `json:"opvoer,omitempty"`  // afgeleid van registratie tijdstip opvoer
+	Afvoer        *time.Time `json:"afvoer,omitempty"`  // afgeleid van registratie tijdstip afvoer
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // materiële tijd: begin van de geldigheid
+	Einde         *time.Time `json:"einde,omitempty"`   // materiële tijd: einde van de geldigheid
 
 	// De U's behorende bij A, 1-1 op enig moment (enkelvoudig: todo tag)
 	Us []A_U `bun:"rel:has-many,join:id=a_id" json:"us,omitempty"`
@@ -98,10 +98,10 @@ type Full_A struct {
 type Full_B struct {
 	bun.BaseModel `bun:"table:b,alias:b"`
 	ID            int        `json:"id" bun:"id,pk"`
-	Opvoer        *time.Time `json:"opvoer,omitempty"` // afgeleid van registratie tijdstip opvoer
-	Afvoer        *time.Time `json:"afvoer,omitempty"` // afgeleid van registratie tijdstip afvoer
-	//Aanvang *time.Time `json:"aanvang,omitempty"` // afgeleid van A_Aanvang
-	//Einde   *time.Time `json:"einde,omitempty"`   // afgeleid van A_Einde
+	Opvoer        *time.Time `json:"opvoer,omitempty"`  // afgeleid van registratie tijdstip opvoer
+	Afvoer        *time.Time `json:"afvoer,omitempty"`  // afgeleid van registratie tijdstip afvoer
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // materiële tijd: begin van de geldigheid
+	Einde         *time.Time `json:"einde,omitempty"`   // materiële tijd: einde van de geldigheid
 
 	// De X's behorende bij B, 1-1 op enig moment (enkelvoudig: todo tag)
 	Xs []B_X `bun:"rel:has-many,join:id=b_id" json:"xs,omitempty"`
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+Materiële tijd (aanvang/einde) geeft aan wanneer iets in de werkelijkheid geldig is,
+los van wanneer het geregistreerd is (opvoer/afvoer, de formele tijd).
+Beide velden zijn optioneel: een lege aanvang is "altijd al", een leeg einde is "nog steeds".
+*/
+
+// ValideerAanvangEinde controleert dat het einde, als beide gevuld zijn, later is dan de aanvang.
+func ValideerAanvangEinde(aanvang *time.Time, einde *time.Time) error {
+	if aanvang == nil || einde == nil {
+		return nil
+	}
+	if !einde.After(*aanvang) {
+		return fmt.Errorf("einde (%s) moet later zijn dan aanvang (%s)",
+			einde.Format(time.RFC3339Nano), aanvang.Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// ZelfdeTijdstip vergelijkt twee optionele tijdstippen; twee lege tijdstippen zijn gelijk.
+func ZelfdeTijdstip(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// PeriodesOverlappen bepaalt of twee materiële periodes [aanvang, einde) elkaar overlappen.
+// Een lege aanvang of een leeg einde is onbegrensd.
+func PeriodesOverlappen(aanvang1 *time.Time, einde1 *time.Time, aanvang2 *time.Time, einde2 *time.Time) bool {
+	eersteBegintVoorEindeTweede := aanvang1 == nil || einde2 == nil || aanvang1.Before(*einde2)
+	tweedeBegintVoorEindeEerste := aanvang2 == nil || einde1 == nil || aanvang2.Before(*einde1)
+	return eersteBegintVoorEindeTweede && tweedeBegintVoorEindeEerste
+}
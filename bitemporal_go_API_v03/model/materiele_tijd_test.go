@@ -0,0 +1,97 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValideerAanvangEinde(t *testing.T) {
+	aanvang := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := aanvang.Add(24 * time.Hour)
+
+	t.Run("accepts open ends", func(t *testing.T) {
+		// Given: aanvang of einde ontbreekt.
+		// When: de materiële tijd wordt gevalideerd.
+		// Then: geen fout.
+		if err := ValideerAanvangEinde(nil, &later); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ValideerAanvangEinde(&aanvang, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("accepts einde after aanvang", func(t *testing.T) {
+		// Given: einde ligt na aanvang.
+		// When: de materiële tijd wordt gevalideerd.
+		// Then: geen fout.
+		if err := ValideerAanvangEinde(&aanvang, &later); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("rejects einde not after aanvang", func(t *testing.T) {
+		// Given: einde is gelijk aan of ligt voor aanvang.
+		// When: de materiële tijd wordt gevalideerd.
+		// Then: een fout.
+		if err := ValideerAanvangEinde(&aanvang, &aanvang); err == nil {
+			t.Fatal("expected error for einde equal to aanvang")
+		}
+		if err := ValideerAanvangEinde(&later, &aanvang); err == nil {
+			t.Fatal("expected error for einde before aanvang")
+		}
+	})
+}
+
+func TestZelfdeTijdstip(t *testing.T) {
+	// Given: lege en gevulde tijdstippen, ook in verschillende tijdzones.
+	// When: ze vergeleken worden.
+	// Then: leeg==leeg, leeg!=gevuld, en gelijke momenten zijn gelijk.
+	utc := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cet := utc.In(time.FixedZone("CET", 3600))
+
+	if !ZelfdeTijdstip(nil, nil) {
+		t.Fatal("expected nil == nil")
+	}
+	if ZelfdeTijdstip(nil, &utc) || ZelfdeTijdstip(&utc, nil) {
+		t.Fatal("expected nil != value")
+	}
+	if !ZelfdeTijdstip(&utc, &cet) {
+		t.Fatal("expected same instant in different zones to be equal")
+	}
+}
+
+func TestPeriodesOverlappen(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mrt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	gevallen := []struct {
+		naam             string
+		aanvang1, einde1 *time.Time
+		aanvang2, einde2 *time.Time
+		verwacht         bool
+	}{
+		{"both unbounded", nil, nil, nil, nil, true},
+		{"adjacent periods do not overlap", &jan, &mrt, &mrt, nil, false},
+		{"future value after ended value", &jan, &mrt, &jun, nil, false},
+		{"open-ended current value overlaps future value", &jan, nil, &jun, nil, true},
+		{"partial overlap", &jan, &jun, &mrt, nil, true},
+		{"unbounded start overlaps", nil, &mrt, &jan, &jun, true},
+		{"unbounded start before", nil, &jan, &mrt, &jun, false},
+	}
+
+	for _, geval := range gevallen {
+		t.Run(geval.naam, func(t *testing.T) {
+			// Given: twee periodes.
+			// When: op overlap wordt gecontroleerd (in beide volgordes).
+			// Then: de uitkomst is de verwachte, onafhankelijk van de volgorde.
+			if got := PeriodesOverlappen(geval.aanvang1, geval.einde1, geval.aanvang2, geval.einde2); got != geval.verwacht {
+				t.Fatalf("expected %t, got %t", geval.verwacht, got)
+			}
+			if got := PeriodesOverlappen(geval.aanvang2, geval.einde2, geval.aanvang1, geval.einde1); got != geval.verwacht {
+				t.Fatalf("expected %t (swapped), got %t", geval.verwacht, got)
+			}
+		})
+	}
+}
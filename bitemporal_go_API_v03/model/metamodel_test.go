@@ -45,3 +45,45 @@ func TestGetBovenliggendeRelatieMeta(t *testing.T) {
 		}
 	})
 }
+
+func TestOnderliggendGegevenselementValideerAantal(t *testing.T) {
+	us := OnderliggendGegevenselement{Rolnaam: "Us", Doeltype: "A_U", Minimum: 1, Maximum: 1}
+	vs := OnderliggendGegevenselement{Rolnaam: "Vs", Doeltype: "A_V", Maximum: 5}
+
+	t.Run("accepts counts within bounds", func(t *testing.T) {
+		// Given: precies één U en vijf V's.
+		// When: de aantallen worden gevalideerd.
+		// Then: geen fout.
+		if err := us.ValideerAantal(1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := vs.ValideerAantal(5); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("rejects counts outside bounds", func(t *testing.T) {
+		// Given: geen U en zes V's.
+		// When: de aantallen worden gevalideerd.
+		// Then: fouten die de rol en de grens noemen.
+		if err := us.ValideerAantal(0); err == nil || err.Error() != "Us heeft 0 actieve A_U (minimaal 1)" {
+			t.Fatalf("expected minimum error, got %v", err)
+		}
+		if err := vs.ValideerAantal(6); err == nil || err.Error() != "Vs heeft 6 actieve A_V (maximaal 5)" {
+			t.Fatalf("expected maximum error, got %v", err)
+		}
+	})
+
+	t.Run("maximum 0 is unbounded", func(t *testing.T) {
+		// Given: een rol zonder kardinaliteit.
+		// When: een groot aantal wordt gevalideerd.
+		// Then: geen fout.
+		rol := OnderliggendGegevenselement{Rolnaam: "RelABs", Doeltype: "Rel_A_B"}
+		if rol.HeeftKardinaliteit() {
+			t.Fatal("expected no cardinality")
+		}
+		if err := rol.ValideerAantal(1000); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -21,10 +21,11 @@ var MetaRegistry = MetaRegistryType{
 		EntiteitIDKolom:           "",
 		SecondaireEntiteitIDKolom: "",
 		// Alleen voor entiteiten: de onderliggende gegevenselementen/relaties
+		// met het afvoerbeleid bij afvoer van de entiteit (cascade, restrict of detach)
 		OnderliggendeGegevenselementen: []OnderliggendGegevenselement{
-			{Rolnaam: "Us", Doeltype: "A_U", Momentvoorkomen: Enkelvoudig},
-			{Rolnaam: "Vs", Doeltype: "A_V", Momentvoorkomen: Meervoudig},
-			{Rolnaam: "RelABs", Doeltype: "Rel_A_B", Momentvoorkomen: Meervoudig},
+			{Rolnaam: "Us", Doeltype: "A_U", Momentvoorkomen: Enkelvoudig, Afvoerbeleid: AfvoerbeleidCascade},
+			{Rolnaam: "Vs", Doeltype: "A_V", Momentvoorkomen: Meervoudig, Afvoerbeleid: AfvoerbeleidCascade},
+			{Rolnaam: "RelABs", Doeltype: "Rel_A_B", Momentvoorkomen: Meervoudig, Afvoerbeleid: AfvoerbeleidCascade},
 		},
 	},
 	"B": {
@@ -46,9 +47,10 @@ var MetaRegistry = MetaRegistryType{
 		EntiteitIDKolom:           "",
 		SecondaireEntiteitIDKolom: "",
 		// Alleen voor entiteiten: de onderliggende gegevenselementen/relaties
+		// met het afvoerbeleid bij afvoer van de entiteit (cascade, restrict of detach)
 		OnderliggendeGegevenselementen: []OnderliggendGegevenselement{
-			{Rolnaam: "Xs", Doeltype: "B_X", Momentvoorkomen: Enkelvoudig},
-			{Rolnaam: "Ys", Doeltype: "B_Y", Momentvoorkomen: Enkelvoudig},
+			{Rolnaam: "Xs", Doeltype: "B_X", Momentvoorkomen: Enkelvoudig, Afvoerbeleid: AfvoerbeleidCascade},
+			{Rolnaam: "Ys", Doeltype: "B_Y", Momentvoorkomen: Enkelvoudig, Afvoerbeleid: AfvoerbeleidCascade},
 		},
 	},
 	"Rel_A_B": {
@@ -68,7 +70,10 @@ var MetaRegistry = MetaRegistryType{
 		HeeftPFK:                  false,
 		EntiteitIDKolom:           "a_id",
 		SecondaireEntiteitIDKolom: "b_id",
+		SecondaireEntiteittype:    "B",
 		Momentvoorkomen:           Meervoudig,
+		// bij afvoer van B wordt de relatie mee afgevoerd (bij afvoer van A: zie A.OnderliggendeGegevenselementen)
+		SecondairAfvoerbeleid: AfvoerbeleidCascade,
 	},
 	"A_U": {
 		// UML
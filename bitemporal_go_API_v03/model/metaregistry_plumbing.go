@@ -1,5 +1,10 @@
 package model
 
+import (
+	"fmt"
+	"sort"
+)
+
 // Hardcoded meta model for representatie types, avoiding reflection.
 
 // Momentvoorkomen describes whether a relation is single or multiple.
@@ -10,11 +15,53 @@ const (
 	Meervoudig
 )
 
-// OnderliggendGegevenselement describes a related field on an entity and its multiplicity.
+// Afvoerbeleid bepaalt wat er met een actief gegevenselement of een actieve relatie gebeurt
+// als de entiteit waar het naar verwijst wordt afgevoerd.
+type Afvoerbeleid string
+
+const (
+	// AfvoerbeleidCascade: het gegevenselement/de relatie wordt mee afgevoerd (met een eigen afvoer wijziging). Dit is de standaard.
+	AfvoerbeleidCascade Afvoerbeleid = "cascade"
+	// AfvoerbeleidRestrict: de afvoer van de entiteit wordt geweigerd zolang er actieve gegevenselementen/relaties naar verwijzen.
+	AfvoerbeleidRestrict Afvoerbeleid = "restrict"
+	// AfvoerbeleidDetach: het gegevenselement/de relatie blijft actief; de verwijzing naar de afgevoerde entiteit blijft staan.
+	AfvoerbeleidDetach Afvoerbeleid = "detach"
+)
+
+// MetStandaard geeft het beleid terug, of cascade als er geen beleid is opgegeven.
+func (b Afvoerbeleid) MetStandaard() Afvoerbeleid {
+	if b == "" {
+		return AfvoerbeleidCascade
+	}
+	return b
+}
+
+// OnderliggendGegevenselement describes a related field on an entity, its multiplicity and its afvoer policy.
 type OnderliggendGegevenselement struct {
 	Rolnaam         string
 	Doeltype        string
 	Momentvoorkomen Momentvoorkomen // enkelvoudig of meervoudig = het voorkomen op enig moment in de tijd
+	Afvoerbeleid    Afvoerbeleid    // wat er met de actieve records gebeurt bij afvoer van de entiteit (leeg = cascade)
+	// Kardinaliteit: het minimum en maximum aantal actieve records per actieve entiteit
+	// na afloop van een registratie (Maximum 0 = onbegrensd).
+	Minimum int
+	Maximum int
+}
+
+// HeeftKardinaliteit geeft aan of er een minimum of maximum is opgegeven.
+func (o OnderliggendGegevenselement) HeeftKardinaliteit() bool {
+	return o.Minimum > 0 || o.Maximum > 0
+}
+
+// ValideerAantal controleert een aantal actieve records tegen het minimum en maximum.
+func (o OnderliggendGegevenselement) ValideerAantal(aantal int) error {
+	if aantal < o.Minimum {
+		return fmt.Errorf("%s heeft %d actieve %s (minimaal %d)", o.Rolnaam, aantal, o.Doeltype, o.Minimum)
+	}
+	if o.Maximum > 0 && aantal > o.Maximum {
+		return fmt.Errorf("%s heeft %d actieve %s (maximaal %d)", o.Rolnaam, aantal, o.Doeltype, o.Maximum)
+	}
+	return nil
 }
 
 // OnderliggendeRepresentatie koppelt een typenaam aan een concrete FormeleRepresentatie.
@@ -32,8 +79,10 @@ type HeeftOnderliggendeGegevenselementen interface {
 // TypeMeta holds metadata for a representatie type.
 type TypeMeta struct {
 	// ==== UML ====
-	Typenaam    string
-	Metatype    Metatype
+	Typenaam string
+	Metatype Metatype
+	// IsMaterieel: heeft (naast opvoer/afvoer) ook materiële tijd aanvang/einde.
+	// Bij een enkelvoudig gegevenselement mogen dan meerdere waarden actief zijn, zolang hun periodes niet overlappen.
 	IsMaterieel bool
 
 	// ==== JSON ====
@@ -63,6 +112,12 @@ type TypeMeta struct {
 
 	// SecondaireEntiteitIDKolom is the FK column for a secondary entiteit (relations only).
 	SecondaireEntiteitIDKolom string
+	// SecondaireEntiteittype is the type name of the secondary entiteit (relations only), bijv. "B" voor Rel_A_B.
+	SecondaireEntiteittype string
+
+	// SecondairAfvoerbeleid (relations only): wat er met de relatie gebeurt bij afvoer van de secundaire entiteit
+	// (SecondaireEntiteitIDKolom). Het beleid bij afvoer van de primaire entiteit staat bij OnderliggendGegevenselement.
+	SecondairAfvoerbeleid Afvoerbeleid
 
 	// ook bij het gegevenselement/relatie meta, want dat is nodig voor
 	// het automatisch afvoeren van onderliggende gegevenselementen/relaties
@@ -94,6 +149,17 @@ Handig voor plekken waar een ontbrekend type een programmeerfout is
 en je liever hard faalt.
 */
 
+// HeeftIDSequence geeft aan of de ID kolom een sequence als default heeft: bij gegevenselementen/relaties zonder PFK.
+// (Entiteiten krijgen hun ID van de client, bij een PFK geeft de trigger een relatief ID.)
+func (m TypeMeta) HeeftIDSequence() bool {
+	return m.Metatype != MetatypeEntiteit && !m.HeeftPFK
+}
+
+// IDSequence is de naam van de sequence van de ID kolom, zoals Postgres die ook bij SERIAL geeft.
+func (m TypeMeta) IDSequence() string {
+	return fmt.Sprintf("%s_%s_seq", m.Tabelnaam, m.IDKolom)
+}
+
 // GetTypeMeta returns metadata for a type, if present.
 func (r MetaRegistryType) GetTypeMeta(typeName string) (TypeMeta, bool) {
 	meta, ok := r[typeName]
@@ -119,6 +185,19 @@ func (r MetaRegistryType) GetByVeldnaam(veldnaam string) (TypeMeta, bool) {
 	return TypeMeta{}, false
 }
 
+// GetVerwijzendeRelatieMetas geeft de relatietypen (op naam gesorteerd) waarvan de secundaire entiteit van dit type is,
+// bijv. Rel_A_B voor B.
+func (r MetaRegistryType) GetVerwijzendeRelatieMetas(entiteittype string) []TypeMeta {
+	relaties := make([]TypeMeta, 0)
+	for _, meta := range r {
+		if meta.Metatype == MetatypeRelatie && meta.SecondaireEntiteittype == entiteittype {
+			relaties = append(relaties, meta)
+		}
+	}
+	sort.Slice(relaties, func(i, j int) bool { return relaties[i].Typenaam < relaties[j].Typenaam })
+	return relaties
+}
+
 // GetBovenliggendeRelatieMeta finds the parent entiteit metadata for a given child type.
 func (r MetaRegistryType) GetBovenliggendeRelatieMeta(childTypeName string) (BovenliggendeRelatieMeta, bool) {
 	for _, parentMeta := range r {
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -82,6 +83,8 @@ type WijzigingstypeEnum string
 const (
 	WijzigingstypeOpvoer WijzigingstypeEnum = "opvoer"
 	WijzigingstypeAfvoer WijzigingstypeEnum = "afvoer"
+	// WijzigingstypeMaterieel: alleen aanvang/einde van een bestaande (actieve) representatie gewijzigd
+	WijzigingstypeMaterieel WijzigingstypeEnum = "materieel"
 )
 
 // RegistratietypeEnum defines the possible values for Registratietype
@@ -98,12 +101,19 @@ const (
 type Wijziging struct {
 	bun.BaseModel     `bun:"table:wijziging"`
 	ID                int64              `json:"id" bun:"id,pk,autoincrement"`
-	Wijzigingstype    WijzigingstypeEnum `json:"wijzigingstype"`    // Opvoer of Afvoer
-	RegistratieID     int64              `json:"registratie_id"`    // verwijzing naar de registratie waarbij deze wijziging hoort
-	Representatienaam string             `json:"representatienaam"` // type-naam van de representatie, zoals "A", "B", "Rel_A_B", "A_U", "A_V", "B_X" of "B_Y"
-	RepresentatieID   string             `json:"representatie_id"`  // Bewust een string to support both numeric and string IDs, or for instance UUIDs
-	Tijdstip          time.Time          `json:"tijdstip"`          //afgeleid van registratie tijdstip
+	Wijzigingstype    WijzigingstypeEnum `json:"wijzigingstype"`                        // Opvoer, Afvoer of Materieel
+	RegistratieID     int64              `json:"registratie_id"`                        // verwijzing naar de registratie waarbij deze wijziging hoort
+	Representatienaam string             `json:"representatienaam"`                     // type-naam van de representatie, zoals "A", "B", "Rel_A_B", "A_U", "A_V", "B_X" of "B_Y"
+	EntiteitID        string             `json:"entiteit_id,omitempty" bun:",nullzero"` // alleen bij een PFK: ID van de bovenliggende entiteit
+	RepresentatieID   string             `json:"representatie_id"`                      // Bewust een string to support both numeric and string IDs, or for instance UUIDs
+	Tijdstip          time.Time          `json:"tijdstip"`                              //afgeleid van registratie tijdstip
 	// TODO TIJDSTIP ook REGISTRATIETIJDSTIP noemen?
+
+	// Alleen bij Wijzigingstype materieel: de nieuwe en de vorige aanvang/einde, zodat ongedaanmaking ze kan herstellen
+	Aanvang       *time.Time `json:"aanvang,omitempty"`
+	Einde         *time.Time `json:"einde,omitempty"`
+	VorigeAanvang *time.Time `json:"vorige_aanvang,omitempty"`
+	VorigeEinde   *time.Time `json:"vorige_einde,omitempty"`
 }
 
 // not used (yet?)
@@ -116,12 +126,24 @@ type Registratie struct {
 	bun.BaseModel              `bun:"table:registratie"`
 	ID                         int64               `json:"id" bun:"id,pk,autoincrement"`
 	Registratietype            RegistratietypeEnum `json:"registratietype"`                         // Registratie, Correctie, Ongedaanmaking
-	Tijdstip                   time.Time           `json:"tijdstip"`                                // Het tijdstip van de registratie, correctie of ongedaanmaking
+	Tijdstip                   time.Time           `json:"tijdstip" bun:",notnull,unique"`          // Het tijdstip van de registratie, correctie of ongedaanmaking
 	Opmerking                  *string             `json:"opmerking,omitempty"`                     // optioneel veld voor extra informatie
 	CorrigeertRegistratieID    *int64              `json:"corrigeert_registratie_id,omitempty"`     // bij correcties: verwijzing naar de registratie die gecorrigeerd wordt
 	MaaktOngedaanRegistratieID *int64              `json:"maakt_ongedaan_registratie_id,omitempty"` // bij ongedaanmakings: verwijzing naar de registratie die ongedaan wordt gemaakt
 }
 
+// Idempotentiesleutel legt bij een registratie de Idempotency-Key van het request vast,
+// met de hash van het request en het oorspronkelijke antwoord, zodat een herhaald request dat antwoord terugkrijgt
+type Idempotentiesleutel struct {
+	bun.BaseModel `bun:"table:idempotentiesleutel"`
+	Sleutel       string          `json:"sleutel" bun:"sleutel,pk"`         // de waarde van de Idempotency-Key header
+	RequestHash   string          `json:"request_hash" bun:",notnull"`      // SHA-256 (hex) van de request body
+	RegistratieID int64           `json:"registratie_id" bun:",notnull"`    // de registratie die met deze sleutel is vastgelegd
+	Status        int             `json:"status" bun:",notnull"`            // HTTP status van het oorspronkelijke antwoord
+	Antwoord      json.RawMessage `json:"antwoord" bun:"type:json,notnull"` // het oorspronkelijke antwoord (JSON, letterlijk bewaard)
+	Tijdstip      time.Time       `json:"tijdstip" bun:",notnull"`          // wanneer de sleutel is vastgelegd (= registratietijdstip)
+}
+
 // methodes op registratie en wijziging om ID te kunnen ophalen in de generic handlers
 func (reg Registratie) GetID() any { return reg.ID } // waarschijnlijk niet nodig, want Registratie is geen representatie
 func (wij Wijziging) GetID() any   { return wij.ID } //waarschijnlijk niet nodig, want Wijziging is geen representatie
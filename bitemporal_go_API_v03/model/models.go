@@ -23,19 +23,19 @@ func (r Rel_A_B) IsMaterieel() bool  { return true } // Rel_A_B heeft aanvang/ei
 // Gegevenselementen
 func (au A_U) GetID() any         { return au.Rel_ID }
 func (au A_U) Metatype() Metatype { return MetatypeGegevenselement }
-func (au A_U) IsMaterieel() bool  { return false } // A_U heeft geen aanvang/einde, dus is formeel
+func (au A_U) IsMaterieel() bool  { return MetaRegistry.MustTypeMeta("A_U").IsMaterieel } // instelbaar in de MetaRegistry
 
 func (av A_V) GetID() any         { return av.Rel_ID }
 func (av A_V) Metatype() Metatype { return MetatypeGegevenselement }
-func (av A_V) IsMaterieel() bool  { return false } // A_V heeft geen aanvang/einde, dus is formeel
+func (av A_V) IsMaterieel() bool  { return MetaRegistry.MustTypeMeta("A_V").IsMaterieel } // instelbaar in de MetaRegistry
 
 func (bx B_X) GetID() any         { return bx.Rel_ID }
 func (bx B_X) Metatype() Metatype { return MetatypeGegevenselement }
-func (bx B_X) IsMaterieel() bool  { return false } // B_X heeft geen aanvang/einde, dus is formeel
+func (bx B_X) IsMaterieel() bool  { return MetaRegistry.MustTypeMeta("B_X").IsMaterieel } // instelbaar in de MetaRegistry
 
 func (by B_Y) GetID() any         { return by.Rel_ID }
 func (by B_Y) Metatype() Metatype { return MetatypeGegevenselement }
-func (by B_Y) IsMaterieel() bool  { return false } // B_Y heeft geen aanvang/einde, dus is formeel
+func (by B_Y) IsMaterieel() bool  { return MetaRegistry.MustTypeMeta("B_Y").IsMaterieel } // instelbaar in de MetaRegistry
 
 /* Basis structs voor alle representaties
 Dat is zonder de relatie van entiteit naar gegevenselementen en relaties.
@@ -66,7 +66,7 @@ type B_basis struct {
 // Relaties
 type Rel_A_B struct {
 	bun.BaseModel `bun:"table:rel_a_b"`
-	ID            int        `json:"id" bun:"id,pk"`
+	ID            int        `json:"id" bun:"id,pk,autoincrement"` // zonder id geeft de sequence er een (o.a. bij een correctie)
 	A_ID          int        `json:"a_id"`
 	B_ID          int        `json:"b_id"`
 	Opvoer        *time.Time `json:"opvoer,omitempty"`
@@ -86,6 +86,8 @@ type A_U struct {
 	Bbb           string     `json:"bbb"`
 	Opvoer        *time.Time `json:"opvoer,omitempty"`
 	Afvoer        *time.Time `json:"afvoer,omitempty"`
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
+	Einde         *time.Time `json:"einde,omitempty"`   // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
 }
 
 // A (1) - (*) V
@@ -97,6 +99,8 @@ type A_V struct {
 	Ccc           string     `json:"ccc"`
 	Opvoer        *time.Time `json:"opvoer,omitempty"`
 	Afvoer        *time.Time `json:"afvoer,omitempty"`
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
+	Einde         *time.Time `json:"einde,omitempty"`   // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
 }
 
 // B (1) - (1) X
@@ -109,6 +113,8 @@ type B_X struct {
 	Ggg           string     `json:"ggg"`
 	Opvoer        *time.Time `json:"opvoer,omitempty"`
 	Afvoer        *time.Time `json:"afvoer,omitempty"`
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
+	Einde         *time.Time `json:"einde,omitempty"`   // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
 }
 
 // B (1) - (1) Y
@@ -120,6 +126,8 @@ type B_Y struct {
 	Hhh           string     `json:"hhh"`
 	Opvoer        *time.Time `json:"opvoer,omitempty"`
 	Afvoer        *time.Time `json:"afvoer,omitempty"`
+	Aanvang       *time.Time `json:"aanvang,omitempty"` // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
+	Einde         *time.Time `json:"einde,omitempty"`   // alleen gebruikt als het gegevenselement materieel is (MetaRegistry)
 }
 
 // Opvoer / Afvoer (formele tijd) methoden voor formele tijd intereface implementatie
@@ -183,7 +191,36 @@ func (r *Rel_A_B) SetAanvang(t *time.Time) { r.Aanvang = t }
 func (r Rel_A_B) GetEinde() *time.Time     { return r.Einde }
 func (r *Rel_A_B) SetEinde(t *time.Time)   { r.Einde = t }
 
-//TODO: als A_U, A_V, B_X, B_Y ook aanvang/einde krijgen, dan hier ook getters/setters toevoegen
+func (a Full_A) GetAanvang() *time.Time   { return a.Aanvang }
+func (a *Full_A) SetAanvang(t *time.Time) { a.Aanvang = t }
+func (a Full_A) GetEinde() *time.Time     { return a.Einde }
+func (a *Full_A) SetEinde(t *time.Time)   { a.Einde = t }
+
+func (b Full_B) GetAanvang() *time.Time   { return b.Aanvang }
+func (b *Full_B) SetAanvang(t *time.Time) { b.Aanvang = t }
+func (b Full_B) GetEinde() *time.Time     { return b.Einde }
+func (b *Full_B) SetEinde(t *time.Time)   { b.Einde = t }
+
+// gegevenselementen hebben altijd aanvang/einde velden; of ze gebruikt worden bepaalt IsMaterieel in de MetaRegistry
+func (au A_U) GetAanvang() *time.Time   { return au.Aanvang }
+func (au *A_U) SetAanvang(t *time.Time) { au.Aanvang = t }
+func (au A_U) GetEinde() *time.Time     { return au.Einde }
+func (au *A_U) SetEinde(t *time.Time)   { au.Einde = t }
+
+func (av A_V) GetAanvang() *time.Time   { return av.Aanvang }
+func (av *A_V) SetAanvang(t *time.Time) { av.Aanvang = t }
+func (av A_V) GetEinde() *time.Time     { return av.Einde }
+func (av *A_V) SetEinde(t *time.Time)   { av.Einde = t }
+
+func (bx B_X) GetAanvang() *time.Time   { return bx.Aanvang }
+func (bx *B_X) SetAanvang(t *time.Time) { bx.Aanvang = t }
+func (bx B_X) GetEinde() *time.Time     { return bx.Einde }
+func (bx *B_X) SetEinde(t *time.Time)   { bx.Einde = t }
+
+func (by B_Y) GetAanvang() *time.Time   { return by.Aanvang }
+func (by *B_Y) SetAanvang(t *time.Time) { by.Aanvang = t }
+func (by B_Y) GetEinde() *time.Time     { return by.Einde }
+func (by *B_Y) SetEinde(t *time.Time)   { by.Einde = t }
 
 // String methoden voor debuggen
 func (a A_basis) String() string { return RepresentatieToString(a) }
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+Semantiek van (geneste) ongedaanmakingen.
+
+Een ongedaanmaking verwijst via MaaktOngedaanRegistratieID naar een andere registratie.
+Dat kan een gewone registratie of correctie zijn, maar ook weer een ongedaanmaking.
+Zo ontstaat een keten die altijd eindigt bij een registratie met wijzigingen: de basis.
+
+- ongedaanmaking van de basis: de wijzigingen van de basis worden teruggedraaid
+- ongedaanmaking van een ongedaanmaking: de wijzigingen van de basis worden opnieuw aangebracht
+- en zo verder: elke extra ongedaanmaking in de keten keert de richting om
+
+Een registratie kan maar één keer ongedaan gemaakt worden; wie een ongedaanmaking wil herroepen,
+maakt die ongedaanmaking ongedaan. Cycli in de keten zijn ongeldig.
+*/
+
+// ErrOngedaanmakingscyclus geeft aan dat de verwijzingen tussen ongedaanmakingen een cyclus vormen.
+var ErrOngedaanmakingscyclus = errors.New("cyclus in ongedaanmakingen")
+
+// Ongedaanmakingsketen is de opgeloste keten van een nieuwe ongedaanmaking tot aan de basis.
+type Ongedaanmakingsketen struct {
+	// Basis is de registratie (registratie of correctie) aan het eind van de keten, waarvan de wijzigingen het effect bepalen.
+	Basis Registratie
+	// Schakels zijn de ongedaanmakingen tussen de ongedaan te maken registratie en de basis (inclusief de eerste, exclusief de basis).
+	Schakels []Registratie
+	// Heraanbrengen is true als de wijzigingen van de basis opnieuw aangebracht moeten worden,
+	// en false als ze teruggedraaid moeten worden.
+	Heraanbrengen bool
+}
+
+// LosOngedaanmakingsketenOp volgt vanaf de ongedaan te maken registratie de verwijzingen MaaktOngedaanRegistratieID
+// tot aan een registratie die zelf geen ongedaanmaking is. haal zoekt een registratie op ID op.
+func LosOngedaanmakingsketenOp(ongedaanTeMaken Registratie, haal func(id int64) (Registratie, bool, error)) (Ongedaanmakingsketen, error) {
+	keten := Ongedaanmakingsketen{}
+	bezocht := map[int64]bool{}
+	huidige := ongedaanTeMaken
+
+	for huidige.Registratietype == RegistratietypeOngedaanmaking {
+		if bezocht[huidige.ID] {
+			return Ongedaanmakingsketen{}, fmt.Errorf("%w: registratie %d komt twee keer voor", ErrOngedaanmakingscyclus, huidige.ID)
+		}
+		bezocht[huidige.ID] = true
+		keten.Schakels = append(keten.Schakels, huidige)
+
+		if huidige.MaaktOngedaanRegistratieID == nil {
+			return Ongedaanmakingsketen{}, fmt.Errorf("ongedaanmaking %d verwijst niet naar een registratie", huidige.ID)
+		}
+		volgende, gevonden, err := haal(*huidige.MaaktOngedaanRegistratieID)
+		if err != nil {
+			return Ongedaanmakingsketen{}, err
+		}
+		if !gevonden {
+			return Ongedaanmakingsketen{}, fmt.Errorf("ongedaanmaking %d verwijst naar onbekende registratie %d",
+				huidige.ID, *huidige.MaaktOngedaanRegistratieID)
+		}
+		huidige = volgende
+	}
+
+	keten.Basis = huidige
+	// de nieuwe ongedaanmaking zelf draait terug; elke schakel keert dat om
+	keten.Heraanbrengen = len(keten.Schakels)%2 == 1
+	return keten, nil
+}
+
+// BepaalOngedaanGemaakteRegistraties bepaalt welke registraties netto ongedaan gemaakt zijn.
+// Een ongedaanmaking telt alleen mee als zij zelf niet (netto) ongedaan gemaakt is.
+// De ongedaanmakingen hoeven niet gesorteerd te zijn; andere registratietypen worden genegeerd.
+func BepaalOngedaanGemaakteRegistraties(ongedaanmakingen []Registratie) (map[int64]bool, error) {
+	ongedaanGemaaktDoor := map[int64][]int64{}
+	for _, ongedaanmaking := range ongedaanmakingen {
+		if ongedaanmaking.Registratietype != RegistratietypeOngedaanmaking || ongedaanmaking.MaaktOngedaanRegistratieID == nil {
+			continue
+		}
+		doel := *ongedaanmaking.MaaktOngedaanRegistratieID
+		ongedaanGemaaktDoor[doel] = append(ongedaanGemaaktDoor[doel], ongedaanmaking.ID)
+	}
+
+	uitkomst := map[int64]bool{}
+	bezig := map[int64]bool{}
+
+	var isOngedaanGemaakt func(id int64) (bool, error)
+	isOngedaanGemaakt = func(id int64) (bool, error) {
+		if resultaat, ok := uitkomst[id]; ok {
+			return resultaat, nil
+		}
+		if bezig[id] {
+			return false, fmt.Errorf("%w: registratie %d", ErrOngedaanmakingscyclus, id)
+		}
+		bezig[id] = true
+		defer delete(bezig, id)
+
+		resultaat := false
+		for _, ongedaanmakingID := range ongedaanGemaaktDoor[id] {
+			zelfOngedaan, err := isOngedaanGemaakt(ongedaanmakingID)
+			if err != nil {
+				return false, err
+			}
+			if !zelfOngedaan {
+				resultaat = true
+			}
+		}
+		uitkomst[id] = resultaat
+		return resultaat, nil
+	}
+
+	ongedaanGemaakt := map[int64]bool{}
+	for doel := range ongedaanGemaaktDoor {
+		resultaat, err := isOngedaanGemaakt(doel)
+		if err != nil {
+			return nil, err
+		}
+		if resultaat {
+			ongedaanGemaakt[doel] = true
+		}
+	}
+
+	return ongedaanGemaakt, nil
+}
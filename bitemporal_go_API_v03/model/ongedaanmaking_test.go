@@ -0,0 +1,155 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func registratieVoorTest(id int64, registratietype RegistratietypeEnum, maaktOngedaan int64) Registratie {
+	registratie := Registratie{ID: id, Registratietype: registratietype}
+	if maaktOngedaan != 0 {
+		registratie.MaaktOngedaanRegistratieID = &maaktOngedaan
+	}
+	return registratie
+}
+
+func haalUitMap(registraties ...Registratie) func(id int64) (Registratie, bool, error) {
+	perID := map[int64]Registratie{}
+	for _, registratie := range registraties {
+		perID[registratie.ID] = registratie
+	}
+	return func(id int64) (Registratie, bool, error) {
+		registratie, ok := perID[id]
+		return registratie, ok, nil
+	}
+}
+
+func TestLosOngedaanmakingsketenOp(t *testing.T) {
+	r1 := registratieVoorTest(1, RegistratietypeRegistratie, 0)
+	c2 := registratieVoorTest(2, RegistratietypeCorrectie, 0)
+	o3 := registratieVoorTest(3, RegistratietypeOngedaanmaking, 1)
+	o4 := registratieVoorTest(4, RegistratietypeOngedaanmaking, 3)
+	haal := haalUitMap(r1, c2, o3, o4)
+
+	t.Run("undo of a registratie reverts its wijzigingen", func(t *testing.T) {
+		// Given: registratie 1 zonder ongedaanmakingen.
+		// When: registratie 1 ongedaan wordt gemaakt.
+		// Then: basis is 1 en de wijzigingen worden teruggedraaid.
+		keten, err := LosOngedaanmakingsketenOp(r1, haal)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keten.Basis.ID != 1 || keten.Heraanbrengen {
+			t.Fatalf("expected revert of 1, got basis %d heraanbrengen %t", keten.Basis.ID, keten.Heraanbrengen)
+		}
+	})
+
+	t.Run("undo of a correctie reverts the correctie", func(t *testing.T) {
+		// Given: correctie 2.
+		// When: correctie 2 ongedaan wordt gemaakt.
+		// Then: de wijzigingen van de correctie worden teruggedraaid (pre-correctie waarden terug).
+		keten, err := LosOngedaanmakingsketenOp(c2, haal)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keten.Basis.ID != 2 || keten.Heraanbrengen {
+			t.Fatalf("expected revert of 2, got basis %d heraanbrengen %t", keten.Basis.ID, keten.Heraanbrengen)
+		}
+	})
+
+	t.Run("undo of an undo re-applies the original", func(t *testing.T) {
+		// Given: ongedaanmaking 3 van registratie 1.
+		// When: ongedaanmaking 3 ongedaan wordt gemaakt.
+		// Then: basis is 1 en de wijzigingen worden opnieuw aangebracht.
+		keten, err := LosOngedaanmakingsketenOp(o3, haal)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keten.Basis.ID != 1 || !keten.Heraanbrengen || len(keten.Schakels) != 1 {
+			t.Fatalf("expected re-apply of 1 via 1 schakel, got %+v", keten)
+		}
+	})
+
+	t.Run("undo of an undo of an undo reverts again", func(t *testing.T) {
+		// Given: 4 maakt 3 ongedaan, 3 maakt 1 ongedaan.
+		// When: 4 ongedaan wordt gemaakt.
+		// Then: de wijzigingen van 1 worden weer teruggedraaid.
+		keten, err := LosOngedaanmakingsketenOp(o4, haal)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keten.Basis.ID != 1 || keten.Heraanbrengen || len(keten.Schakels) != 2 {
+			t.Fatalf("expected revert of 1 via 2 schakels, got %+v", keten)
+		}
+	})
+
+	t.Run("rejects cycles", func(t *testing.T) {
+		// Given: twee ongedaanmakingen die naar elkaar verwijzen.
+		// When: de keten wordt opgelost.
+		// Then: ErrOngedaanmakingscyclus.
+		o5 := registratieVoorTest(5, RegistratietypeOngedaanmaking, 6)
+		o6 := registratieVoorTest(6, RegistratietypeOngedaanmaking, 5)
+		_, err := LosOngedaanmakingsketenOp(o5, haalUitMap(o5, o6))
+		if !errors.Is(err, ErrOngedaanmakingscyclus) {
+			t.Fatalf("expected cycle error, got %v", err)
+		}
+	})
+
+	t.Run("rejects dangling reference", func(t *testing.T) {
+		// Given: een ongedaanmaking naar een onbekende registratie.
+		// When: de keten wordt opgelost.
+		// Then: een foutmelding.
+		o7 := registratieVoorTest(7, RegistratietypeOngedaanmaking, 99)
+		if _, err := LosOngedaanmakingsketenOp(o7, haalUitMap(o7)); err == nil {
+			t.Fatal("expected error for unknown registratie")
+		}
+	})
+}
+
+func TestBepaalOngedaanGemaakteRegistraties(t *testing.T) {
+	t.Run("undone undo restores the original", func(t *testing.T) {
+		// Given: 3 maakt 1 ongedaan en 4 maakt 3 ongedaan.
+		// When: de netto ongedaan gemaakte registraties worden bepaald.
+		// Then: alleen 3 is ongedaan gemaakt; 1 telt weer mee.
+		ongedaan, err := BepaalOngedaanGemaakteRegistraties([]Registratie{
+			registratieVoorTest(4, RegistratietypeOngedaanmaking, 3),
+			registratieVoorTest(3, RegistratietypeOngedaanmaking, 1),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ongedaan[1] || !ongedaan[3] || ongedaan[4] {
+			t.Fatalf("expected only 3 undone, got %v", ongedaan)
+		}
+	})
+
+	t.Run("three levels deep undoes the original again", func(t *testing.T) {
+		// Given: 3 maakt 1 ongedaan, 4 maakt 3 ongedaan, 5 maakt 4 ongedaan.
+		// When: de netto ongedaan gemaakte registraties worden bepaald.
+		// Then: 1 en 4 zijn ongedaan gemaakt.
+		ongedaan, err := BepaalOngedaanGemaakteRegistraties([]Registratie{
+			registratieVoorTest(3, RegistratietypeOngedaanmaking, 1),
+			registratieVoorTest(4, RegistratietypeOngedaanmaking, 3),
+			registratieVoorTest(5, RegistratietypeOngedaanmaking, 4),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !ongedaan[1] || ongedaan[3] || !ongedaan[4] || ongedaan[5] {
+			t.Fatalf("expected 1 and 4 undone, got %v", ongedaan)
+		}
+	})
+
+	t.Run("rejects cycles", func(t *testing.T) {
+		// Given: 5 en 6 maken elkaar ongedaan.
+		// When: de netto ongedaan gemaakte registraties worden bepaald.
+		// Then: ErrOngedaanmakingscyclus.
+		_, err := BepaalOngedaanGemaakteRegistraties([]Registratie{
+			registratieVoorTest(5, RegistratietypeOngedaanmaking, 6),
+			registratieVoorTest(6, RegistratietypeOngedaanmaking, 5),
+		})
+		if !errors.Is(err, ErrOngedaanmakingscyclus) {
+			t.Fatalf("expected cycle error, got %v", err)
+		}
+	})
+}
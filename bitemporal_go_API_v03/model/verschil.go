@@ -0,0 +1,223 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+Verschil tussen twee toestanden van een entiteit (bijv. op twee peiltijdstippen).
+
+Equal (==) vergelijkt pointers op adres en RepresentatieToString is alleen voor debuggen;
+VergelijkEntiteiten vergelijkt een Full_A/Full_B inhoudelijk:
+- de eigen velden van de entiteit (zonder opvoer/afvoer: die zijn formele plumbing)
+- per rol (OnderliggendGegevenselement.Rolnaam) de gegevenselementen/relaties, gematcht op ID:
+  - alleen in de nieuwe toestand: toegevoegd
+  - alleen in de oude toestand: verwijderd
+  - in beide, maar met andere velden: gewijzigd
+Bij een enkelvoudige rol is een nieuwe waarde een nieuwe rij (nieuw rel_id). Als er precies één is verwijderd
+en één toegevoegd, is dat dus een wijziging van de waarde en tonen we het als gewijzigd.
+*/
+
+// VeldVerschil is een veld met een andere waarde in de oude en de nieuwe toestand.
+type VeldVerschil struct {
+	Veld  string `json:"veld"` // JSON veldnaam
+	Oud   any    `json:"oud"`
+	Nieuw any    `json:"nieuw"`
+}
+
+// RepresentatieVerschil is een gegevenselement of relatie die in beide toestanden voorkomt, maar verschilt.
+type RepresentatieVerschil struct {
+	Oud    any            `json:"oud"`
+	Nieuw  any            `json:"nieuw"`
+	Velden []VeldVerschil `json:"velden"`
+}
+
+// RolVerschil zijn de verschillen binnen een rol van een entiteit.
+type RolVerschil struct {
+	Toegevoegd []any                   `json:"toegevoegd,omitempty"`
+	Verwijderd []any                   `json:"verwijderd,omitempty"`
+	Gewijzigd  []RepresentatieVerschil `json:"gewijzigd,omitempty"`
+}
+
+// IsLeeg geeft aan of er binnen de rol niets verschilt.
+func (r RolVerschil) IsLeeg() bool {
+	return len(r.Toegevoegd) == 0 && len(r.Verwijderd) == 0 && len(r.Gewijzigd) == 0
+}
+
+// EntiteitVerschil is het verschil tussen twee toestanden van een entiteit.
+// Een nil toestand betekent: de entiteit bestond (op dat moment) niet.
+type EntiteitVerschil struct {
+	BestondOud   bool                   `json:"bestond_oud"`
+	BestaatNieuw bool                   `json:"bestaat_nieuw"`
+	Velden       []VeldVerschil         `json:"velden"`
+	Rollen       map[string]RolVerschil `json:"rollen"` // alleen rollen met verschillen
+}
+
+// IsLeeg geeft aan of de twee toestanden gelijk zijn.
+func (v EntiteitVerschil) IsLeeg() bool {
+	return v.BestondOud == v.BestaatNieuw && len(v.Velden) == 0 && len(v.Rollen) == 0
+}
+
+// VergelijkEntiteiten vergelijkt twee toestanden van een entiteit (pointers naar bijv. Full_A) aan de hand van de MetaRegistry.
+func VergelijkEntiteiten(meta TypeMeta, oud Representatie, nieuw Representatie) (EntiteitVerschil, error) {
+	verschil := EntiteitVerschil{
+		BestondOud:   !isNilRepresentatie(oud),
+		BestaatNieuw: !isNilRepresentatie(nieuw),
+		Velden:       []VeldVerschil{},
+		Rollen:       map[string]RolVerschil{},
+	}
+
+	var oudeWaarde, nieuweWaarde reflect.Value
+	if verschil.BestondOud {
+		oudeWaarde = reflect.Indirect(reflect.ValueOf(oud))
+	}
+	if verschil.BestaatNieuw {
+		nieuweWaarde = reflect.Indirect(reflect.ValueOf(nieuw))
+	}
+	if verschil.BestondOud && verschil.BestaatNieuw {
+		verschil.Velden = vergelijkVelden(oudeWaarde, nieuweWaarde)
+	}
+
+	for _, onderliggend := range meta.OnderliggendeGegevenselementen {
+		oudeRijen, err := rijenVanRol(oudeWaarde, onderliggend.Rolnaam)
+		if err != nil {
+			return EntiteitVerschil{}, err
+		}
+		nieuweRijen, err := rijenVanRol(nieuweWaarde, onderliggend.Rolnaam)
+		if err != nil {
+			return EntiteitVerschil{}, err
+		}
+
+		rolVerschil := vergelijkRol(oudeRijen, nieuweRijen, onderliggend.Momentvoorkomen)
+		if !rolVerschil.IsLeeg() {
+			verschil.Rollen[onderliggend.Rolnaam] = rolVerschil
+		}
+	}
+
+	return verschil, nil
+}
+
+func isNilRepresentatie(rep Representatie) bool {
+	if rep == nil {
+		return true
+	}
+	waarde := reflect.ValueOf(rep)
+	return waarde.Kind() == reflect.Pointer && waarde.IsNil()
+}
+
+// rijenVanRol geeft de gegevenselementen/relaties in een rol, op volgorde van ID.
+func rijenVanRol(entiteit reflect.Value, rolnaam string) ([]reflect.Value, error) {
+	if !entiteit.IsValid() {
+		return nil, nil
+	}
+	veld := entiteit.FieldByName(rolnaam)
+	if !veld.IsValid() || veld.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("MODEL: %s heeft geen slice veld voor rol %s", entiteit.Type().Name(), rolnaam)
+	}
+
+	rijen := make([]reflect.Value, 0, veld.Len())
+	for i := 0; i < veld.Len(); i++ {
+		rijen = append(rijen, veld.Index(i))
+	}
+	sort.SliceStable(rijen, func(i, j int) bool { return idVanRij(rijen[i]) < idVanRij(rijen[j]) })
+	return rijen, nil
+}
+
+func idVanRij(rij reflect.Value) string {
+	if rij.CanAddr() {
+		if metID, ok := rij.Addr().Interface().(HasID); ok {
+			return fmt.Sprintf("%020v", metID.GetID())
+		}
+	}
+	return ""
+}
+
+func vergelijkRol(oud []reflect.Value, nieuw []reflect.Value, momentvoorkomen Momentvoorkomen) RolVerschil {
+	var verschil RolVerschil
+
+	nieuwPerID := map[string]reflect.Value{}
+	for _, rij := range nieuw {
+		nieuwPerID[idVanRij(rij)] = rij
+	}
+	oudPerID := map[string]bool{}
+
+	var verwijderd []reflect.Value
+	for _, rij := range oud {
+		id := idVanRij(rij)
+		oudPerID[id] = true
+		nieuweRij, ok := nieuwPerID[id]
+		if !ok {
+			verwijderd = append(verwijderd, rij)
+			continue
+		}
+		if velden := vergelijkVelden(rij, nieuweRij); len(velden) > 0 {
+			verschil.Gewijzigd = append(verschil.Gewijzigd, RepresentatieVerschil{Oud: rij.Interface(), Nieuw: nieuweRij.Interface(), Velden: velden})
+		}
+	}
+
+	var toegevoegd []reflect.Value
+	for _, rij := range nieuw {
+		if !oudPerID[idVanRij(rij)] {
+			toegevoegd = append(toegevoegd, rij)
+		}
+	}
+
+	// enkelvoudig: een vervangen waarde is een wijziging van de waarde
+	if momentvoorkomen == Enkelvoudig && len(verwijderd) == 1 && len(toegevoegd) == 1 {
+		verschil.Gewijzigd = append(verschil.Gewijzigd, RepresentatieVerschil{
+			Oud: verwijderd[0].Interface(), Nieuw: toegevoegd[0].Interface(), Velden: vergelijkVelden(verwijderd[0], toegevoegd[0]),
+		})
+		return verschil
+	}
+
+	for _, rij := range verwijderd {
+		verschil.Verwijderd = append(verschil.Verwijderd, rij.Interface())
+	}
+	for _, rij := range toegevoegd {
+		verschil.Toegevoegd = append(verschil.Toegevoegd, rij.Interface())
+	}
+	return verschil
+}
+
+// vergelijkVelden vergelijkt de enkelvoudige, geëxporteerde velden van twee structs van hetzelfde type.
+// Rollen (slices en bun relaties), de bun.BaseModel en opvoer/afvoer worden overgeslagen.
+func vergelijkVelden(oud reflect.Value, nieuw reflect.Value) []VeldVerschil {
+	verschillen := []VeldVerschil{}
+	structType := oud.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		veld := structType.Field(i)
+		if !veld.IsExported() || veld.Anonymous || veld.Type.Kind() == reflect.Slice {
+			continue
+		}
+		if strings.Contains(veld.Tag.Get("bun"), "rel:") || veld.Name == "Opvoer" || veld.Name == "Afvoer" {
+			continue
+		}
+
+		oudeWaarde, nieuweWaarde := oud.Field(i).Interface(), nieuw.Field(i).Interface()
+		if zelfdeWaarde(oudeWaarde, nieuweWaarde) {
+			continue
+		}
+		verschillen = append(verschillen, VeldVerschil{Veld: jsonVeldnaam(veld), Oud: oudeWaarde, Nieuw: nieuweWaarde})
+	}
+	return verschillen
+}
+
+func zelfdeWaarde(a any, b any) bool {
+	tijdA, okA := a.(*time.Time)
+	tijdB, okB := b.(*time.Time)
+	if okA && okB {
+		return ZelfdeTijdstip(tijdA, tijdB)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func jsonVeldnaam(veld reflect.StructField) string {
+	if naam, _, _ := strings.Cut(veld.Tag.Get("json"), ","); naam != "" && naam != "-" {
+		return naam
+	}
+	return veld.Name
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVergelijkEntiteiten(t *testing.T) {
+	metaA := MetaRegistry.MustTypeMeta("A")
+	maart := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("reports changed, added and removed gegevenselementen per rol", func(t *testing.T) {
+		// Given: oud heeft U1 en V1; nieuw heeft U2 (vervangt U1), V1 met een andere waarde en V2, en een einde.
+		// When: de toestanden vergeleken worden.
+		// Then: het einde is gewijzigd, Us heeft één gewijzigde waarde, Vs één gewijzigde en één toegevoegde.
+		oud := &Full_A{ID: 2,
+			Us: []A_U{{A_ID: 2, Rel_ID: 1, Aaa: "oud"}},
+			Vs: []A_V{{A_ID: 2, Rel_ID: 1, Ccc: "c"}}}
+		nieuw := &Full_A{ID: 2, Einde: &maart,
+			Us: []A_U{{A_ID: 2, Rel_ID: 2, Aaa: "nieuw"}},
+			Vs: []A_V{{A_ID: 2, Rel_ID: 2, Ccc: "d"}, {A_ID: 2, Rel_ID: 1, Ccc: "c2"}}}
+
+		verschil, err := VergelijkEntiteiten(metaA, oud, nieuw)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(verschil.Velden) != 1 || verschil.Velden[0].Veld != "einde" {
+			t.Fatalf("expected only einde to differ, got %+v", verschil.Velden)
+		}
+		us := verschil.Rollen["Us"]
+		if len(us.Gewijzigd) != 1 || len(us.Toegevoegd) != 0 || len(us.Verwijderd) != 0 {
+			t.Fatalf("expected the enkelvoudige U to be changed, got %+v", us)
+		}
+		vs := verschil.Rollen["Vs"]
+		if len(vs.Gewijzigd) != 1 || len(vs.Toegevoegd) != 1 || len(vs.Verwijderd) != 0 {
+			t.Fatalf("expected V1 changed and V2 added, got %+v", vs)
+		}
+		if _, ok := verschil.Rollen["RelABs"]; ok {
+			t.Fatalf("expected no entry for unchanged rol RelABs")
+		}
+	})
+
+	t.Run("equal states have no verschil, even with different time pointers", func(t *testing.T) {
+		// Given: twee toestanden met dezelfde aanvang in verschillende pointers.
+		// When: de toestanden vergeleken worden.
+		// Then: er is geen verschil.
+		kopie := maart
+		verschil, err := VergelijkEntiteiten(metaA, &Full_A{ID: 2, Aanvang: &maart}, &Full_A{ID: 2, Aanvang: &kopie})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !verschil.IsLeeg() {
+			t.Fatalf("expected no verschil, got %+v", verschil)
+		}
+	})
+
+	t.Run("an entity that did not exist yet has everything added", func(t *testing.T) {
+		// Given: oud bestaat niet; nieuw heeft een V.
+		// When: de toestanden vergeleken worden.
+		// Then: bestond_oud is false en de V is toegevoegd.
+		verschil, err := VergelijkEntiteiten(metaA, (*Full_A)(nil), &Full_A{ID: 2, Vs: []A_V{{A_ID: 2, Rel_ID: 1}}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if verschil.BestondOud || !verschil.BestaatNieuw || len(verschil.Rollen["Vs"].Toegevoegd) != 1 {
+			t.Fatalf("expected V added to a new entity, got %+v", verschil)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+/*
+Versie van een entiteit voor optimistische concurrency: de laatste registratie die de entiteit
+(of een van haar gegevenselementen/relaties) heeft geraakt. Elke registratie werkt de versie van de
+geraakte entiteiten bij, in dezelfde transactie. Een entiteit zonder versie (nog nooit geraakt sinds de
+tabel bestaat) heeft versie 0.
+
+Het ETag van een entiteit is "Typenaam/ID/registratieID", bijv. "A/1/17".
+*/
+
+// Entiteitversie is per entiteit de laatste registratie die haar heeft geraakt.
+type Entiteitversie struct {
+	bun.BaseModel `bun:"table:entiteitversie"`
+	Typenaam      string `json:"typenaam" bun:"typenaam,pk"`
+	EntiteitID    int    `json:"entiteit_id" bun:"entiteit_id,pk"`
+	RegistratieID int64  `json:"registratie_id" bun:",notnull"`
+}
+
+// ETag geeft het (sterke) ETag van de versie, inclusief aanhalingstekens.
+func (v Entiteitversie) ETag() string {
+	return fmt.Sprintf(`"%s/%d/%d"`, v.Typenaam, v.EntiteitID, v.RegistratieID)
+}
+
+// ParseETag leest een ETag zoals Entiteitversie.ETag die maakt. Een zwak ETag (W/"...") mag ook.
+func ParseETag(etag string) (Entiteitversie, error) {
+	waarde := strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	if len(waarde) < 2 || !strings.HasPrefix(waarde, `"`) || !strings.HasSuffix(waarde, `"`) {
+		return Entiteitversie{}, fmt.Errorf("MODEL: ETag %s staat niet tussen aanhalingstekens", etag)
+	}
+
+	delen := strings.Split(waarde[1:len(waarde)-1], "/")
+	if len(delen) != 3 || delen[0] == "" {
+		return Entiteitversie{}, fmt.Errorf("MODEL: ETag %s heeft niet de vorm \"Typenaam/ID/registratieID\"", etag)
+	}
+	entiteitID, err := strconv.Atoi(delen[1])
+	if err != nil {
+		return Entiteitversie{}, fmt.Errorf("MODEL: ETag %s heeft een ongeldige ID: %v", etag, err)
+	}
+	registratieID, err := strconv.ParseInt(delen[2], 10, 64)
+	if err != nil {
+		return Entiteitversie{}, fmt.Errorf("MODEL: ETag %s heeft een ongeldige registratie: %v", etag, err)
+	}
+
+	return Entiteitversie{Typenaam: delen[0], EntiteitID: entiteitID, RegistratieID: registratieID}, nil
+}
@@ -0,0 +1,40 @@
+package model
+
+import "testing"
+
+func TestEntiteitversieETag(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		// Given: A1 is het laatst geraakt door registratie 17.
+		// When: het ETag wordt gemaakt en weer gelezen.
+		// Then: het ETag is "A/1/17" en levert dezelfde versie op.
+		versie := Entiteitversie{Typenaam: "A", EntiteitID: 1, RegistratieID: 17}
+		if versie.ETag() != `"A/1/17"` {
+			t.Fatalf("unexpected ETag %s", versie.ETag())
+		}
+		gelezen, err := ParseETag(versie.ETag())
+		if err != nil || gelezen != versie {
+			t.Fatalf("expected %+v, got %+v (%v)", versie, gelezen, err)
+		}
+	})
+
+	t.Run("weak ETag", func(t *testing.T) {
+		// Given: een zwak ETag.
+		// When: het wordt gelezen.
+		// Then: het telt als dezelfde versie.
+		gelezen, err := ParseETag(` W/"B/3/0"`)
+		if err != nil || gelezen != (Entiteitversie{Typenaam: "B", EntiteitID: 3}) {
+			t.Fatalf("unexpected result %+v (%v)", gelezen, err)
+		}
+	})
+
+	t.Run("invalid ETags", func(t *testing.T) {
+		// Given: ETags zonder aanhalingstekens, met te weinig delen of met een ongeldig getal.
+		// When: ze worden gelezen.
+		// Then: volgt telkens een fout.
+		for _, etag := range []string{`A/1/17`, `"A/1"`, `"/1/17"`, `"A/x/17"`, `"A/1/x"`, `"`} {
+			if _, err := ParseETag(etag); err == nil {
+				t.Fatalf("expected an error for %s", etag)
+			}
+		}
+	})
+}
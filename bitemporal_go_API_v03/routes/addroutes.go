@@ -72,6 +72,11 @@ func AddRoutes(router *gin.Engine) {
 	router.GET("/full/bs/:id", handlers.MakeGetFullEntityHandler[model.Full_B]("B", []string{"Xs", "Ys"}))
 	router.POST("/full/bs", handlers.MakeAddFullEntityHandler[model.Full_B]("Full_B", []string{"Xs", "Ys"}))
 
+	// Historie van een entiteit (inclusief onderliggende gegevenselementen/relaties), gegroepeerd per registratie
+	router.GET("/historie/:type/:id", handlers.MakeGetHistorieHandler())
+	// Verschil van een entiteit tussen twee peiltijdstippen (?van=...&tot=...)
+	router.GET("/verschil/:type/:id", handlers.MakeGetVerschilHandler())
+
 	// Bitemporal registration, correction and undoing routes
 	// see README.md for details and examples
 	router.POST("/registreer/as", handlers.MakeRegisterFullEntityHandlerA()) // DEPRECATED, use /registratie/ endpoint instead